@@ -0,0 +1,199 @@
+package acl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Permission is a bitmask of permissions
+	Permission uint32
+
+	// ObjectIdentity identifies a domain object, e.g. invoice 42
+	ObjectIdentity struct {
+		// Type is the type of the domain object, e.g. invoice
+		Type string
+		// ID is the identifier of the domain object, e.g. 42
+		ID string
+	}
+
+	// Identifiable is implemented by domain objects which
+	// are able to tell their ObjectIdentity
+	Identifiable interface {
+		ObjectIdentity() ObjectIdentity
+	}
+
+	// Sid is a security identity, either a principal or a role
+	Sid struct {
+		// Principal is true if Name is a principal, false if it is a role
+		Principal bool
+		// Name is the principal or the role name
+		Name string
+	}
+
+	// Entry is an access control entry which grants or denies
+	// the permissions of Mask to the Sid
+	Entry struct {
+		Sid      Sid
+		Mask     Permission
+		Granting bool
+	}
+
+	// ACL is the access control list of a domain object
+	ACL struct {
+		// Object is the domain object the ACL protects
+		Object ObjectIdentity
+		// Owner is implicitly granted every permission that
+		// is not explicitly denied to it by Entries
+		Owner *Sid
+		// Parent is the ACL entries are inherited from if InheritParent is true
+		Parent *ObjectIdentity
+		// InheritParent enables inheritance from Parent
+		InheritParent bool
+		// Entries are evaluated in order, the first Entry
+		// that matches a Sid and a permission decides
+		Entries []Entry
+	}
+)
+
+const (
+	Read Permission = 1 << iota
+	Write
+	Create
+	Delete
+	Administration
+
+	// All contains every predefined permission
+	All = Read | Write | Create | Delete | Administration
+)
+
+var (
+	permissionNames = []string{"read", "write", "create", "delete", "administration"}
+
+	// ErrNotIdentifiable is returned if the ObjectIdentity of an object cannot be told
+	ErrNotIdentifiable = errors.New("object is not identifiable")
+)
+
+// String returns the names of the permissions, e.g. read|write
+func (p Permission) String() string {
+	if p == 0 {
+		return "none"
+	}
+
+	var names []string
+	for i, name := range permissionNames {
+		if p&(1<<i) != 0 {
+			names = append(names, name)
+			p &^= 1 << i
+		}
+	}
+
+	if p != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(p)))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// NewObjectIdentity returns the ObjectIdentity of the domain object
+func NewObjectIdentity(typ string, id string) ObjectIdentity {
+	return ObjectIdentity{Type: typ, ID: id}
+}
+
+func (oi ObjectIdentity) String() string {
+	return oi.Type + ":" + oi.ID
+}
+
+// IdentityOf returns the ObjectIdentity of an ObjectIdentity or an Identifiable
+func IdentityOf(object any) (ObjectIdentity, error) {
+	switch o := object.(type) {
+	case ObjectIdentity:
+		return o, nil
+	case *ObjectIdentity:
+		if o != nil {
+			return *o, nil
+		}
+	case Identifiable:
+		return o.ObjectIdentity(), nil
+	}
+
+	return ObjectIdentity{}, fmt.Errorf("%w: %T", ErrNotIdentifiable, object)
+}
+
+// PrincipalSid returns the Sid of the principal
+func PrincipalSid(principal string) Sid {
+	return Sid{Principal: true, Name: principal}
+}
+
+// RoleSid returns the Sid of the role
+func RoleSid(role string) Sid {
+	return Sid{Name: role}
+}
+
+func (s Sid) String() string {
+	if s.Principal {
+		return "principal:" + s.Name
+	}
+
+	return "role:" + s.Name
+}
+
+///=====================================
+///		    ACL
+///=====================================
+
+// NewACL returns an empty ACL of the object owned by owner
+func NewACL(object ObjectIdentity, owner Sid) *ACL {
+	return &ACL{Object: object, Owner: &owner}
+}
+
+// Grant appends an Entry which grants the permissions to the Sid
+func (a *ACL) Grant(sid Sid, mask Permission) *ACL {
+	a.Entries = append(a.Entries, Entry{Sid: sid, Mask: mask, Granting: true})
+	return a
+}
+
+// Deny appends an Entry which denies the permissions to the Sid, since entries
+// are evaluated in order, it must precede grants it should override
+func (a *ACL) Deny(sid Sid, mask Permission) *ACL {
+	a.Entries = append(a.Entries, Entry{Sid: sid, Mask: mask, Granting: false})
+	return a
+}
+
+// Revoke removes the entries of the Sid
+func (a *ACL) Revoke(sid Sid) *ACL {
+	j := 0
+	for _, e := range a.Entries {
+		if e.Sid != sid {
+			a.Entries[j] = e
+			j++
+		}
+	}
+	a.Entries = a.Entries[:j]
+	return a
+}
+
+// Inherit makes the ACL inherit the entries of parent
+func (a *ACL) Inherit(parent ObjectIdentity) *ACL {
+	a.Parent = &parent
+	a.InheritParent = true
+	return a
+}
+
+// Clone returns a deep copy of the ACL
+func (a *ACL) Clone() *ACL {
+	clone := *a
+	if a.Owner != nil {
+		owner := *a.Owner
+		clone.Owner = &owner
+	}
+
+	if a.Parent != nil {
+		parent := *a.Parent
+		clone.Parent = &parent
+	}
+
+	clone.Entries = append([]Entry(nil), a.Entries...)
+	return &clone
+}
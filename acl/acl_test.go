@@ -0,0 +1,179 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ud string
+
+func (o ud) Principal() string {
+	return string(o)
+}
+
+type rolesRealm map[string][]string
+
+func (r rolesRealm) LoadRoles(_ context.Context, userDetails authc.UserDetails) ([]authz.Role, error) {
+	var roles []authz.Role
+	for _, name := range r[userDetails.Principal()] {
+		roles = append(roles, authz.NewRole(name))
+	}
+
+	return roles, nil
+}
+
+func (r rolesRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return nil, nil
+}
+
+type failingRealm struct {
+}
+
+func (r *failingRealm) LoadRoles(context.Context, authc.UserDetails) ([]authz.Role, error) {
+	return nil, errors.New("database down")
+}
+
+func (r *failingRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return nil, errors.New("database down")
+}
+
+type invoice struct {
+	id string
+}
+
+func (i invoice) ObjectIdentity() ObjectIdentity {
+	return NewObjectIdentity("invoice", i.id)
+}
+
+func TestPermission(t *testing.T) {
+	assert.Equal(t, "none", Permission(0).String())
+	assert.Equal(t, "read|write", (Read | Write).String())
+	assert.Equal(t, "read|write|create|delete|administration", All.String())
+	assert.Equal(t, "delete|0x40", (Delete | 1<<6).String())
+}
+
+func TestHasPermission(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	realm := rolesRealm{"bob": {"accountant"}, "carol": {"auditor"}}
+	azer := NewAuthorizer(authz.NewAuthorizer(realm), store)
+
+	folder := NewObjectIdentity("folder", "2022")
+	assert.NoError(t, store.Save(ctx, NewACL(folder, PrincipalSid("root")).
+		Grant(RoleSid("auditor"), Read)))
+
+	assert.NoError(t, store.Save(ctx, NewACL(invoice{"42"}.ObjectIdentity(), PrincipalSid("alice")).
+		Inherit(folder).
+		Deny(PrincipalSid("dave"), Read).
+		Grant(PrincipalSid("alice"), Read).
+		Deny(PrincipalSid("alice"), Delete).
+		Grant(RoleSid("accountant"), Read|Write)))
+
+	// explicit entries
+	assert.True(t, azer.HasPermission(ctx, ud("bob"), invoice{"42"}, Read|Write))
+	assert.False(t, azer.HasPermission(ctx, ud("bob"), invoice{"42"}, Read|Delete))
+
+	// owner is granted unless denied
+	assert.True(t, azer.HasPermission(ctx, ud("alice"), invoice{"42"}, Read|Write|Administration))
+	assert.False(t, azer.HasPermission(ctx, ud("alice"), invoice{"42"}, Delete))
+
+	// inherited from the parent
+	assert.True(t, azer.HasPermission(ctx, ud("carol"), invoice{"42"}, Read))
+	assert.False(t, azer.HasPermission(ctx, ud("carol"), invoice{"42"}, Write))
+	assert.True(t, azer.HasPermission(ctx, ud("root"), invoice{"42"}, All))
+
+	// deny entries preceding the parent
+	assert.False(t, azer.HasPermission(ctx, ud("dave"), invoice{"42"}, Read))
+
+	// objects without ACL
+	err := azer.CheckPermission(ctx, ud("alice"), NewObjectIdentity("invoice", "43"), Read)
+	assert.ErrorIs(t, err, authz.ErrForbidden)
+	assert.EqualError(t, err, "forbidden: hasPermission(invoice:43, read)")
+
+	// empty permissions are never granted
+	err = azer.CheckPermission(ctx, ud("alice"), invoice{"42"}, 0)
+	assert.ErrorIs(t, err, authz.ErrForbidden)
+	assert.EqualError(t, err, "forbidden: hasPermission(invoice:42, none)")
+
+	_, err = IdentityOf("invoice:42")
+	assert.ErrorIs(t, err, ErrNotIdentifiable)
+	assert.ErrorIs(t, azer.CheckPermission(ctx, ud("alice"), "invoice:42", Read), ErrNotIdentifiable)
+
+	// role checks still work
+	assert.True(t, azer.HasRole(ctx, ud("bob"), authz.NewRole("accountant")))
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	h := authz.MustParseRoleHierarchy("manager > accountant")
+	azer := NewAuthorizer(authz.NewHierarchicalAuthorizer(authz.NewAuthorizer(rolesRealm{"erin": {"manager"}}), h), store)
+
+	assert.NoError(t, store.Save(ctx, NewACL(NewObjectIdentity("invoice", "1"), PrincipalSid("alice")).
+		Grant(RoleSid("accountant"), Read)))
+	assert.True(t, azer.HasPermission(ctx, ud("erin"), NewObjectIdentity("invoice", "1"), Read))
+}
+
+func TestInheritanceCycle(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	azer := NewAuthorizer(authz.NewAuthorizer(rolesRealm{}), store)
+
+	a, b := NewObjectIdentity("folder", "a"), NewObjectIdentity("folder", "b")
+	assert.NoError(t, store.Save(ctx, (&ACL{Object: a}).Inherit(b)))
+	assert.NoError(t, store.Save(ctx, (&ACL{Object: b}).Inherit(a)))
+
+	assert.False(t, azer.HasPermission(ctx, ud("alice"), a, Read))
+}
+
+func TestRealmFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	azer := NewAuthorizer(authz.NewAuthorizer(&failingRealm{}), store)
+
+	oi := NewObjectIdentity("invoice", "1")
+	assert.NoError(t, store.Save(ctx, NewACL(oi, PrincipalSid("root")).Grant(PrincipalSid("alice"), Read).Grant(RoleSid("accountant"), Read)))
+
+	err := azer.CheckPermission(ctx, ud("bob"), oi, Read)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, authz.ErrForbidden)
+
+	// principal entries do not need the realm
+	assert.True(t, azer.HasPermission(ctx, ud("alice"), oi, Read))
+}
+
+func TestMapStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore()
+	oi := NewObjectIdentity("invoice", "1")
+
+	acl, err := store.Load(ctx, oi)
+	assert.NoError(t, err)
+	assert.Nil(t, acl)
+
+	acl = NewACL(oi, PrincipalSid("alice")).Grant(PrincipalSid("bob"), Read).Grant(RoleSid("auditor"), Read)
+	assert.NoError(t, store.Save(ctx, acl))
+
+	// the store keeps a copy
+	acl.Revoke(PrincipalSid("bob"))
+	assert.Len(t, acl.Entries, 1)
+
+	loaded, err := store.Load(ctx, oi)
+	assert.NoError(t, err)
+	assert.Len(t, loaded.Entries, 2)
+	assert.Equal(t, "principal:alice", loaded.Owner.String())
+
+	assert.NoError(t, store.Delete(ctx, oi))
+	loaded, err = store.Load(ctx, oi)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = store.Load(cancelled, oi)
+	assert.ErrorIs(t, err, context.Canceled)
+}
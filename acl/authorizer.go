@@ -0,0 +1,157 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+)
+
+type (
+	// Authorizer is an authz.Authorizer which is also able
+	// to authorize permissions on domain objects
+	Authorizer interface {
+		authz.Authorizer
+		// HasPermission returns true if the user is granted every permission of perm on the
+		// object, the object is an ObjectIdentity or an Identifiable
+		HasPermission(ctx context.Context, userDetails authc.UserDetails, object any, perm Permission) bool
+		// CheckPermission is like HasPermission but tells why it fails, an authz.ForbiddenError
+		// is returned if a permission is not granted, or the Store or realm failure otherwise
+		CheckPermission(ctx context.Context, userDetails authc.UserDetails, object any, perm Permission) error
+	}
+
+	authorizer struct {
+		authz.Authorizer
+		store Store
+	}
+
+	// decision of an ACL for a single permission
+	decision int
+)
+
+const (
+	undecided decision = iota
+	granted
+	denied
+)
+
+var _ Authorizer = (*authorizer)(nil)
+
+// NewAuthorizer wraps the authz.Authorizer so that it also authorizes
+// permissions on domain objects with the ACL of the Store, role entries
+// are matched by authz.Authorizer.CheckRole, so that role hierarchies apply
+func NewAuthorizer(az authz.Authorizer, store Store) Authorizer {
+	return &authorizer{Authorizer: az, store: store}
+}
+
+func (z *authorizer) HasPermission(ctx context.Context, userDetails authc.UserDetails, object any, perm Permission) bool {
+	return z.CheckPermission(ctx, userDetails, object, perm) == nil
+}
+
+// CheckPermission evaluates each permission of perm separately, the first Entry
+// matching the permission and a Sid of the user decides, if no Entry matches,
+// the owner is granted, otherwise the parent ACL is evaluated if inherited.
+// An empty perm is never granted
+func (z *authorizer) CheckPermission(ctx context.Context, userDetails authc.UserDetails, object any, perm Permission) error {
+	oi, err := IdentityOf(object)
+	if err != nil {
+		return err
+	}
+
+	forbidden := &authz.ForbiddenError{Requirement: "hasPermission(" + oi.String() + ", " + perm.String() + ")"}
+
+	// an empty permission would be granted vacuously
+	if perm == 0 {
+		return forbidden
+	}
+
+	for bit := Permission(1); bit != 0 && bit <= perm; bit <<= 1 {
+		if perm&bit == 0 {
+			continue
+		}
+
+		d, err := z.decide(ctx, userDetails, oi, bit)
+		if err != nil {
+			return err
+		}
+
+		if d != granted {
+			return forbidden
+		}
+	}
+
+	return nil
+}
+
+func (z *authorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	if la, ok := z.Authorizer.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
+
+func (z *authorizer) decide(ctx context.Context, userDetails authc.UserDetails, oi ObjectIdentity, perm Permission) (decision, error) {
+	// guards against cyclic parents
+	visited := make(map[ObjectIdentity]bool)
+	for {
+		if visited[oi] {
+			return undecided, nil
+		}
+		visited[oi] = true
+
+		acl, err := z.store.Load(ctx, oi)
+		if err != nil || acl == nil {
+			return undecided, err
+		}
+
+		for _, e := range acl.Entries {
+			if e.Mask&perm == 0 {
+				continue
+			}
+
+			matched, err := z.matches(ctx, userDetails, e.Sid)
+			if err != nil {
+				return undecided, err
+			}
+
+			if !matched {
+				continue
+			}
+
+			if e.Granting {
+				return granted, nil
+			}
+
+			return denied, nil
+		}
+
+		if acl.Owner != nil {
+			matched, err := z.matches(ctx, userDetails, *acl.Owner)
+			if err != nil {
+				return undecided, err
+			}
+
+			if matched {
+				return granted, nil
+			}
+		}
+
+		if !acl.InheritParent || acl.Parent == nil {
+			return undecided, nil
+		}
+
+		oi = *acl.Parent
+	}
+}
+
+func (z *authorizer) matches(ctx context.Context, userDetails authc.UserDetails, sid Sid) (bool, error) {
+	if sid.Principal {
+		return sid.Name == userDetails.Principal(), nil
+	}
+
+	err := z.Authorizer.CheckRole(ctx, userDetails, authz.NewRole(sid.Name))
+	if errors.Is(err, authz.ErrForbidden) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
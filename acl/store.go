@@ -0,0 +1,76 @@
+package acl
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// Store persists ACL by ObjectIdentity
+	Store interface {
+		// Load returns the ACL of the object, or nil if the object has no ACL
+		Load(context.Context, ObjectIdentity) (*ACL, error)
+		// Save creates or replaces the ACL
+		Save(context.Context, *ACL) error
+		// Delete removes the ACL of the object
+		Delete(context.Context, ObjectIdentity) error
+	}
+
+	// MapStore is a Store backed by a map
+	MapStore struct {
+		mu   sync.RWMutex
+		acls map[ObjectIdentity]*ACL
+	}
+)
+
+var _ Store = (*MapStore)(nil)
+
+func NewStore() *MapStore {
+	return &MapStore{acls: make(map[ObjectIdentity]*ACL)}
+}
+
+func (s *MapStore) Load(ctx context.Context, object ObjectIdentity) (*ACL, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acl, ok := s.acls[object]
+	if !ok {
+		return nil, nil
+	}
+
+	return acl.Clone(), nil
+}
+
+func (s *MapStore) Save(ctx context.Context, acl *ACL) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.acls[acl.Object] = acl.Clone()
+	return nil
+}
+
+func (s *MapStore) Delete(ctx context.Context, object ObjectIdentity) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.acls, object)
+	return nil
+}
@@ -3,11 +3,16 @@ package authc
 import (
 	"context"
 	"errors"
+	"github.com/shrinex/shield/event"
 )
 
 type (
 	authenticator struct {
-		realms []Realm
+		realms      []Realm
+		strategy    Strategy
+		preChecker  UserDetailsChecker
+		postChecker UserDetailsChecker
+		publisher   event.Publisher
 	}
 )
 
@@ -16,10 +21,16 @@ var (
 
 	ErrInvalidToken    = errors.New("invalid token")
 	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrSecondFactorRequired is returned when the user has not passed the second factor yet
+	ErrSecondFactorRequired = errors.New("second factor required")
 )
 
+// NewAuthenticator returns an Authenticator that consults realms in order
+// and uses the FirstSuccessful Strategy, use Builder with FirstSuccessfulLenient
+// to fall back to later realms when a realm fails with an error other than
+// ErrUnauthenticated
 func NewAuthenticator(realm Realm, realms ...Realm) Authenticator {
-	return &authenticator{realms: append(realms, realm)}
+	return NewBuilder().Realms(realm, realms...).Build()
 }
 
 func (c *authenticator) Authenticate(ctx context.Context, token Token) (UserDetails, error) {
@@ -27,21 +38,37 @@ func (c *authenticator) Authenticate(ctx context.Context, token Token) (UserDeta
 		return nil, ErrInvalidToken
 	}
 
-	for _, r := range c.realms {
-		if r.Supports(token) {
-			user, err := r.LoadUserDetails(ctx, token)
-			if err != nil {
-				// gives other realm a chance
-				if errors.Is(err, ErrUnauthenticated) {
-					continue
-				}
-				return nil, err
-			}
-			return user, nil
+	user, err := c.strategy.Authenticate(ctx, c.realms, token)
+	if err == nil {
+		user, err = c.check(ctx, user)
+	}
+
+	if err != nil {
+		c.publisher.Publish(ctx, &AuthenticationFailureEvent{Token: token, Err: err})
+		return nil, err
+	}
+
+	if !Resumption(ctx) {
+		c.publisher.Publish(ctx, &AuthenticationSuccessEvent{Token: token, UserDetails: user})
+	}
+
+	return user, nil
+}
+
+func (c *authenticator) check(ctx context.Context, user UserDetails) (UserDetails, error) {
+	if c.preChecker != nil {
+		if err := c.preChecker.Check(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.postChecker != nil {
+		if err := c.postChecker.Check(ctx, user); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil, ErrUnauthenticated
+	return user, nil
 }
 
 func (c *authenticator) Logout(ctx context.Context, userDetails UserDetails) {
@@ -0,0 +1,71 @@
+package authc
+
+import "github.com/shrinex/shield/event"
+
+// Builder provides a fluent way to create Authenticator
+type Builder struct {
+	realms      []Realm
+	strategy    Strategy
+	preChecker  UserDetailsChecker
+	postChecker UserDetailsChecker
+	publisher   event.Publisher
+}
+
+// NewBuilder returns a newly created Builder
+func NewBuilder() *Builder {
+	return &Builder{
+		strategy:    FirstSuccessful,
+		preChecker:  PreAuthenticationChecker,
+		postChecker: PostAuthenticationChecker,
+		publisher:   event.Default,
+	}
+}
+
+// Realms supplies realms used by Authenticator, they are consulted in order
+func (b *Builder) Realms(realm Realm, realms ...Realm) *Builder {
+	b.realms = append(append(b.realms, realm), realms...)
+	return b
+}
+
+// Strategy supplies a Strategy that decides how the results of
+// multiple realms are combined, FirstSuccessful is used by default
+func (b *Builder) Strategy(strategy Strategy) *Builder {
+	b.strategy = strategy
+	return b
+}
+
+// PreChecker supplies a checker that runs once a realm has loaded the
+// UserDetails, PreAuthenticationChecker is used by default
+func (b *Builder) PreChecker(checker UserDetailsChecker) *Builder {
+	b.preChecker = checker
+	return b
+}
+
+// PostChecker supplies a checker that runs after the pre-checker
+// passed, PostAuthenticationChecker is used by default
+func (b *Builder) PostChecker(checker UserDetailsChecker) *Builder {
+	b.postChecker = checker
+	return b
+}
+
+// Publisher supplies a Publisher that receives AuthenticationSuccessEvent
+// and AuthenticationFailureEvent, event.Default is used by default
+func (b *Builder) Publisher(publisher event.Publisher) *Builder {
+	b.publisher = publisher
+	return b
+}
+
+// Build creates the Authenticator
+func (b *Builder) Build() Authenticator {
+	if len(b.realms) == 0 || b.strategy == nil || b.publisher == nil {
+		panic("nil")
+	}
+
+	return &authenticator{
+		realms:      b.realms,
+		strategy:    b.strategy,
+		preChecker:  b.preChecker,
+		postChecker: b.postChecker,
+		publisher:   b.publisher,
+	}
+}
@@ -0,0 +1,113 @@
+package authc
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// AccountStatus is an optional interface of UserDetails
+	// which reports the status of the account
+	AccountStatus interface {
+		// Enabled returns false if the account has been disabled
+		Enabled() bool
+		// Locked returns true if the account has been locked
+		Locked() bool
+		// AccountExpired returns true if the account has expired
+		AccountExpired() bool
+		// CredentialsExpired returns true if the credentials has expired,
+		// the user is usually asked to change the password
+		CredentialsExpired() bool
+	}
+
+	// A UserDetailsChecker checks the status of the loaded UserDetails
+	UserDetailsChecker interface {
+		// Check returns an error if the UserDetails should not be authenticated
+		Check(context.Context, UserDetails) error
+	}
+
+	// UserDetailsCheckerFunc is an adapter to allow the use of ordinary functions as UserDetailsChecker
+	UserDetailsCheckerFunc func(context.Context, UserDetails) error
+
+	// AccountStatusError is returned when the account status check failed,
+	// it unwraps to one of ErrAccountDisabled, ErrAccountLocked,
+	// ErrAccountExpired and ErrCredentialsExpired
+	AccountStatusError struct {
+		// UserDetails is the account that failed the check
+		UserDetails UserDetails
+		// Err is the reason
+		Err error
+	}
+
+	preAuthenticationChecker struct {
+	}
+
+	postAuthenticationChecker struct {
+	}
+)
+
+var (
+	_ UserDetailsChecker = (UserDetailsCheckerFunc)(nil)
+	_ UserDetailsChecker = (*preAuthenticationChecker)(nil)
+	_ UserDetailsChecker = (*postAuthenticationChecker)(nil)
+
+	// ErrAccountDisabled is returned when the account has been disabled
+	ErrAccountDisabled = errors.New("account disabled")
+	// ErrAccountLocked is returned when the account has been locked
+	ErrAccountLocked = errors.New("account locked")
+	// ErrAccountExpired is returned when the account has expired
+	ErrAccountExpired = errors.New("account expired")
+	// ErrCredentialsExpired is returned when the credentials has expired
+	ErrCredentialsExpired = errors.New("credentials expired")
+
+	// PreAuthenticationChecker checks the account is enabled, not locked and not expired
+	PreAuthenticationChecker UserDetailsChecker = &preAuthenticationChecker{}
+	// PostAuthenticationChecker checks the credentials is not expired
+	PostAuthenticationChecker UserDetailsChecker = &postAuthenticationChecker{}
+)
+
+func (f UserDetailsCheckerFunc) Check(ctx context.Context, userDetails UserDetails) error {
+	return f(ctx, userDetails)
+}
+
+func (c *preAuthenticationChecker) Check(_ context.Context, userDetails UserDetails) error {
+	status, ok := userDetails.(AccountStatus)
+	if !ok {
+		return nil
+	}
+
+	if status.Locked() {
+		return &AccountStatusError{UserDetails: userDetails, Err: ErrAccountLocked}
+	}
+
+	if !status.Enabled() {
+		return &AccountStatusError{UserDetails: userDetails, Err: ErrAccountDisabled}
+	}
+
+	if status.AccountExpired() {
+		return &AccountStatusError{UserDetails: userDetails, Err: ErrAccountExpired}
+	}
+
+	return nil
+}
+
+func (c *postAuthenticationChecker) Check(_ context.Context, userDetails UserDetails) error {
+	status, ok := userDetails.(AccountStatus)
+	if !ok {
+		return nil
+	}
+
+	if status.CredentialsExpired() {
+		return &AccountStatusError{UserDetails: userDetails, Err: ErrCredentialsExpired}
+	}
+
+	return nil
+}
+
+func (e *AccountStatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AccountStatusError) Unwrap() error {
+	return e.Err
+}
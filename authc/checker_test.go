@@ -0,0 +1,99 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type mockAccount struct {
+	principal          string
+	disabled           bool
+	locked             bool
+	accountExpired     bool
+	credentialsExpired bool
+}
+
+func (a *mockAccount) Principal() string {
+	return a.principal
+}
+
+func (a *mockAccount) Enabled() bool {
+	return !a.disabled
+}
+
+func (a *mockAccount) Locked() bool {
+	return a.locked
+}
+
+func (a *mockAccount) AccountExpired() bool {
+	return a.accountExpired
+}
+
+func (a *mockAccount) CredentialsExpired() bool {
+	return a.credentialsExpired
+}
+
+func TestAccountStatusChecks(t *testing.T) {
+	cases := []struct {
+		account *mockAccount
+		err     error
+	}{
+		{&mockAccount{principal: "archer"}, nil},
+		{&mockAccount{principal: "archer", disabled: true}, ErrAccountDisabled},
+		{&mockAccount{principal: "archer", locked: true}, ErrAccountLocked},
+		{&mockAccount{principal: "archer", accountExpired: true}, ErrAccountExpired},
+		{&mockAccount{principal: "archer", credentialsExpired: true}, ErrCredentialsExpired},
+	}
+
+	for _, c := range cases {
+		mr := &mockRealm{}
+		mr.On("Supports", mock.Anything).Return(true)
+		mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(c.account, nil)
+
+		ac := NewAuthenticator(mr)
+		user, err := ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+		if c.err == nil {
+			assert.NoError(t, err)
+			assert.Equal(t, c.account, user)
+			continue
+		}
+
+		assert.ErrorIs(t, err, c.err)
+		assert.NotErrorIs(t, err, ErrUnauthenticated)
+		assert.Nil(t, user)
+
+		var statusErr *AccountStatusError
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, c.account, statusErr.UserDetails)
+	}
+}
+
+func TestCustomCheckers(t *testing.T) {
+	mr := &mockRealm{}
+	mr.On("Supports", mock.Anything).Return(true)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).
+		Return(&mockAccount{principal: "archer", credentialsExpired: true}, nil)
+
+	errBlocked := errors.New("blocked")
+	ac := NewBuilder().
+		Realms(mr).
+		PreChecker(UserDetailsCheckerFunc(func(ctx context.Context, userDetails UserDetails) error {
+			if userDetails.Principal() == "archer" {
+				return errBlocked
+			}
+			return nil
+		})).
+		Build()
+
+	_, err := ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+	assert.ErrorIs(t, err, errBlocked)
+
+	// credentials expiration is allowed if the post-checker is disabled
+	ac = NewBuilder().Realms(mr).PostChecker(nil).Build()
+	user, err := ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+}
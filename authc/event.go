@@ -0,0 +1,53 @@
+package authc
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// AuthenticationSuccessEvent is published once a Token is authenticated,
+	// unless the context is marked by WithResumption
+	AuthenticationSuccessEvent struct {
+		// Token that was authenticated, listeners should
+		// not log it since it may contain credentials
+		Token Token
+		// UserDetails of the authenticated user
+		UserDetails UserDetails
+	}
+
+	// AuthenticationFailureEvent is published once a Token is rejected
+	AuthenticationFailureEvent struct {
+		// Token that was rejected, listeners should
+		// not log it since it may contain credentials
+		Token Token
+		// Err is the reason
+		Err error
+	}
+
+	resumptionCtxKey struct{}
+)
+
+// WithResumption marks the context so that the Authenticator does not publish
+// AuthenticationSuccessEvent, it is used when a token issued by a former login,
+// e.g. a session token sent with every request, is presented again
+func WithResumption(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resumptionCtxKey{}, true)
+}
+
+// Resumption reports whether the context is marked by WithResumption
+func Resumption(ctx context.Context) bool {
+	resumption, _ := ctx.Value(resumptionCtxKey{}).(bool)
+	return resumption
+}
+
+// RealmErrors returns the reason of every Realm that rejected
+// the Token, it is empty if the Token was rejected by a checker
+func (e *AuthenticationFailureEvent) RealmErrors() []*RealmError {
+	var ae *AggregateError
+	if errors.As(e.Err, &ae) {
+		return ae.Errors
+	}
+
+	return nil
+}
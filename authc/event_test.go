@@ -0,0 +1,53 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"github.com/shrinex/shield/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuthenticationEvents(t *testing.T) {
+	var (
+		successes []*AuthenticationSuccessEvent
+		failures  []*AuthenticationFailureEvent
+	)
+	publisher := event.NewMulticaster()
+	publisher.AddListener(event.On(func(_ context.Context, e *AuthenticationSuccessEvent) {
+		successes = append(successes, e)
+	}))
+	publisher.AddListener(event.On(func(_ context.Context, e *AuthenticationFailureEvent) {
+		failures = append(failures, e)
+	}))
+
+	errDown := errors.New("directory down")
+	ldap := newNamedRealm("ldap", nil, errDown)
+	local := newNamedRealm("local", NewBearerToken("archer"), nil)
+
+	ac := NewBuilder().Realms(ldap, local).Strategy(FirstSuccessfulLenient).Publisher(publisher).Build()
+	user, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(successes))
+	assert.Equal(t, user, successes[0].UserDetails)
+
+	_, err = ac.Authenticate(WithResumption(context.TODO()), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(successes))
+
+	ac = NewBuilder().Realms(ldap).Publisher(publisher).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(failures))
+	assert.Equal(t, err, failures[0].Err)
+	assert.Equal(t, 1, len(failures[0].RealmErrors()))
+	assert.Equal(t, ldap, failures[0].RealmErrors()[0].Realm)
+	assert.ErrorIs(t, failures[0].RealmErrors()[0], errDown)
+
+	disabled := newNamedRealm("disabled", &mockAccount{principal: "archer", disabled: true}, nil)
+	ac = NewBuilder().Realms(disabled).Publisher(publisher).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+	assert.Equal(t, 2, len(failures))
+	assert.Empty(t, failures[1].RealmErrors())
+}
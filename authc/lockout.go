@@ -0,0 +1,404 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// Attempts records consecutive authentication failures of a key
+	Attempts struct {
+		// Failures is the number of consecutive failures within the window
+		Failures int
+		// LockedUntil is the time before which attempts are rejected
+		LockedUntil time.Time
+	}
+
+	// A LockoutStore keeps track of Attempts, it can be backed
+	// by a shared storage so that all replicas see the same counters
+	LockoutStore interface {
+		// Load returns the Attempts of the key, zero value if not found
+		Load(context.Context, string) (Attempts, error)
+		// Increment atomically records a failure and returns the updated failures,
+		// failures are forgotten once no failure happens within the window
+		Increment(context.Context, string, time.Duration) (int, error)
+		// LockUntil rejects attempts of the key until the specified time
+		LockUntil(context.Context, string, time.Time) error
+		// Reset forgets all failures of the key
+		Reset(context.Context, string) error
+	}
+
+	// LockoutOption can be used to customize LockoutOptions
+	LockoutOption func(*LockoutOptions)
+
+	// LockoutOptions contains config attribute that can
+	// affect how brute-force attempts are throttled
+	LockoutOptions struct {
+		// MaxFailures is the number of failures per principal before locking
+		MaxFailures int
+		// MaxClientFailures is the number of failures per client key before locking,
+		// it is usually larger since a client may try many principals legitimately
+		MaxClientFailures int
+		// Window controls how long failures are remembered
+		Window time.Duration
+		// LockDuration is the lock duration once MaxFailures is reached
+		LockDuration time.Duration
+		// MaxLockDuration caps the lock duration, the lock duration doubles on
+		// every further failure until reaching it, set it to LockDuration to
+		// disable exponential backoff
+		MaxLockDuration time.Duration
+	}
+
+	// LockedError is returned when too many failures happened,
+	// errors.Is(err, ErrLocked) reports true for it
+	LockedError struct {
+		// RetryAfter is the time after which attempts are accepted again
+		RetryAfter time.Time
+	}
+
+	lockedRecord struct {
+		failures    int
+		lastFailure time.Time
+		window      time.Duration
+		lockedUntil time.Time
+	}
+
+	// MapLockoutStore is a LockoutStore backed by a map, records are
+	// evicted once both the failure window and the lock have passed
+	MapLockoutStore struct {
+		mu        sync.Mutex
+		records   map[string]*lockedRecord
+		stopGuard sync.Once
+		stopChan  chan struct{}
+	}
+
+	lockoutKey struct {
+		name        string
+		maxFailures int
+	}
+
+	lockoutAuthenticator struct {
+		authenticator Authenticator
+		store         LockoutStore
+		opt           *LockoutOptions
+	}
+
+	clientKeyCtxKey struct{}
+)
+
+var (
+	_ LockoutStore  = (*MapLockoutStore)(nil)
+	_ Authenticator = (*lockoutAuthenticator)(nil)
+	_ LogoutAware   = (*lockoutAuthenticator)(nil)
+
+	// ErrLocked is returned when attempts are rejected temporarily
+	ErrLocked = errors.New("locked")
+
+	nowFunc = time.Now
+)
+
+var defaultLockoutOptions = LockoutOptions{
+	MaxFailures:       5,
+	MaxClientFailures: 20,
+	Window:            15 * time.Minute,
+	LockDuration:      time.Minute,
+	MaxLockDuration:   time.Hour,
+}
+
+func WithMaxFailures(maxFailures int) LockoutOption {
+	return func(opt *LockoutOptions) {
+		if maxFailures > 0 {
+			opt.MaxFailures = maxFailures
+		}
+	}
+}
+
+func WithMaxClientFailures(maxFailures int) LockoutOption {
+	return func(opt *LockoutOptions) {
+		if maxFailures > 0 {
+			opt.MaxClientFailures = maxFailures
+		}
+	}
+}
+
+func WithFailureWindow(window time.Duration) LockoutOption {
+	return func(opt *LockoutOptions) {
+		if window > 0 {
+			opt.Window = window
+		}
+	}
+}
+
+func WithLockDuration(lockDuration time.Duration, maxLockDuration time.Duration) LockoutOption {
+	return func(opt *LockoutOptions) {
+		if lockDuration > 0 && maxLockDuration >= lockDuration {
+			opt.LockDuration = lockDuration
+			opt.MaxLockDuration = maxLockDuration
+		}
+	}
+}
+
+// WithClientKey binds a client key, e.g. the remote IP, to the context so
+// that failures are counted per client as well as per principal
+func WithClientKey(ctx context.Context, clientKey string) context.Context {
+	return context.WithValue(ctx, clientKeyCtxKey{}, clientKey)
+}
+
+// ClientKey returns the client key bound by WithClientKey
+func ClientKey(ctx context.Context) string {
+	clientKey, _ := ctx.Value(clientKeyCtxKey{}).(string)
+	return clientKey
+}
+
+// NewLockoutAuthenticator wraps an Authenticator, failures that are ErrUnauthenticated
+// are counted per principal and per client key, and further attempts are rejected
+// with LockedError once the limit is reached. Other errors are not counted.
+// NewLockoutStore is used if the store is nil
+func NewLockoutAuthenticator(authenticator Authenticator, store LockoutStore, opts ...LockoutOption) Authenticator {
+	opt := defaultLockoutOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	if store == nil {
+		store = NewLockoutStore()
+	}
+
+	return &lockoutAuthenticator{
+		authenticator: authenticator,
+		store:         store,
+		opt:           &opt,
+	}
+}
+
+func (c *lockoutAuthenticator) Authenticate(ctx context.Context, token Token) (UserDetails, error) {
+	if token == nil || len(token.Principal()) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	keys := c.keys(ctx, token)
+	for _, k := range keys {
+		attempts, err := c.store.Load(ctx, k.name)
+		if err != nil {
+			return nil, err
+		}
+
+		if nowFunc().Before(attempts.LockedUntil) {
+			return nil, &LockedError{RetryAfter: attempts.LockedUntil}
+		}
+	}
+
+	userDetails, err := c.authenticator.Authenticate(ctx, token)
+	if err == nil {
+		// only the principal is reset, client failures expire on their own,
+		// otherwise a client owning an account could spray passwords and
+		// clear its counter by logging in. A failed reset should not fail
+		// the login attempt
+		_ = c.store.Reset(ctx, keys[0].name)
+
+		return userDetails, nil
+	}
+
+	if !errors.Is(err, ErrUnauthenticated) {
+		return nil, err
+	}
+
+	for _, k := range keys {
+		failures, ierr := c.store.Increment(ctx, k.name, c.opt.Window)
+		if ierr != nil {
+			return nil, ierr
+		}
+
+		if failures < k.maxFailures {
+			continue
+		}
+
+		ierr = c.store.LockUntil(ctx, k.name, nowFunc().Add(c.lockDuration(failures-k.maxFailures)))
+		if ierr != nil {
+			return nil, ierr
+		}
+	}
+
+	return nil, err
+}
+
+func (c *lockoutAuthenticator) Logout(ctx context.Context, userDetails UserDetails) {
+	if la, ok := c.authenticator.(LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
+
+func (c *lockoutAuthenticator) keys(ctx context.Context, token Token) []lockoutKey {
+	keys := []lockoutKey{{
+		name:        "principal:" + token.Principal(),
+		maxFailures: c.opt.MaxFailures,
+	}}
+
+	if clientKey := ClientKey(ctx); len(clientKey) != 0 {
+		keys = append(keys, lockoutKey{
+			name:        "client:" + clientKey,
+			maxFailures: c.opt.MaxClientFailures,
+		})
+	}
+
+	return keys
+}
+
+func (c *lockoutAuthenticator) lockDuration(exceeded int) time.Duration {
+	duration := c.opt.LockDuration
+	for i := 0; i < exceeded && duration < c.opt.MaxLockDuration; i++ {
+		duration *= 2
+	}
+
+	if duration > c.opt.MaxLockDuration {
+		return c.opt.MaxLockDuration
+	}
+
+	return duration
+}
+
+///=====================================
+///		    LockedError
+///=====================================
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("locked, retry after %s", e.RetryAfter.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+///=====================================
+///		    MapLockoutStore
+///=====================================
+
+// NewLockoutStore returns a MapLockoutStore which evicts
+// stale records in background until StopCleanup is called
+func NewLockoutStore() *MapLockoutStore {
+	s := &MapLockoutStore{
+		records:  make(map[string]*lockedRecord),
+		stopChan: make(chan struct{}),
+	}
+
+	go s.startCleanup()
+
+	return s
+}
+
+func (s *MapLockoutStore) Load(ctx context.Context, key string) (Attempts, error) {
+	select {
+	case <-ctx.Done():
+		return Attempts{}, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok || record.stale(nowFunc()) {
+		return Attempts{}, nil
+	}
+
+	return Attempts{
+		Failures:    record.failures,
+		LockedUntil: record.lockedUntil,
+	}, nil
+}
+
+func (s *MapLockoutStore) Increment(ctx context.Context, key string, window time.Duration) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nowTime := nowFunc()
+	record, ok := s.records[key]
+	if !ok || record.lastFailure.Add(window).Before(nowTime) {
+		record = &lockedRecord{}
+		s.records[key] = record
+	}
+
+	record.failures++
+	record.lastFailure = nowTime
+	record.window = window
+	return record.failures, nil
+}
+
+func (s *MapLockoutStore) LockUntil(ctx context.Context, key string, lockedUntil time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		record.lockedUntil = lockedUntil
+	}
+
+	return nil
+}
+
+func (s *MapLockoutStore) Reset(ctx context.Context, key string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MapLockoutStore) StopCleanup() error {
+	s.stopGuard.Do(func() {
+		close(s.stopChan)
+	})
+
+	return nil
+}
+
+func (s *MapLockoutStore) startCleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteStale()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *MapLockoutStore) deleteStale() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nowTime := nowFunc()
+	for key, record := range s.records {
+		if record.stale(nowTime) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// stale reports whether the failures have been forgotten and the lock has expired
+func (r *lockedRecord) stale(nowTime time.Time) bool {
+	return r.lastFailure.Add(r.window).Before(nowTime) && !nowTime.Before(r.lockedUntil)
+}
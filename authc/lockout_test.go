@@ -0,0 +1,160 @@
+package authc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestLockoutPerPrincipal(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	nowTime := time.Unix(0, 0)
+	nowFunc = func() time.Time { return nowTime }
+
+	mr := &mockRealm{}
+	mr.On("Supports", mock.Anything).Return(true)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(nil, ErrUnauthenticated).Times(5)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(NewBearerToken("archer"), nil)
+
+	ac := NewLockoutAuthenticator(NewAuthenticator(mr), NewLockoutStore(),
+		WithMaxFailures(3), WithLockDuration(time.Minute, 4*time.Minute))
+	tk := NewUsernamePasswordToken("archer", "bad")
+
+	for i := 0; i < 3; i++ {
+		_, err := ac.Authenticate(context.TODO(), tk)
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+	}
+
+	_, err := ac.Authenticate(context.TODO(), tk)
+	assert.ErrorIs(t, err, ErrLocked)
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.Equal(t, nowTime.Add(time.Minute), locked.RetryAfter)
+
+	// other principals are not affected
+	_, err = ac.Authenticate(context.TODO(), NewUsernamePasswordToken("guest", "bad"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.NotErrorIs(t, err, ErrLocked)
+
+	// lock doubles on further failure
+	nowFunc = func() time.Time { return nowTime.Add(time.Minute) }
+	_, err = ac.Authenticate(context.TODO(), tk)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.NotErrorIs(t, err, ErrLocked)
+
+	_, err = ac.Authenticate(context.TODO(), tk)
+	assert.ErrorIs(t, err, ErrLocked)
+	assert.ErrorAs(t, err, &locked)
+	assert.Equal(t, nowTime.Add(3*time.Minute), locked.RetryAfter)
+
+	nowFunc = func() time.Time { return nowTime.Add(3 * time.Minute) }
+	user, err := ac.Authenticate(context.TODO(), tk)
+	assert.NoError(t, err)
+	assert.NotNil(t, user)
+
+	// success resets the counter
+	attempts, err := ac.(*lockoutAuthenticator).store.Load(context.TODO(), "principal:archer")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+}
+
+func TestLockoutPerClient(t *testing.T) {
+	mr := &mockRealm{}
+	mr.On("Supports", mock.Anything).Return(true)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(nil, ErrUnauthenticated)
+
+	ac := NewLockoutAuthenticator(NewAuthenticator(mr), NewLockoutStore(),
+		WithMaxFailures(10), WithMaxClientFailures(2))
+	ctx := WithClientKey(context.TODO(), "10.0.0.1")
+	assert.Equal(t, "10.0.0.1", ClientKey(ctx))
+
+	// password spraying across principals
+	_, err := ac.Authenticate(ctx, NewUsernamePasswordToken("a", "123456"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = ac.Authenticate(ctx, NewUsernamePasswordToken("b", "123456"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = ac.Authenticate(ctx, NewUsernamePasswordToken("c", "123456"))
+	assert.ErrorIs(t, err, ErrLocked)
+
+	// other clients are not affected
+	_, err = ac.Authenticate(context.TODO(), NewUsernamePasswordToken("c", "123456"))
+	assert.NotErrorIs(t, err, ErrLocked)
+}
+
+func TestLockoutIgnoresOtherErrors(t *testing.T) {
+	mr := &mockRealm{}
+	mr.On("Supports", mock.Anything).Return(true)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(nil, context.DeadlineExceeded)
+
+	ac := NewLockoutAuthenticator(NewAuthenticator(mr), NewLockoutStore(), WithMaxFailures(1))
+
+	for i := 0; i < 3; i++ {
+		_, err := ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
+
+func TestLockoutKeepsClientFailuresOnSuccess(t *testing.T) {
+	mr := &mockRealm{}
+	mr.On("Supports", mock.Anything).Return(true)
+	mr.On("LoadUserDetails", mock.Anything, mock.MatchedBy(func(token Token) bool {
+		return token.Principal() == "mallory" && token.Credentials() == "123"
+	})).Return(NewBearerToken("mallory"), nil)
+	mr.On("LoadUserDetails", mock.Anything, mock.Anything).Return(nil, ErrUnauthenticated)
+
+	// the store defaults to a MapLockoutStore
+	ac := NewLockoutAuthenticator(NewAuthenticator(mr), nil, WithMaxClientFailures(3))
+	ctx := WithClientKey(context.TODO(), "10.0.0.1")
+
+	// password spraying interleaved with logins to an owned account
+	for _, principal := range []string{"a", "b"} {
+		_, err := ac.Authenticate(ctx, NewUsernamePasswordToken(principal, "123456"))
+		assert.ErrorIs(t, err, ErrUnauthenticated)
+
+		_, err = ac.Authenticate(ctx, NewUsernamePasswordToken("mallory", "123"))
+		assert.NoError(t, err)
+	}
+
+	_, err := ac.Authenticate(ctx, NewUsernamePasswordToken("c", "123456"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = ac.Authenticate(ctx, NewUsernamePasswordToken("d", "123456"))
+	assert.ErrorIs(t, err, ErrLocked)
+
+	attempts, err := ac.(*lockoutAuthenticator).store.Load(context.TODO(), "client:10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts.Failures)
+}
+
+func TestMapLockoutStoreEviction(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	nowTime := time.Unix(0, 0)
+	nowFunc = func() time.Time { return nowTime }
+
+	store := NewLockoutStore()
+	defer store.StopCleanup()
+
+	_, err := store.Increment(context.TODO(), "a", time.Minute)
+	assert.NoError(t, err)
+	_, err = store.Increment(context.TODO(), "b", time.Minute)
+	assert.NoError(t, err)
+	assert.NoError(t, store.LockUntil(context.TODO(), "b", nowTime.Add(time.Hour)))
+
+	nowFunc = func() time.Time { return nowTime.Add(2 * time.Minute) }
+	attempts, err := store.Load(context.TODO(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+
+	store.deleteStale()
+	assert.Equal(t, 1, len(store.records))
+
+	// kept until the lock expires
+	attempts, err = store.Load(context.TODO(), "b")
+	assert.NoError(t, err)
+	assert.Equal(t, nowTime.Add(time.Hour), attempts.LockedUntil)
+
+	nowFunc = func() time.Time { return nowTime.Add(time.Hour) }
+	store.deleteStale()
+	assert.Equal(t, 0, len(store.records))
+}
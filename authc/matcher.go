@@ -0,0 +1,142 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type (
+	// A CredentialsMatcher determines whether the submitted Token
+	// credentials matches the credentials stored in the system
+	CredentialsMatcher interface {
+		// CredentialsMatch returns ErrBadCredentials if the Token does not match
+		// the stored credentials. If the stored credentials should be re-encoded,
+		// the upgraded credentials is returned, otherwise it is empty
+		CredentialsMatch(Token, string) (string, error)
+	}
+
+	// A CredentialsLoader is responsible for loading UserDetails
+	// together with its stored credentials by principal
+	CredentialsLoader interface {
+		// LoadCredentials returns ErrUnauthenticated if the principal is not found
+		LoadCredentials(context.Context, string) (UserDetails, string, error)
+	}
+
+	// CredentialsUpdater allowing stored credentials to be
+	// replaced once they have been re-encoded
+	CredentialsUpdater interface {
+		// UpdateCredentials triggered when the stored credentials should be upgraded
+		UpdateCredentials(context.Context, UserDetails, string) error
+	}
+
+	passwordMatcher struct {
+		encoder   PasswordEncoder
+		dummyOnce sync.Once
+		dummy     string
+	}
+
+	// dummyMatcher is implemented by CredentialsMatcher(s) which are able to
+	// verify against dummy credentials, so that an unknown principal costs as
+	// much time as a known one and response timing does not reveal accounts
+	dummyMatcher interface {
+		dummyCredentials() string
+	}
+
+	credentialsRealm struct {
+		loader  CredentialsLoader
+		matcher CredentialsMatcher
+	}
+)
+
+var (
+	_ CredentialsMatcher = (*passwordMatcher)(nil)
+	_ dummyMatcher       = (*passwordMatcher)(nil)
+	_ Realm              = (*credentialsRealm)(nil)
+
+	// ErrBadCredentials is returned when the submitted credentials is incorrect,
+	// it wraps ErrUnauthenticated so that other realm still gets a chance
+	ErrBadCredentials = fmt.Errorf("%w: bad credentials", ErrUnauthenticated)
+)
+
+// NewPasswordMatcher returns a CredentialsMatcher that verifies
+// Token credentials as raw password using the PasswordEncoder
+func NewPasswordMatcher(encoder PasswordEncoder) CredentialsMatcher {
+	return &passwordMatcher{encoder: encoder}
+}
+
+func (m *passwordMatcher) CredentialsMatch(token Token, credentials string) (string, error) {
+	matched, err := m.encoder.Matches(token.Credentials(), credentials)
+	if err != nil {
+		return "", err
+	}
+
+	if !matched {
+		return "", ErrBadCredentials
+	}
+
+	if !m.encoder.UpgradeEncoding(credentials) {
+		return "", nil
+	}
+
+	return m.encoder.Encode(token.Credentials())
+}
+
+func (m *passwordMatcher) dummyCredentials() string {
+	m.dummyOnce.Do(func() {
+		password, err := randomBytes(16)
+		if err != nil {
+			return
+		}
+
+		m.dummy, _ = m.encoder.Encode(b64.EncodeToString(password))
+	})
+
+	return m.dummy
+}
+
+// NewCredentialsRealm returns a Realm that supports UsernamePasswordToken,
+// it loads stored credentials by CredentialsLoader and verifies them by CredentialsMatcher.
+// If the loader implements CredentialsUpdater, upgraded credentials will be saved back.
+// Unknown principals are verified against dummy credentials by NewPasswordMatcher
+func NewCredentialsRealm(loader CredentialsLoader, matcher CredentialsMatcher) Realm {
+	return &credentialsRealm{loader: loader, matcher: matcher}
+}
+
+func (r *credentialsRealm) Supports(token Token) bool {
+	_, ok := token.(*UsernamePasswordToken)
+	return ok
+}
+
+func (r *credentialsRealm) LoadUserDetails(ctx context.Context, token Token) (UserDetails, error) {
+	userDetails, credentials, err := r.loader.LoadCredentials(ctx, token.Principal())
+	if err != nil {
+		if dm, ok := r.matcher.(dummyMatcher); ok && errors.Is(err, ErrUnauthenticated) {
+			// verify anyway so that unknown principals take as long as known ones
+			if dummy := dm.dummyCredentials(); len(dummy) != 0 {
+				_, _ = r.matcher.CredentialsMatch(token, dummy)
+			}
+		}
+
+		return nil, err
+	}
+
+	upgraded, err := r.matcher.CredentialsMatch(token, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	if cu, ok := r.loader.(CredentialsUpdater); ok && len(upgraded) != 0 {
+		// failed upgrade should not fail the login attempt
+		_ = cu.UpdateCredentials(ctx, userDetails, upgraded)
+	}
+
+	return userDetails, nil
+}
+
+func (r *credentialsRealm) Logout(ctx context.Context, userDetails UserDetails) {
+	if la, ok := r.loader.(LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
@@ -0,0 +1,81 @@
+package authc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+type mockLoader struct {
+	credentials map[string]string
+}
+
+func (l *mockLoader) LoadCredentials(_ context.Context, principal string) (UserDetails, string, error) {
+	credentials, ok := l.credentials[principal]
+	if !ok {
+		return nil, "", ErrUnauthenticated
+	}
+
+	return NewBearerToken(principal), credentials, nil
+}
+
+func (l *mockLoader) UpdateCredentials(_ context.Context, userDetails UserDetails, credentials string) error {
+	l.credentials[userDetails.Principal()] = credentials
+	return nil
+}
+
+func TestCredentialsRealm(t *testing.T) {
+	legacy := NewPbkdf2PasswordEncoder(16, 32, 1000)
+	encoder := NewDelegatingPasswordEncoder(BCryptEncoderId, map[string]PasswordEncoder{
+		BCryptEncoderId: NewBCryptPasswordEncoder(bcrypt.MinCost),
+		PBKDF2EncoderId: legacy,
+	})
+
+	encoded, _ := legacy.Encode("123")
+	loader := &mockLoader{credentials: map[string]string{"archer": "{pbkdf2}" + encoded}}
+	realm := NewCredentialsRealm(loader, NewPasswordMatcher(encoder))
+	ac := NewAuthenticator(realm)
+
+	assert.False(t, realm.Supports(NewBearerToken("archer")))
+	assert.True(t, realm.Supports(NewUsernamePasswordToken("archer", "123")))
+
+	_, err := realm.LoadUserDetails(context.TODO(), NewUsernamePasswordToken("archer", "456"))
+	assert.ErrorIs(t, err, ErrBadCredentials)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	_, err = ac.Authenticate(context.TODO(), NewUsernamePasswordToken("nobody", "123"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	user, err := ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	// upgraded to bcrypt
+	assert.Regexp(t, `^\{bcrypt}`, loader.credentials["archer"])
+
+	user, err = ac.Authenticate(context.TODO(), NewUsernamePasswordToken("archer", "123"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+}
+
+type countingEncoder struct {
+	PasswordEncoder
+	matches int
+}
+
+func (e *countingEncoder) Matches(rawPassword string, encodedPassword string) (bool, error) {
+	e.matches++
+	return e.PasswordEncoder.Matches(rawPassword, encodedPassword)
+}
+
+func TestCredentialsRealmUnknownPrincipal(t *testing.T) {
+	encoder := &countingEncoder{PasswordEncoder: NewBCryptPasswordEncoder(bcrypt.MinCost)}
+	realm := NewCredentialsRealm(&mockLoader{credentials: map[string]string{}}, NewPasswordMatcher(encoder))
+
+	// the password is still verified against dummy credentials
+	_, err := realm.LoadUserDetails(context.TODO(), NewUsernamePasswordToken("nobody", "123"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.NotErrorIs(t, err, ErrBadCredentials)
+	assert.Equal(t, 1, encoder.matches)
+}
@@ -0,0 +1,392 @@
+package authc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"strconv"
+	"strings"
+)
+
+type (
+	// A PasswordEncoder is responsible for encoding passwords
+	PasswordEncoder interface {
+		// Encode the raw password
+		Encode(string) (string, error)
+		// Matches returns true if the raw password matches the encoded password
+		Matches(string, string) (bool, error)
+		// UpgradeEncoding returns true if the encoded password should be encoded again
+		UpgradeEncoding(string) bool
+	}
+
+	bcryptPasswordEncoder struct {
+		cost int
+	}
+
+	pbkdf2PasswordEncoder struct {
+		saltLen    int
+		keyLen     int
+		iterations int
+	}
+
+	scryptPasswordEncoder struct {
+		saltLen int
+		keyLen  int
+		n       int
+		r       int
+		p       int
+	}
+
+	delegatingPasswordEncoder struct {
+		idForEncode string
+		encoders    map[string]PasswordEncoder
+	}
+)
+
+var (
+	_ PasswordEncoder = (*bcryptPasswordEncoder)(nil)
+	_ PasswordEncoder = (*pbkdf2PasswordEncoder)(nil)
+	_ PasswordEncoder = (*scryptPasswordEncoder)(nil)
+	_ PasswordEncoder = (*delegatingPasswordEncoder)(nil)
+
+	// ErrMalformedPassword is returned when the encoded password can not be parsed
+	ErrMalformedPassword = errors.New("malformed encoded password")
+	// ErrUnknownEncoder is returned when no PasswordEncoder is mapped to the id
+	ErrUnknownEncoder = errors.New("unknown password encoder")
+
+	b64 = base64.RawStdEncoding
+)
+
+const (
+	// maxPbkdf2Iterations caps the iterations parsed from stored passwords,
+	// so that a tampered hash cannot make a single verification run forever
+	maxPbkdf2Iterations = 10_000_000
+	// maxSCryptN, maxSCryptR and maxSCryptP cap the scrypt parameters parsed
+	// from stored passwords, scrypt needs 128*n*r bytes of memory
+	maxSCryptN = 1 << 20
+	maxSCryptR = 32
+	maxSCryptP = 16
+	// maxKeyLen caps the length of keys parsed from stored passwords
+	maxKeyLen = 1024
+
+	// BCryptEncoderId is the id of bcrypt PasswordEncoder
+	BCryptEncoderId = "bcrypt"
+	// PBKDF2EncoderId is the id of PBKDF2 PasswordEncoder
+	PBKDF2EncoderId = "pbkdf2"
+	// SCryptEncoderId is the id of scrypt PasswordEncoder
+	SCryptEncoderId = "scrypt"
+)
+
+///=====================================
+///		    BCrypt
+///=====================================
+
+// NewBCryptPasswordEncoder returns a PasswordEncoder that uses bcrypt,
+// bcrypt.DefaultCost is used if cost is out of range
+func NewBCryptPasswordEncoder(cost int) PasswordEncoder {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = bcrypt.DefaultCost
+	}
+
+	return &bcryptPasswordEncoder{cost: cost}
+}
+
+func (e *bcryptPasswordEncoder) Encode(rawPassword string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(rawPassword), e.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (e *bcryptPasswordEncoder) Matches(rawPassword string, encodedPassword string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedPassword), []byte(rawPassword))
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%w: %v", ErrMalformedPassword, err)
+}
+
+func (e *bcryptPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedPassword))
+	if err != nil {
+		return false
+	}
+
+	return cost < e.cost
+}
+
+///=====================================
+///		    PBKDF2
+///=====================================
+
+// NewPbkdf2PasswordEncoder returns a PasswordEncoder that uses PBKDF2 with HMAC-SHA256
+func NewPbkdf2PasswordEncoder(saltLen int, keyLen int, iterations int) PasswordEncoder {
+	return &pbkdf2PasswordEncoder{
+		saltLen:    saltLen,
+		keyLen:     keyLen,
+		iterations: iterations,
+	}
+}
+
+// Encode returns a string formatted as $pbkdf2-sha256$i=<iterations>$<salt>$<key>
+func (e *pbkdf2PasswordEncoder) Encode(rawPassword string) (string, error) {
+	salt, err := randomBytes(e.saltLen)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key([]byte(rawPassword), salt, e.iterations, e.keyLen, sha256.New)
+
+	return fmt.Sprintf("$pbkdf2-sha256$i=%d$%s$%s", e.iterations,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (e *pbkdf2PasswordEncoder) Matches(rawPassword string, encodedPassword string) (bool, error) {
+	iterations, salt, key, err := e.decode(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	actual := pbkdf2.Key([]byte(rawPassword), salt, iterations, len(key), sha256.New)
+
+	return subtle.ConstantTimeCompare(key, actual) == 1, nil
+}
+
+func (e *pbkdf2PasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	iterations, salt, key, err := e.decode(encodedPassword)
+	if err != nil {
+		return false
+	}
+
+	return iterations < e.iterations || len(salt) < e.saltLen || len(key) < e.keyLen
+}
+
+func (e *pbkdf2PasswordEncoder) decode(encodedPassword string) (iterations int, salt []byte, key []byte, err error) {
+	parts := strings.Split(encodedPassword, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "pbkdf2-sha256" {
+		err = ErrMalformedPassword
+		return
+	}
+
+	iterations, err = parseParam(parts[2], "i")
+	if err != nil {
+		return
+	}
+
+	if iterations > maxPbkdf2Iterations {
+		err = fmt.Errorf("%w: too many iterations %d", ErrMalformedPassword, iterations)
+		return
+	}
+
+	salt, key, err = decodeSaltAndKey(parts[3], parts[4])
+	return
+}
+
+///=====================================
+///		    SCrypt
+///=====================================
+
+// NewSCryptPasswordEncoder returns a PasswordEncoder that uses scrypt,
+// n is the CPU/memory cost and must be a power of two greater than 1
+func NewSCryptPasswordEncoder(saltLen int, keyLen int, n int, r int, p int) PasswordEncoder {
+	return &scryptPasswordEncoder{
+		saltLen: saltLen,
+		keyLen:  keyLen,
+		n:       n,
+		r:       r,
+		p:       p,
+	}
+}
+
+// Encode returns a string formatted as $scrypt$n=<n>,r=<r>,p=<p>$<salt>$<key>
+func (e *scryptPasswordEncoder) Encode(rawPassword string) (string, error) {
+	salt, err := randomBytes(e.saltLen)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := scrypt.Key([]byte(rawPassword), salt, e.n, e.r, e.p, e.keyLen)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$scrypt$n=%d,r=%d,p=%d$%s$%s", e.n, e.r, e.p,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (e *scryptPasswordEncoder) Matches(rawPassword string, encodedPassword string) (bool, error) {
+	params, salt, key, err := e.decode(encodedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	actual, err := scrypt.Key([]byte(rawPassword), salt, params[0], params[1], params[2], len(key))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrMalformedPassword, err)
+	}
+
+	return subtle.ConstantTimeCompare(key, actual) == 1, nil
+}
+
+func (e *scryptPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	params, salt, key, err := e.decode(encodedPassword)
+	if err != nil {
+		return false
+	}
+
+	return params[0] < e.n || params[1] < e.r || params[2] < e.p ||
+		len(salt) < e.saltLen || len(key) < e.keyLen
+}
+
+func (e *scryptPasswordEncoder) decode(encodedPassword string) (params [3]int, salt []byte, key []byte, err error) {
+	parts := strings.Split(encodedPassword, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "scrypt" {
+		err = ErrMalformedPassword
+		return
+	}
+
+	kvs := strings.Split(parts[2], ",")
+	if len(kvs) != 3 {
+		err = ErrMalformedPassword
+		return
+	}
+
+	for i, name := range []string{"n", "r", "p"} {
+		params[i], err = parseParam(kvs[i], name)
+		if err != nil {
+			return
+		}
+	}
+
+	if params[0] > maxSCryptN || params[1] > maxSCryptR || params[2] > maxSCryptP {
+		err = fmt.Errorf("%w: scrypt parameters n=%d,r=%d,p=%d out of range",
+			ErrMalformedPassword, params[0], params[1], params[2])
+		return
+	}
+
+	salt, key, err = decodeSaltAndKey(parts[3], parts[4])
+	return
+}
+
+///=====================================
+///		    Delegating
+///=====================================
+
+// NewDelegatingPasswordEncoder returns a PasswordEncoder that delegates to another
+// PasswordEncoder based on a prefixed identifier, e.g. {bcrypt}$2a$10$...
+// New passwords are always encoded by the PasswordEncoder mapped to idForEncode
+func NewDelegatingPasswordEncoder(idForEncode string, encoders map[string]PasswordEncoder) PasswordEncoder {
+	if _, ok := encoders[idForEncode]; !ok {
+		panic(fmt.Sprintf("no PasswordEncoder mapped to id %q", idForEncode))
+	}
+
+	return &delegatingPasswordEncoder{
+		idForEncode: idForEncode,
+		encoders:    encoders,
+	}
+}
+
+// NewDefaultPasswordEncoder returns a delegating PasswordEncoder that
+// encodes with bcrypt and understands all builtin encoders
+func NewDefaultPasswordEncoder() PasswordEncoder {
+	return NewDelegatingPasswordEncoder(BCryptEncoderId, map[string]PasswordEncoder{
+		BCryptEncoderId: NewBCryptPasswordEncoder(bcrypt.DefaultCost),
+		PBKDF2EncoderId: NewPbkdf2PasswordEncoder(16, 32, 310000),
+		SCryptEncoderId: NewSCryptPasswordEncoder(16, 32, 1<<15, 8, 1),
+	})
+}
+
+func (e *delegatingPasswordEncoder) Encode(rawPassword string) (string, error) {
+	encoded, err := e.encoders[e.idForEncode].Encode(rawPassword)
+	if err != nil {
+		return "", err
+	}
+
+	return "{" + e.idForEncode + "}" + encoded, nil
+}
+
+func (e *delegatingPasswordEncoder) Matches(rawPassword string, encodedPassword string) (bool, error) {
+	id, encoded := extractId(encodedPassword)
+	encoder, ok := e.encoders[id]
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrUnknownEncoder, id)
+	}
+
+	return encoder.Matches(rawPassword, encoded)
+}
+
+func (e *delegatingPasswordEncoder) UpgradeEncoding(encodedPassword string) bool {
+	id, encoded := extractId(encodedPassword)
+	if id != e.idForEncode {
+		return true
+	}
+
+	return e.encoders[id].UpgradeEncoding(encoded)
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func extractId(encodedPassword string) (string, string) {
+	if !strings.HasPrefix(encodedPassword, "{") {
+		return "", encodedPassword
+	}
+
+	end := strings.Index(encodedPassword, "}")
+	if end < 0 {
+		return "", encodedPassword
+	}
+
+	return encodedPassword[1:end], encodedPassword[end+1:]
+}
+
+func parseParam(kv string, name string) (int, error) {
+	prefix := name + "="
+	if !strings.HasPrefix(kv, prefix) {
+		return 0, ErrMalformedPassword
+	}
+
+	v, err := strconv.Atoi(kv[len(prefix):])
+	if err != nil || v <= 0 {
+		return 0, ErrMalformedPassword
+	}
+
+	return v, nil
+}
+
+func decodeSaltAndKey(encodedSalt string, encodedKey string) ([]byte, []byte, error) {
+	salt, err := b64.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, nil, ErrMalformedPassword
+	}
+
+	key, err := b64.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 || len(key) > maxKeyLen {
+		return nil, nil, ErrMalformedPassword
+	}
+
+	return salt, key, nil
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
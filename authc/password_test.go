@@ -0,0 +1,103 @@
+package authc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestBCryptEncoder(t *testing.T) {
+	encoder := NewBCryptPasswordEncoder(bcrypt.MinCost)
+
+	encoded, err := encoder.Encode("123")
+	assert.NoError(t, err)
+
+	matched, err := encoder.Matches("123", encoded)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = encoder.Matches("456", encoded)
+	assert.NoError(t, err)
+	assert.False(t, matched)
+
+	assert.False(t, encoder.UpgradeEncoding(encoded))
+	assert.True(t, NewBCryptPasswordEncoder(bcrypt.MinCost+1).UpgradeEncoding(encoded))
+}
+
+func TestPbkdf2Encoder(t *testing.T) {
+	encoder := NewPbkdf2PasswordEncoder(16, 32, 1000)
+
+	encoded, err := encoder.Encode("123")
+	assert.NoError(t, err)
+
+	matched, err := encoder.Matches("123", encoded)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = encoder.Matches("456", encoded)
+	assert.NoError(t, err)
+	assert.False(t, matched)
+
+	assert.False(t, encoder.UpgradeEncoding(encoded))
+	assert.True(t, NewPbkdf2PasswordEncoder(16, 32, 2000).UpgradeEncoding(encoded))
+
+	_, err = encoder.Matches("123", "$pbkdf2-sha256$i=x$abc$def")
+	assert.ErrorIs(t, err, ErrMalformedPassword)
+
+	_, err = encoder.Matches("123", "$pbkdf2-sha256$i=2000000000$abc$def")
+	assert.ErrorIs(t, err, ErrMalformedPassword)
+}
+
+func TestSCryptEncoder(t *testing.T) {
+	encoder := NewSCryptPasswordEncoder(16, 32, 16, 8, 1)
+
+	encoded, err := encoder.Encode("123")
+	assert.NoError(t, err)
+
+	matched, err := encoder.Matches("123", encoded)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	matched, err = encoder.Matches("456", encoded)
+	assert.NoError(t, err)
+	assert.False(t, matched)
+
+	assert.False(t, encoder.UpgradeEncoding(encoded))
+	assert.True(t, NewSCryptPasswordEncoder(16, 32, 32, 8, 1).UpgradeEncoding(encoded))
+
+	_, err = encoder.Matches("123", "$scrypt$n=16$abc$def")
+	assert.ErrorIs(t, err, ErrMalformedPassword)
+
+	for _, params := range []string{"n=1073741824,r=8,p=1", "n=16,r=1024,p=1", "n=16,r=8,p=1024"} {
+		_, err = encoder.Matches("123", "$scrypt$"+params+"$abc$def")
+		assert.ErrorIs(t, err, ErrMalformedPassword, params)
+	}
+}
+
+func TestDelegatingEncoder(t *testing.T) {
+	pbkdf2 := NewPbkdf2PasswordEncoder(16, 32, 1000)
+	legacy := NewDelegatingPasswordEncoder(PBKDF2EncoderId, map[string]PasswordEncoder{
+		PBKDF2EncoderId: pbkdf2,
+	})
+	encoder := NewDelegatingPasswordEncoder(BCryptEncoderId, map[string]PasswordEncoder{
+		BCryptEncoderId: NewBCryptPasswordEncoder(bcrypt.MinCost),
+		PBKDF2EncoderId: pbkdf2,
+	})
+
+	encoded, err := legacy.Encode("123")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\{pbkdf2}\$pbkdf2-sha256\$`, encoded)
+
+	matched, err := encoder.Matches("123", encoded)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+	assert.True(t, encoder.UpgradeEncoding(encoded))
+
+	encoded, err = encoder.Encode("123")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^\{bcrypt}\$2a\$`, encoded)
+	assert.False(t, encoder.UpgradeEncoding(encoded))
+
+	_, err = encoder.Matches("123", "{noop}123")
+	assert.ErrorIs(t, err, ErrUnknownEncoder)
+}
@@ -0,0 +1,421 @@
+package authc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RememberMeToken is exchanged for a new session, it is decoded
+	// from the long-lived cookie by ParseRememberMeToken
+	RememberMeToken struct {
+		series string
+		value  string
+	}
+
+	// RememberMeUserDetails is the UserDetails returned by RememberMeRealm,
+	// it carries the rotated cookie value which must replace the cookie of
+	// the client, use As to retrieve it
+	RememberMeUserDetails struct {
+		UserDetails
+		renewed string
+	}
+
+	// PersistentToken is a remember-me series as persisted by PersistentTokenStore,
+	// only the hash of the token value is kept so a leaked store cannot be replayed
+	PersistentToken struct {
+		// Series identifies the remember-me login, it never changes
+		Series string
+		// Principal the series belongs to
+		Principal string
+		// TokenHash is the hash of the current token value, it rotates on every use
+		TokenHash string
+		// LastUsed is the last time the series was used
+		LastUsed time.Time
+	}
+
+	// A PersistentTokenStore keeps track of remember-me series
+	PersistentTokenStore interface {
+		// Create saves a new series
+		Create(context.Context, PersistentToken) error
+		// Load returns the series or nil if not found
+		Load(context.Context, string) (*PersistentToken, error)
+		// Update rotates the token hash of the series from the previous hash to the
+		// new one atomically, it returns false without updating if the series is not
+		// found or its token hash is no longer the previous one
+		Update(ctx context.Context, series string, previous string, next string, lastUsed time.Time) (bool, error)
+		// Remove removes the series
+		Remove(context.Context, string) error
+		// RemoveAll removes every series of the principal
+		RemoveAll(context.Context, string) error
+	}
+
+	// RememberMeOption can be used to customize RememberMeOptions
+	RememberMeOption func(*RememberMeOptions)
+
+	// RememberMeOptions contains config attribute that can
+	// affect how remember-me tokens are issued
+	RememberMeOptions struct {
+		// Validity controls how long a series can be left unused
+		Validity time.Duration
+		// TokenLength is the number of random bytes of series and token values
+		TokenLength int
+	}
+
+	// MapPersistentTokenStore is a PersistentTokenStore backed by a map
+	MapPersistentTokenStore struct {
+		mu     sync.Mutex
+		series map[string]PersistentToken
+	}
+
+	// RememberMeRealm authenticates RememberMeToken following the "series + token"
+	// design, the token value rotates on every use, and if an old value is presented
+	// again, the cookie is assumed stolen and every series of the principal is removed.
+	// The UserDetails is a RememberMeUserDetails carrying the rotated cookie value
+	RememberMeRealm struct {
+		store  PersistentTokenStore
+		loader CredentialsLoader
+		opt    *RememberMeOptions
+	}
+)
+
+var (
+	_ Token                = (*RememberMeToken)(nil)
+	_ PersistentTokenStore = (*MapPersistentTokenStore)(nil)
+	_ Realm                = (*RememberMeRealm)(nil)
+	_ LogoutAware          = (*RememberMeRealm)(nil)
+	_ UserDetails          = (*RememberMeUserDetails)(nil)
+	_ AccountStatus        = (*RememberMeUserDetails)(nil)
+
+	// ErrRememberMeExpired is returned when the series has been left unused for too long
+	ErrRememberMeExpired = fmt.Errorf("%w: remember-me expired", ErrUnauthenticated)
+	// ErrRememberMeTheft is returned when an old token value of the series is presented
+	ErrRememberMeTheft = fmt.Errorf("%w: remember-me theft", ErrUnauthenticated)
+)
+
+var defaultRememberMeOptions = RememberMeOptions{
+	Validity:    14 * 24 * time.Hour,
+	TokenLength: 16,
+}
+
+func WithRememberMeValidity(validity time.Duration) RememberMeOption {
+	return func(opt *RememberMeOptions) {
+		if validity > 0 {
+			opt.Validity = validity
+		}
+	}
+}
+
+func WithRememberMeTokenLength(length int) RememberMeOption {
+	return func(opt *RememberMeOptions) {
+		if length > 0 {
+			opt.TokenLength = length
+		}
+	}
+}
+
+///=====================================
+///		    RememberMeToken
+///=====================================
+
+func NewRememberMeToken(series string, value string) Token {
+	return &RememberMeToken{series: series, value: value}
+}
+
+// ParseRememberMeToken decodes the cookie value created by RememberMeRealm
+func ParseRememberMeToken(cookie string) (Token, error) {
+	series, value, ok := strings.Cut(cookie, ":")
+	if !ok || len(series) == 0 || len(value) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return NewRememberMeToken(series, value), nil
+}
+
+// Principal returns the series since the principal is unknown before authentication
+func (rt *RememberMeToken) Principal() string {
+	return rt.series
+}
+
+func (rt *RememberMeToken) Credentials() string {
+	return rt.value
+}
+
+// String returns the cookie value
+func (rt *RememberMeToken) String() string {
+	return rt.series + ":" + rt.value
+}
+
+///=====================================
+///		    RememberMeUserDetails
+///=====================================
+
+// Renewed returns the rotated cookie value, the
+// client must replace its cookie with it
+func (u *RememberMeUserDetails) Renewed() string {
+	return u.renewed
+}
+
+// Unwrap returns the UserDetails loaded by CredentialsLoader
+func (u *RememberMeUserDetails) Unwrap() UserDetails {
+	return u.UserDetails
+}
+
+func (u *RememberMeUserDetails) Enabled() bool {
+	s, ok := u.UserDetails.(AccountStatus)
+	return !ok || s.Enabled()
+}
+
+func (u *RememberMeUserDetails) Locked() bool {
+	s, ok := u.UserDetails.(AccountStatus)
+	return ok && s.Locked()
+}
+
+func (u *RememberMeUserDetails) AccountExpired() bool {
+	s, ok := u.UserDetails.(AccountStatus)
+	return ok && s.AccountExpired()
+}
+
+func (u *RememberMeUserDetails) CredentialsExpired() bool {
+	s, ok := u.UserDetails.(AccountStatus)
+	return ok && s.CredentialsExpired()
+}
+
+///=====================================
+///		    RememberMeRealm
+///=====================================
+
+// NewRememberMeRealm returns a Realm that authenticates RememberMeToken,
+// the UserDetails is loaded by the principal of the series
+func NewRememberMeRealm(store PersistentTokenStore, loader CredentialsLoader, opts ...RememberMeOption) *RememberMeRealm {
+	opt := defaultRememberMeOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &RememberMeRealm{
+		store:  store,
+		loader: loader,
+		opt:    &opt,
+	}
+}
+
+func (r *RememberMeRealm) Supports(token Token) bool {
+	_, ok := token.(*RememberMeToken)
+	return ok
+}
+
+func (r *RememberMeRealm) LoadUserDetails(ctx context.Context, token Token) (UserDetails, error) {
+	rt, ok := token.(*RememberMeToken)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	pt, err := r.store.Load(ctx, rt.series)
+	if err != nil {
+		return nil, err
+	}
+
+	if pt == nil {
+		return nil, ErrBadCredentials
+	}
+
+	hash := hashRememberMe(rt.value)
+	if subtle.ConstantTimeCompare([]byte(pt.TokenHash), []byte(hash)) != 1 {
+		// the series is valid but the value has been used already,
+		// someone else must have presented it before
+		return nil, r.theft(ctx, pt.Principal)
+	}
+
+	if pt.LastUsed.Add(r.opt.Validity).Before(nowFunc()) {
+		if err = r.store.Remove(ctx, pt.Series); err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRememberMeExpired
+	}
+
+	userDetails, _, err := r.loader.LoadCredentials(ctx, pt.Principal)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := r.randomValue()
+	if err != nil {
+		return nil, err
+	}
+
+	swapped, err := r.store.Update(ctx, pt.Series, hash, hashRememberMe(value), nowFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	// the same value has been presented concurrently and won the race
+	if !swapped {
+		return nil, r.theft(ctx, pt.Principal)
+	}
+
+	return &RememberMeUserDetails{UserDetails: userDetails, renewed: pt.Series + ":" + value}, nil
+}
+
+// Remember starts a new series for the principal, usually after a successful
+// password login, and returns the cookie value to be sent to the client
+func (r *RememberMeRealm) Remember(ctx context.Context, principal string) (string, error) {
+	series, err := r.randomValue()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := r.randomValue()
+	if err != nil {
+		return "", err
+	}
+
+	err = r.store.Create(ctx, PersistentToken{
+		Series:    series,
+		Principal: principal,
+		TokenHash: hashRememberMe(value),
+		LastUsed:  nowFunc(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return series + ":" + value, nil
+}
+
+// Validity returns how long a series can be left unused, which
+// is usually the max age of the remember-me cookie
+func (r *RememberMeRealm) Validity() time.Duration {
+	return r.opt.Validity
+}
+
+// Logout removes every series of the user
+func (r *RememberMeRealm) Logout(ctx context.Context, userDetails UserDetails) {
+	_ = r.store.RemoveAll(ctx, userDetails.Principal())
+}
+
+// theft removes every series of the principal
+func (r *RememberMeRealm) theft(ctx context.Context, principal string) error {
+	if err := r.store.RemoveAll(ctx, principal); err != nil {
+		return err
+	}
+
+	return ErrRememberMeTheft
+}
+
+func (r *RememberMeRealm) randomValue() (string, error) {
+	b := make([]byte, r.opt.TokenLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRememberMe(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+///=====================================
+///		    MapPersistentTokenStore
+///=====================================
+
+func NewPersistentTokenStore() *MapPersistentTokenStore {
+	return &MapPersistentTokenStore{series: make(map[string]PersistentToken)}
+}
+
+func (s *MapPersistentTokenStore) Create(ctx context.Context, token PersistentToken) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series[token.Series] = token
+	return nil
+}
+
+func (s *MapPersistentTokenStore) Load(ctx context.Context, series string) (*PersistentToken, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.series[series]
+	if !ok {
+		return nil, nil
+	}
+
+	return &token, nil
+}
+
+func (s *MapPersistentTokenStore) Update(ctx context.Context, series string, previous string, next string, lastUsed time.Time) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.series[series]
+	if !ok || token.TokenHash != previous {
+		return false, nil
+	}
+
+	token.TokenHash = next
+	token.LastUsed = lastUsed
+	s.series[series] = token
+	return true, nil
+}
+
+func (s *MapPersistentTokenStore) Remove(ctx context.Context, series string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.series, series)
+	return nil
+}
+
+func (s *MapPersistentTokenStore) RemoveAll(ctx context.Context, principal string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for series, token := range s.series {
+		if token.Principal == principal {
+			delete(s.series, series)
+		}
+	}
+
+	return nil
+}
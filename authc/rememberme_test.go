@@ -0,0 +1,146 @@
+package authc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRememberMeRotation(t *testing.T) {
+	store := NewPersistentTokenStore()
+	loader := &mockLoader{credentials: map[string]string{"archer": ""}}
+	realm := NewRememberMeRealm(store, loader)
+	ac := NewAuthenticator(realm)
+
+	cookie, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	token, err := ParseRememberMeToken(cookie)
+	assert.NoError(t, err)
+	assert.True(t, realm.Supports(token))
+	assert.Equal(t, cookie, token.(*RememberMeToken).String())
+
+	user, err := ac.Authenticate(context.TODO(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	rud, ok := As[*RememberMeUserDetails](user)
+	assert.True(t, ok)
+	renewed := rud.Renewed()
+	assert.NotEmpty(t, renewed)
+	assert.NotEqual(t, cookie, renewed)
+
+	// the series is kept while the value rotates
+	next, err := ParseRememberMeToken(renewed)
+	assert.NoError(t, err)
+	assert.Equal(t, token.Principal(), next.Principal())
+
+	user, err = ac.Authenticate(context.TODO(), next)
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	_, err = ParseRememberMeToken("malformed")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = ac.Authenticate(context.TODO(), NewRememberMeToken("unknown", "value"))
+	assert.ErrorIs(t, err, ErrBadCredentials)
+}
+
+func TestRememberMeTheft(t *testing.T) {
+	store := NewPersistentTokenStore()
+	loader := &mockLoader{credentials: map[string]string{"archer": ""}}
+	realm := NewRememberMeRealm(store, loader)
+	ac := NewAuthenticator(realm)
+
+	stolen, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+	other, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	// the attacker uses the stolen cookie first
+	token, _ := ParseRememberMeToken(stolen)
+	_, err = ac.Authenticate(context.TODO(), token)
+	assert.NoError(t, err)
+
+	// then the victim presents the old value
+	token, _ = ParseRememberMeToken(stolen)
+	_, err = ac.Authenticate(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrRememberMeTheft)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	// every series of the principal is invalidated
+	token, _ = ParseRememberMeToken(other)
+	_, err = ac.Authenticate(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrBadCredentials)
+}
+
+// racingStore lets another request rotate the series right after it is loaded
+type racingStore struct {
+	*MapPersistentTokenStore
+}
+
+func (s *racingStore) Load(ctx context.Context, series string) (*PersistentToken, error) {
+	pt, err := s.MapPersistentTokenStore.Load(ctx, series)
+	if pt != nil {
+		_, _ = s.MapPersistentTokenStore.Update(ctx, series, pt.TokenHash, "rotated", nowFunc())
+	}
+
+	return pt, err
+}
+
+func TestRememberMeConcurrentUse(t *testing.T) {
+	store := &racingStore{MapPersistentTokenStore: NewPersistentTokenStore()}
+	loader := &mockLoader{credentials: map[string]string{"archer": ""}}
+	realm := NewRememberMeRealm(store, loader)
+
+	cookie, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	// the same value is presented twice, only one of them may win the swap
+	token, _ := ParseRememberMeToken(cookie)
+	_, err = realm.LoadUserDetails(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrRememberMeTheft)
+
+	pt, err := store.MapPersistentTokenStore.Load(context.TODO(), token.Principal())
+	assert.NoError(t, err)
+	assert.Nil(t, pt)
+}
+
+func TestRememberMeExpired(t *testing.T) {
+	nowTime := time.Now()
+	defer func() { nowFunc = time.Now }()
+	nowFunc = func() time.Time { return nowTime }
+
+	store := NewPersistentTokenStore()
+	loader := &mockLoader{credentials: map[string]string{"archer": ""}}
+	realm := NewRememberMeRealm(store, loader, WithRememberMeValidity(time.Hour))
+	ac := NewAuthenticator(realm)
+
+	cookie, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	nowFunc = func() time.Time { return nowTime.Add(2 * time.Hour) }
+	token, _ := ParseRememberMeToken(cookie)
+	_, err = ac.Authenticate(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrRememberMeExpired)
+
+	pt, err := store.Load(context.TODO(), token.Principal())
+	assert.NoError(t, err)
+	assert.Nil(t, pt)
+}
+
+func TestRememberMeLogout(t *testing.T) {
+	store := NewPersistentTokenStore()
+	loader := &mockLoader{credentials: map[string]string{"archer": ""}}
+	realm := NewRememberMeRealm(store, loader)
+
+	cookie, err := realm.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	realm.Logout(context.TODO(), NewBearerToken("archer"))
+
+	token, _ := ParseRememberMeToken(cookie)
+	_, err = realm.LoadUserDetails(context.TODO(), token)
+	assert.ErrorIs(t, err, ErrBadCredentials)
+}
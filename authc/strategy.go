@@ -0,0 +1,296 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	// A Strategy decides how the results of multiple realms are combined
+	Strategy interface {
+		// Authenticate consults realms that support the Token in order
+		Authenticate(context.Context, []Realm, Token) (UserDetails, error)
+	}
+
+	// RealmError records why a Realm rejected the Token
+	RealmError struct {
+		// Realm that rejected the Token
+		Realm Realm
+		// Err is the reason
+		Err error
+	}
+
+	// AggregateError is returned when realms rejected the Token,
+	// errors.Is and errors.As report true if any of the reasons matches
+	AggregateError struct {
+		// Errors holds the reason of every Realm
+		Errors []*RealmError
+	}
+
+	// CompositeUserDetails merges UserDetails loaded by multiple realms for
+	// the same Principal, use As to retrieve the UserDetails of a concrete type
+	CompositeUserDetails struct {
+		details []UserDetails
+	}
+
+	firstSuccessfulStrategy struct {
+		lenient bool
+	}
+
+	atLeastOneSuccessfulStrategy struct {
+	}
+
+	allSuccessfulStrategy struct {
+	}
+)
+
+var (
+	_ Strategy      = (*firstSuccessfulStrategy)(nil)
+	_ Strategy      = (*atLeastOneSuccessfulStrategy)(nil)
+	_ Strategy      = (*allSuccessfulStrategy)(nil)
+	_ UserDetails   = (*CompositeUserDetails)(nil)
+	_ AccountStatus = (*CompositeUserDetails)(nil)
+
+	// FirstSuccessful returns the UserDetails of the first realm that succeeds,
+	// later realms are only consulted if the previous realm failed with
+	// ErrUnauthenticated, other errors such as a database outage fail fast
+	FirstSuccessful Strategy = &firstSuccessfulStrategy{}
+	// FirstSuccessfulLenient is like FirstSuccessful but consults later
+	// realms whatever the previous realm failed with
+	FirstSuccessfulLenient Strategy = &firstSuccessfulStrategy{lenient: true}
+	// AtLeastOneSuccessful consults all realms and merges the UserDetails of
+	// the successful ones, it fails only if no realm succeeds
+	AtLeastOneSuccessful Strategy = &atLeastOneSuccessfulStrategy{}
+	// AllSuccessful consults all realms and merges their UserDetails,
+	// it fails if any realm fails
+	AllSuccessful Strategy = &allSuccessfulStrategy{}
+
+	// ErrPrincipalMismatch is returned when realms loaded UserDetails of different principals
+	ErrPrincipalMismatch = fmt.Errorf("%w: realms loaded different principals", ErrUnauthenticated)
+)
+
+func (s *firstSuccessfulStrategy) Authenticate(ctx context.Context, realms []Realm, token Token) (UserDetails, error) {
+	var errs []*RealmError
+	for _, r := range realms {
+		if !r.Supports(token) {
+			continue
+		}
+
+		user, err := r.LoadUserDetails(ctx, token)
+		if err == nil {
+			return user, nil
+		}
+
+		errs = append(errs, &RealmError{Realm: r, Err: err})
+		if !s.lenient && !errors.Is(err, ErrUnauthenticated) {
+			break
+		}
+	}
+
+	return nil, aggregate(errs)
+}
+
+func (s *atLeastOneSuccessfulStrategy) Authenticate(ctx context.Context, realms []Realm, token Token) (UserDetails, error) {
+	var (
+		details []UserDetails
+		errs    []*RealmError
+	)
+	for _, r := range realms {
+		if !r.Supports(token) {
+			continue
+		}
+
+		user, err := r.LoadUserDetails(ctx, token)
+		if err != nil {
+			errs = append(errs, &RealmError{Realm: r, Err: err})
+			continue
+		}
+
+		details = append(details, user)
+	}
+
+	if len(details) == 0 {
+		return nil, aggregate(errs)
+	}
+
+	return merge(details)
+}
+
+func (s *allSuccessfulStrategy) Authenticate(ctx context.Context, realms []Realm, token Token) (UserDetails, error) {
+	var (
+		details []UserDetails
+		errs    []*RealmError
+	)
+	for _, r := range realms {
+		if !r.Supports(token) {
+			continue
+		}
+
+		user, err := r.LoadUserDetails(ctx, token)
+		if err != nil {
+			errs = append(errs, &RealmError{Realm: r, Err: err})
+			continue
+		}
+
+		details = append(details, user)
+	}
+
+	if len(errs) != 0 || len(details) == 0 {
+		return nil, aggregate(errs)
+	}
+
+	return merge(details)
+}
+
+///=====================================
+///		    Errors
+///=====================================
+
+func (e *RealmError) Error() string {
+	return RealmName(e.Realm) + ": " + e.Err.Error()
+}
+
+func (e *RealmError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AggregateError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, re := range e.Errors {
+		msgs = append(msgs, re.Error())
+	}
+
+	return "rejected by realms: " + strings.Join(msgs, "; ")
+}
+
+func (e *AggregateError) Is(target error) bool {
+	for _, re := range e.Errors {
+		if errors.Is(re, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *AggregateError) As(target any) bool {
+	for _, re := range e.Errors {
+		if errors.As(re, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RealmName returns the name of the Realm, realms can
+// implement a Name() string method to override it
+func RealmName(realm Realm) string {
+	if named, ok := realm.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+
+	return fmt.Sprintf("%T", realm)
+}
+
+///=====================================
+///		    CompositeUserDetails
+///=====================================
+
+func (u *CompositeUserDetails) Principal() string {
+	return u.details[0].Principal()
+}
+
+// UserDetails returns the merged UserDetails in realm order
+func (u *CompositeUserDetails) UserDetails() []UserDetails {
+	return u.details
+}
+
+// Unwrap is an alias of UserDetails
+func (u *CompositeUserDetails) Unwrap() []UserDetails {
+	return u.details
+}
+
+func (u *CompositeUserDetails) Enabled() bool {
+	return u.all(func(s AccountStatus) bool { return s.Enabled() })
+}
+
+func (u *CompositeUserDetails) Locked() bool {
+	return !u.all(func(s AccountStatus) bool { return !s.Locked() })
+}
+
+func (u *CompositeUserDetails) AccountExpired() bool {
+	return !u.all(func(s AccountStatus) bool { return !s.AccountExpired() })
+}
+
+func (u *CompositeUserDetails) CredentialsExpired() bool {
+	return !u.all(func(s AccountStatus) bool { return !s.CredentialsExpired() })
+}
+
+func (u *CompositeUserDetails) all(predicate func(AccountStatus) bool) bool {
+	for _, ud := range u.details {
+		if s, ok := ud.(AccountStatus); ok && !predicate(s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// As returns the UserDetails of type T, which is either the UserDetails itself,
+// one wrapped by an Unwrap() UserDetails method, or the first one of that type
+// merged in a CompositeUserDetails. Realms use it instead of type assertions
+// so that they work with any Strategy
+func As[T any](userDetails UserDetails) (T, bool) {
+	var zero T
+	for userDetails != nil {
+		if t, ok := userDetails.(T); ok {
+			return t, true
+		}
+
+		switch ud := userDetails.(type) {
+		case *CompositeUserDetails:
+			for _, merged := range ud.details {
+				if t, ok := As[T](merged); ok {
+					return t, true
+				}
+			}
+
+			return zero, false
+		case interface{ Unwrap() UserDetails }:
+			userDetails = ud.Unwrap()
+		default:
+			return zero, false
+		}
+	}
+
+	return zero, false
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func merge(details []UserDetails) (UserDetails, error) {
+	if len(details) == 1 {
+		return details[0], nil
+	}
+
+	for _, ud := range details[1:] {
+		if ud.Principal() != details[0].Principal() {
+			return nil, ErrPrincipalMismatch
+		}
+	}
+
+	return &CompositeUserDetails{details: details}, nil
+}
+
+func aggregate(errs []*RealmError) error {
+	if len(errs) == 0 {
+		return ErrUnauthenticated
+	}
+
+	return &AggregateError{Errors: errs}
+}
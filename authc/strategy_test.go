@@ -0,0 +1,130 @@
+package authc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+type namedRealm struct {
+	mockRealm
+	name string
+}
+
+func (r *namedRealm) Name() string {
+	return r.name
+}
+
+func newNamedRealm(name string, user UserDetails, err error) *namedRealm {
+	r := &namedRealm{name: name}
+	r.On("Supports", mock.Anything).Return(true)
+	r.On("LoadUserDetails", mock.Anything, mock.Anything).Return(user, err)
+	return r
+}
+
+func TestFirstSuccessful(t *testing.T) {
+	errDown := errors.New("directory down")
+	ldap := newNamedRealm("ldap", nil, errDown)
+	local := newNamedRealm("local", NewBearerToken("archer"), nil)
+
+	// fails fast on errors other than ErrUnauthenticated
+	ac := NewAuthenticator(ldap, local)
+	_, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, errDown)
+	assert.NotErrorIs(t, err, ErrUnauthenticated)
+	local.AssertNotCalled(t, "LoadUserDetails", mock.Anything, mock.Anything)
+
+	ac = NewAuthenticator(newNamedRealm("failed", nil, ErrBadCredentials), local)
+	user, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	unused := newNamedRealm("unused", nil, errDown)
+	ac = NewAuthenticator(local, unused)
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	unused.AssertNotCalled(t, "LoadUserDetails", mock.Anything, mock.Anything)
+}
+
+func TestFirstSuccessfulLenient(t *testing.T) {
+	errDown := errors.New("directory down")
+	ldap := newNamedRealm("ldap", nil, errDown)
+	local := newNamedRealm("local", NewBearerToken("archer"), nil)
+
+	ac := NewBuilder().Realms(ldap, local).Strategy(FirstSuccessfulLenient).Build()
+	user, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	ac = NewBuilder().Realms(ldap, newNamedRealm("local", nil, ErrBadCredentials)).
+		Strategy(FirstSuccessfulLenient).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, errDown)
+	assert.ErrorIs(t, err, ErrBadCredentials)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.EqualError(t, err, "rejected by realms: ldap: directory down; local: unauthenticated: bad credentials")
+
+	var aggErr *AggregateError
+	assert.ErrorAs(t, err, &aggErr)
+	assert.Equal(t, 2, len(aggErr.Errors))
+	assert.Equal(t, ldap, aggErr.Errors[0].Realm)
+}
+
+func TestAtLeastOneSuccessful(t *testing.T) {
+	ldap := newNamedRealm("ldap", NewBearerToken("archer"), nil)
+	local := newNamedRealm("local", NewUsernamePasswordToken("archer", ""), nil)
+	failed := newNamedRealm("failed", nil, ErrUnauthenticated)
+
+	ac := NewBuilder().Realms(ldap, failed, local).Strategy(AtLeastOneSuccessful).Build()
+	user, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", user.Principal())
+
+	composite, ok := user.(*CompositeUserDetails)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(composite.UserDetails()))
+	failed.AssertCalled(t, "LoadUserDetails", mock.Anything, mock.Anything)
+
+	token, ok := As[*BearerToken](user)
+	assert.True(t, ok)
+	assert.Equal(t, "archer", token.Principal())
+	_, ok = As[*mockAccount](user)
+	assert.False(t, ok)
+
+	ac = NewBuilder().Realms(failed).Strategy(AtLeastOneSuccessful).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestAllSuccessful(t *testing.T) {
+	ldap := newNamedRealm("ldap", NewBearerToken("archer"), nil)
+	local := newNamedRealm("local", NewBearerToken("archer"), nil)
+	failed := newNamedRealm("failed", nil, ErrUnauthenticated)
+
+	ac := NewBuilder().Realms(ldap, local).Strategy(AllSuccessful).Build()
+	user, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(user.(*CompositeUserDetails).UserDetails()))
+
+	ac = NewBuilder().Realms(ldap, failed, local).Strategy(AllSuccessful).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.EqualError(t, err, "rejected by realms: failed: unauthenticated")
+
+	ac = NewBuilder().Realms(ldap, newNamedRealm("other", NewBearerToken("guest"), nil)).
+		Strategy(AllSuccessful).Build()
+	_, err = ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, ErrPrincipalMismatch)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+}
+
+func TestCompositeAccountStatus(t *testing.T) {
+	ldap := newNamedRealm("ldap", &mockAccount{principal: "archer"}, nil)
+	local := newNamedRealm("local", &mockAccount{principal: "archer", disabled: true}, nil)
+
+	ac := NewBuilder().Realms(ldap, local).Strategy(AllSuccessful).Build()
+	_, err := ac.Authenticate(context.TODO(), NewBearerToken("archer"))
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+}
@@ -9,6 +9,11 @@ type (
 		username string
 		password string
 	}
+
+	OneTimePasswordToken struct {
+		principal string
+		code      string
+	}
 )
 
 var _ Token = (*BearerToken)(nil)
@@ -38,3 +43,17 @@ func (upt *UsernamePasswordToken) Principal() string {
 func (upt *UsernamePasswordToken) Credentials() string {
 	return upt.password
 }
+
+var _ Token = (*OneTimePasswordToken)(nil)
+
+func NewOneTimePasswordToken(principal string, code string) Token {
+	return &OneTimePasswordToken{principal: principal, code: code}
+}
+
+func (ot *OneTimePasswordToken) Principal() string {
+	return ot.principal
+}
+
+func (ot *OneTimePasswordToken) Credentials() string {
+	return ot.code
+}
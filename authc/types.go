@@ -18,6 +18,14 @@ type (
 		Principal() string
 	}
 
+	// Stateless is an optional interface of UserDetails loaded from a
+	// self-contained Token, e.g. a JWT, which is presented with every
+	// request, such UserDetails are not backed by a session
+	Stateless interface {
+		// Stateless returns true if no session is required
+		Stateless() bool
+	}
+
 	// A Realm is responsible for loading UserDetails
 	Realm interface {
 		// Supports returns true if the specified Token can be handled by this Realm, false otherwise
@@ -33,6 +41,15 @@ type (
 		Logout(context.Context, UserDetails)
 	}
 
+	// A SecondFactor is responsible for the second authentication step,
+	// the user is partially authenticated until it is verified
+	SecondFactor interface {
+		// Required returns true if the user must pass the second authentication step
+		Required(context.Context, UserDetails) (bool, error)
+		// Verify checks the second-factor Token submitted by the user
+		Verify(context.Context, UserDetails, Token) error
+	}
+
 	// An Authenticator is responsible for authenticating accounts in an application
 	Authenticator interface {
 		// Authenticate a user based on the submitted Token
@@ -0,0 +1,335 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"strings"
+	"sync"
+)
+
+type (
+	// Attributes are named values used by policies
+	Attributes map[string]any
+
+	// Attributed is an optional interface of authc.UserDetails and resources
+	// which exposes their attributes to policies
+	Attributed interface {
+		// Attributes returns the attributes
+		Attributes() map[string]any
+	}
+
+	// AccessRequest is evaluated by policies
+	AccessRequest struct {
+		// Subject holds the principal, the attributes of authc.UserDetails
+		// and the attributes bound by WithSubjectAttributes
+		Subject Attributes
+		// Resource holds the attributes of the resource being accessed
+		Resource Attributes
+		// Action is being performed on the resource, e.g. edit
+		Action string
+		// Environment holds time, hour, weekday and the
+		// attributes bound by WithEnvironment, e.g. ip
+		Environment Attributes
+	}
+
+	// Effect is the effect of an applicable Policy
+	Effect string
+
+	// A Condition decides whether a Policy applies to an AccessRequest
+	Condition interface {
+		// Evaluate returns true if the Policy applies
+		Evaluate(*AccessRequest) (bool, error)
+	}
+
+	// ConditionFunc is an adapter to allow the use of ordinary functions as Condition
+	ConditionFunc func(*AccessRequest) (bool, error)
+
+	// Policy permits or denies actions when its Condition holds
+	Policy struct {
+		// Name identifies the Policy in errors
+		Name string
+		// Effect is Permit or Forbid
+		Effect Effect
+		// Actions restricts the Policy to the actions, empty or "*" for any action
+		Actions []string
+		// Condition must hold for the Policy to apply, nil always holds
+		Condition Condition
+	}
+
+	// PolicyEngine evaluates policies with deny-overrides, access is granted if
+	// a permitting Policy applies and no forbidding Policy does
+	PolicyEngine struct {
+		mu       sync.RWMutex
+		policies []*Policy
+	}
+
+	// ResourceAuthorizer is an Authorizer which is also
+	// able to authorize actions on specific resources
+	ResourceAuthorizer interface {
+		Authorizer
+		// HasAccess returns true if the user can perform the action on the resource
+		HasAccess(ctx context.Context, userDetails authc.UserDetails, action string, resource any) bool
+		// CheckAccess is like HasAccess but tells why it fails, a ForbiddenError
+		// is returned if the access is denied, or the policy failure otherwise
+		CheckAccess(ctx context.Context, userDetails authc.UserDetails, action string, resource any) error
+	}
+
+	policyAuthorizer struct {
+		Authorizer
+		engine *PolicyEngine
+	}
+
+	subjectAttributesCtxKey struct{}
+	environmentCtxKey       struct{}
+
+	policyDocument struct {
+		Policies []struct {
+			Name      string          `json:"name"`
+			Effect    Effect          `json:"effect"`
+			Actions   []string        `json:"actions"`
+			Condition json.RawMessage `json:"condition"`
+		} `json:"policies"`
+	}
+)
+
+const (
+	// Permit grants access when the Policy applies
+	Permit Effect = "permit"
+	// Forbid denies access when the Policy applies, it overrides Permit
+	Forbid Effect = "forbid"
+)
+
+var (
+	_ Condition          = (ConditionFunc)(nil)
+	_ ResourceAuthorizer = (*policyAuthorizer)(nil)
+
+	// ErrMalformedPolicy is returned when a policy cannot be parsed
+	ErrMalformedPolicy = errors.New("malformed policy")
+)
+
+func (f ConditionFunc) Evaluate(req *AccessRequest) (bool, error) {
+	return f(req)
+}
+
+// WithSubjectAttributes binds extra subject attributes, e.g. session
+// attributes, to the context, they override attributes of authc.UserDetails
+func WithSubjectAttributes(ctx context.Context, attributes Attributes) context.Context {
+	return context.WithValue(ctx, subjectAttributesCtxKey{}, merge(subjectAttributes(ctx), attributes))
+}
+
+// WithEnvironment binds environment attributes, e.g. the client ip, to the context
+func WithEnvironment(ctx context.Context, attributes Attributes) context.Context {
+	return context.WithValue(ctx, environmentCtxKey{}, merge(environment(ctx), attributes))
+}
+
+// NewAccessRequest collects the attributes of the user, the resource and the environment
+func NewAccessRequest(ctx context.Context, userDetails authc.UserDetails, action string, resource any) *AccessRequest {
+	subject := Attributes{}
+	if attributed, ok := authc.As[Attributed](userDetails); ok {
+		subject = merge(subject, attributed.Attributes())
+	}
+	subject = merge(subject, subjectAttributes(ctx))
+	subject["principal"] = userDetails.Principal()
+
+	nowTime := nowFunc()
+	env := merge(Attributes{
+		"time":    nowTime,
+		"hour":    nowTime.Hour(),
+		"weekday": strings.ToLower(nowTime.Weekday().String()),
+	}, environment(ctx))
+
+	return &AccessRequest{
+		Subject:     subject,
+		Resource:    resourceAttributes(resource),
+		Action:      action,
+		Environment: env,
+	}
+}
+
+///=====================================
+///		    Policy
+///=====================================
+
+// ParsePolicies parses policies in the declarative JSON format, e.g.
+//
+//	{"policies": [{
+//	  "name": "edit-own-document",
+//	  "effect": "permit",
+//	  "actions": ["edit"],
+//	  "condition": {"all": [
+//	    {"eq": ["resource.owner", "subject.principal"]},
+//	    {"not": {"eq": ["resource.archived", true]}}
+//	  ]}
+//	}]}
+//
+// See ParseCondition for the condition syntax
+func ParsePolicies(data []byte) ([]*Policy, error) {
+	var doc policyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedPolicy, err)
+	}
+
+	policies := make([]*Policy, 0, len(doc.Policies))
+	for i, p := range doc.Policies {
+		name := p.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		if p.Effect != Permit && p.Effect != Forbid {
+			return nil, fmt.Errorf("%w: policy %s has effect %q, expecting permit or forbid", ErrMalformedPolicy, name, p.Effect)
+		}
+
+		policy := &Policy{Name: name, Effect: p.Effect, Actions: p.Actions}
+		if len(p.Condition) != 0 {
+			condition, err := ParseCondition(p.Condition)
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: %w", name, err)
+			}
+
+			policy.Condition = condition
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// Applies returns true if the Policy applies to the AccessRequest
+func (p *Policy) Applies(req *AccessRequest) (bool, error) {
+	if len(p.Actions) != 0 && !contains(p.Actions, "*") && !contains(p.Actions, req.Action) {
+		return false, nil
+	}
+
+	if p.Condition == nil {
+		return true, nil
+	}
+
+	return p.Condition.Evaluate(req)
+}
+
+///=====================================
+///		    PolicyEngine
+///=====================================
+
+func NewPolicyEngine(policies ...*Policy) *PolicyEngine {
+	return &PolicyEngine{policies: policies}
+}
+
+// Add appends policies to the engine
+func (e *PolicyEngine) Add(policies ...*Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.policies = append(e.policies, policies...)
+}
+
+// Replace replaces all policies of the engine, e.g. once reloaded
+func (e *PolicyEngine) Replace(policies ...*Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.policies = policies
+}
+
+// Evaluate returns nil if access is granted, a ForbiddenError if it is
+// denied, or the error of a Condition that could not be evaluated
+func (e *PolicyEngine) Evaluate(req *AccessRequest) error {
+	e.mu.RLock()
+	policies := e.policies
+	e.mu.RUnlock()
+
+	permitted := false
+	for _, p := range policies {
+		applies, err := p.Applies(req)
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+
+		if !applies {
+			continue
+		}
+
+		if p.Effect == Forbid {
+			return &ForbiddenError{Requirement: "policy(" + p.Name + ")"}
+		}
+
+		permitted = true
+	}
+
+	if !permitted {
+		return &ForbiddenError{Requirement: "policy(" + req.Action + ")"}
+	}
+
+	return nil
+}
+
+///=====================================
+///		    Authorizer
+///=====================================
+
+// NewPolicyAuthorizer wraps the Authorizer so that it
+// also authorizes actions on resources with the engine
+func NewPolicyAuthorizer(authorizer Authorizer, engine *PolicyEngine) ResourceAuthorizer {
+	return &policyAuthorizer{Authorizer: authorizer, engine: engine}
+}
+
+func (z *policyAuthorizer) HasAccess(ctx context.Context, userDetails authc.UserDetails, action string, resource any) bool {
+	return z.CheckAccess(ctx, userDetails, action, resource) == nil
+}
+
+func (z *policyAuthorizer) CheckAccess(ctx context.Context, userDetails authc.UserDetails, action string, resource any) error {
+	return z.engine.Evaluate(NewAccessRequest(ctx, userDetails, action, resource))
+}
+
+func (z *policyAuthorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	if la, ok := z.Authorizer.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func subjectAttributes(ctx context.Context) Attributes {
+	attributes, _ := ctx.Value(subjectAttributesCtxKey{}).(Attributes)
+	return attributes
+}
+
+func environment(ctx context.Context) Attributes {
+	attributes, _ := ctx.Value(environmentCtxKey{}).(Attributes)
+	return attributes
+}
+
+func resourceAttributes(resource any) Attributes {
+	switch r := resource.(type) {
+	case nil:
+		return Attributes{}
+	case Attributes:
+		return r
+	case map[string]any:
+		return r
+	case Attributed:
+		return r.Attributes()
+	default:
+		return Attributes{}
+	}
+}
+
+func merge(dst Attributes, src map[string]any) Attributes {
+	result := make(Attributes, len(dst)+len(src))
+	for k, v := range dst {
+		result[k] = v
+	}
+
+	for k, v := range src {
+		result[k] = v
+	}
+
+	return result
+}
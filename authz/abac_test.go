@@ -0,0 +1,179 @@
+package authz
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type employee struct {
+	ud
+	department string
+}
+
+func (e employee) Attributes() map[string]any {
+	return map[string]any{"department": e.department}
+}
+
+type document struct {
+	owner    string
+	archived bool
+}
+
+func (d document) Attributes() map[string]any {
+	return map[string]any{"owner": d.owner, "archived": d.archived}
+}
+
+const documentPolicies = `{"policies": [
+	{
+		"name": "edit-own-document",
+		"effect": "permit",
+		"actions": ["edit"],
+		"condition": {"all": [
+			{"eq": ["resource.owner", "subject.principal"]},
+			{"not": {"eq": ["resource.archived", true]}}
+		]}
+	},
+	{
+		"name": "read-in-office",
+		"effect": "permit",
+		"actions": ["read"],
+		"condition": {"all": [
+			{"in": ["subject.department", ["sales", "legal"]]},
+			{"ipIn": ["environment.ip", ["10.0.0.0/8", "192.168.0.0/16"]]}
+		]}
+	},
+	{
+		"name": "no-weekend",
+		"effect": "forbid",
+		"condition": {"in": ["environment.weekday", ["saturday", "sunday"]]}
+	}
+]}`
+
+func TestPolicyEngine(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	// Monday
+	nowFunc = func() time.Time { return time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC) }
+
+	editOwn := &Policy{
+		Name:    "edit-own-document",
+		Effect:  Permit,
+		Actions: []string{"edit"},
+		Condition: ConditionFunc(func(req *AccessRequest) (bool, error) {
+			return req.Resource["owner"] == req.Subject["principal"] && req.Resource["archived"] != true, nil
+		}),
+	}
+
+	azer := NewPolicyAuthorizer(NewAuthorizer(&mockRealm{}), NewPolicyEngine(editOwn))
+	ctx := context.Background()
+	assert.True(t, azer.HasAccess(ctx, mockUd, "edit", document{owner: "mockUd"}))
+	assert.False(t, azer.HasAccess(ctx, mockUd, "edit", document{owner: "mockUd", archived: true}))
+	assert.False(t, azer.HasAccess(ctx, mockUd, "edit", document{owner: "other"}))
+	assert.False(t, azer.HasAccess(ctx, mockUd, "delete", document{owner: "mockUd"}))
+	assert.True(t, azer.HasRole(ctx, mockUd, role("a")))
+
+	err := azer.CheckAccess(ctx, mockUd, "delete", document{owner: "mockUd"})
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.EqualError(t, err, "forbidden: policy(delete)")
+}
+
+func TestParsePolicies(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	// Monday
+	nowFunc = func() time.Time { return time.Date(2022, 8, 1, 10, 0, 0, 0, time.UTC) }
+
+	policies, err := ParsePolicies([]byte(documentPolicies))
+	assert.NoError(t, err)
+	assert.Len(t, policies, 3)
+
+	engine := NewPolicyEngine(policies...)
+	azer := NewPolicyAuthorizer(NewAuthorizer(&mockRealm{}), engine)
+	alice := employee{ud: "alice", department: "sales"}
+
+	ctx := context.Background()
+	assert.True(t, azer.HasAccess(ctx, alice, "edit", document{owner: "alice"}))
+	assert.False(t, azer.HasAccess(ctx, alice, "edit", document{owner: "alice", archived: true}))
+	assert.True(t, azer.HasAccess(ctx, alice, "edit", Attributes{"owner": "alice"}))
+
+	// ip comes from the environment
+	assert.False(t, azer.HasAccess(ctx, alice, "read", nil))
+	office := WithEnvironment(ctx, Attributes{"ip": "10.1.2.3"})
+	assert.True(t, azer.HasAccess(office, alice, "read", nil))
+	assert.False(t, azer.HasAccess(WithEnvironment(ctx, Attributes{"ip": "8.8.8.8"}), alice, "read", nil))
+
+	// subject attributes bound to the context override those of the user
+	moved := WithSubjectAttributes(office, Attributes{"department": "it"})
+	assert.False(t, azer.HasAccess(moved, alice, "read", nil))
+
+	// forbid overrides permit
+	nowFunc = func() time.Time { return time.Date(2022, 8, 6, 10, 0, 0, 0, time.UTC) }
+	err = azer.CheckAccess(ctx, alice, "edit", document{owner: "alice"})
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.EqualError(t, err, "forbidden: policy(no-weekend)")
+}
+
+func TestParsePoliciesMalformed(t *testing.T) {
+	_, err := ParsePolicies([]byte(`{"policies": [{"effect": "allow"}]}`))
+	assert.ErrorIs(t, err, ErrMalformedPolicy)
+
+	_, err = ParsePolicies([]byte(`{"policies": [{"effect": "permit", "condition": {"eq": ["a"]}}]}`))
+	assert.ErrorIs(t, err, ErrMalformedPolicy)
+
+	_, err = ParsePolicies([]byte(`{"policies": [{"effect": "permit", "condition": {"like": ["a", "b"]}}]}`))
+	assert.EqualError(t, err, `policy #1: malformed policy: unknown operator "like"`)
+
+	_, err = ParsePolicies([]byte(`{"policies": [{"effect": "permit", "condition": {"eq": [1, 1], "ne": [1, 2]}}]}`))
+	assert.ErrorIs(t, err, ErrMalformedPolicy)
+}
+
+func TestCondition(t *testing.T) {
+	req := &AccessRequest{
+		Subject:  Attributes{"level": 3, "tags": []string{"vip"}, "profile": map[string]any{"country": "cn"}},
+		Resource: Attributes{"level": 2.0, "expires": time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)},
+		Action:   "read",
+	}
+
+	tests := []struct {
+		condition string
+		expected  bool
+	}{
+		{`{"gt": ["subject.level", "resource.level"]}`, true},
+		{`{"le": ["subject.level", 3]}`, true},
+		{`{"lt": ["subject.missing", 3]}`, false},
+		// missing attributes never match, even on both sides
+		{`{"eq": ["resource.tenant", "subject.tenant"]}`, false},
+		{`{"ne": ["resource.tenant", "subject.tenant"]}`, false},
+		{`{"ne": ["resource.tenant", "cn"]}`, false},
+		{`{"in": ["resource.tenant", "subject.tenants"]}`, false},
+		{`{"contains": ["subject.tenants", "resource.tenant"]}`, false},
+		{`{"ne": ["subject.level", 2]}`, true},
+		{`{"contains": ["subject.tags", "vip"]}`, true},
+		{`{"eq": ["subject.profile.country", "cn"]}`, true},
+		{`{"eq": ["action", "read"]}`, true},
+		{`{"not": {"eq": ["action", "read"]}}`, false},
+		{`{"any": [{"eq": ["action", "write"]}, {"eq": ["subject.level", 3]}]}`, true},
+		{`{"ge": ["resource.expires", "2022-07-31T00:00:00Z"]}`, true},
+	}
+
+	for _, tt := range tests {
+		cond, err := ParseCondition([]byte(tt.condition))
+		assert.NoError(t, err, tt.condition)
+
+		ok, err := cond.Evaluate(req)
+		assert.NoError(t, err, tt.condition)
+		assert.Equal(t, tt.expected, ok, tt.condition)
+	}
+
+	cond, err := ParseCondition([]byte(`{"gt": ["resource.tags", 1]}`))
+	assert.NoError(t, err)
+
+	_, err = cond.Evaluate(&AccessRequest{Resource: Attributes{"tags": []string{"vip"}}})
+	assert.Error(t, err)
+
+	// conditions that cannot be evaluated fail closed
+	azer := NewPolicyAuthorizer(NewAuthorizer(&mockRealm{}), NewPolicyEngine(&Policy{Name: "broken", Effect: Permit, Condition: cond}))
+	err = azer.CheckAccess(context.Background(), mockUd, "read", Attributes{"tags": []string{"vip"}})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrForbidden)
+}
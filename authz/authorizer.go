@@ -2,13 +2,23 @@ package authz
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/shrinex/shield/authc"
+	"strings"
 )
 
 type (
 	authorizer struct {
 		realms []Realm
 	}
+
+	// ForbiddenError is returned when the user lacks a requirement,
+	// errors.Is(err, ErrForbidden) reports true for it
+	ForbiddenError struct {
+		// Requirement that is not satisfied, e.g. hasRole(admin)
+		Requirement string
+	}
 )
 
 var (
@@ -16,6 +26,9 @@ var (
 
 	// NoopAuthorizer does nothing
 	NoopAuthorizer = &authorizer{realms: make([]Realm, 0)}
+
+	// ErrForbidden is returned when the user is authenticated but not allowed
+	ErrForbidden = errors.New("forbidden")
 )
 
 func NewAuthorizer(realm Realm, realms ...Realm) Authorizer {
@@ -23,82 +36,148 @@ func NewAuthorizer(realm Realm, realms ...Realm) Authorizer {
 }
 
 func (z *authorizer) HasRole(ctx context.Context, userDetails authc.UserDetails, role Role) bool {
-	for _, r := range z.realms {
-		roles, err := r.LoadRoles(ctx, userDetails)
-		if err != nil {
-			continue
-		}
+	return z.CheckRole(ctx, userDetails, role) == nil
+}
 
-		for _, v := range roles {
-			if v.Implies(role) {
-				return true
-			}
-		}
-	}
+func (z *authorizer) HasAnyRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
+	return z.CheckAnyRole(ctx, userDetails, roles...) == nil
+}
 
-	return false
+func (z *authorizer) HasAllRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
+	return z.CheckAllRole(ctx, userDetails, roles...) == nil
 }
 
-func (z *authorizer) HasAnyRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
+func (z *authorizer) HasAuthority(ctx context.Context, userDetails authc.UserDetails, authority Authority) bool {
+	return z.CheckAuthority(ctx, userDetails, authority) == nil
+}
+
+func (z *authorizer) HasAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
+	return z.CheckAnyAuthority(ctx, userDetails, authorities...) == nil
+}
+
+func (z *authorizer) HasAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
+	return z.CheckAllAuthority(ctx, userDetails, authorities...) == nil
+}
+
+func (z *authorizer) CheckRole(ctx context.Context, userDetails authc.UserDetails, role Role) error {
+	return z.checkAnyRole(ctx, userDetails, Requirement("hasRole", role), role)
+}
+
+func (z *authorizer) CheckAnyRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) error {
+	return z.checkAnyRole(ctx, userDetails, Requirement("hasAnyRole", roles...), roles...)
+}
+
+func (z *authorizer) CheckAllRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) error {
 	for _, role := range roles {
-		if z.HasRole(ctx, userDetails, role) {
-			return true
+		if err := z.CheckRole(ctx, userDetails, role); err != nil {
+			return err
 		}
 	}
 
-	return false
+	return nil
 }
 
-func (z *authorizer) HasAllRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
-	for _, role := range roles {
-		if !z.HasRole(ctx, userDetails, role) {
-			return false
+func (z *authorizer) CheckAuthority(ctx context.Context, userDetails authc.UserDetails, authority Authority) error {
+	return z.checkAnyAuthority(ctx, userDetails, Requirement("hasAuthority", authority), authority)
+}
+
+func (z *authorizer) CheckAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) error {
+	return z.checkAnyAuthority(ctx, userDetails, Requirement("hasAnyAuthority", authorities...), authorities...)
+}
+
+func (z *authorizer) CheckAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) error {
+	for _, authority := range authorities {
+		if err := z.CheckAuthority(ctx, userDetails, authority); err != nil {
+			return err
 		}
 	}
 
-	return true
+	return nil
 }
 
-func (z *authorizer) HasAuthority(ctx context.Context, userDetails authc.UserDetails, authority Authority) bool {
+func (z *authorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
 	for _, r := range z.realms {
-		authorities, err := r.LoadAuthorities(ctx, userDetails)
+		if la, ok := r.(authc.LogoutAware); ok {
+			la.Logout(ctx, userDetails)
+		}
+	}
+}
+
+// checkAnyRole grants access as soon as a realm grants it, a realm failure
+// is only reported if no other realm grants access, since the failed realm
+// may have granted it
+func (z *authorizer) checkAnyRole(ctx context.Context, userDetails authc.UserDetails, requirement string, roles ...Role) error {
+	var loadErr error
+	for _, r := range z.realms {
+		granted, err := r.LoadRoles(ctx, userDetails)
 		if err != nil {
+			if loadErr == nil {
+				loadErr = fmt.Errorf("load roles: %w", err)
+			}
 			continue
 		}
-		for _, v := range authorities {
-			if v.Implies(authority) {
-				return true
+
+		for _, v := range granted {
+			for _, role := range roles {
+				if v.Implies(role) {
+					return nil
+				}
 			}
 		}
 	}
 
-	return false
+	if loadErr != nil {
+		return loadErr
+	}
+
+	return &ForbiddenError{Requirement: requirement}
 }
 
-func (z *authorizer) HasAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
-	for _, authority := range authorities {
-		if z.HasAuthority(ctx, userDetails, authority) {
-			return true
+func (z *authorizer) checkAnyAuthority(ctx context.Context, userDetails authc.UserDetails, requirement string, authorities ...Authority) error {
+	var loadErr error
+	for _, r := range z.realms {
+		granted, err := r.LoadAuthorities(ctx, userDetails)
+		if err != nil {
+			if loadErr == nil {
+				loadErr = fmt.Errorf("load authorities: %w", err)
+			}
+			continue
 		}
+
+		for _, v := range granted {
+			for _, authority := range authorities {
+				if v.Implies(authority) {
+					return nil
+				}
+			}
+		}
+	}
+
+	if loadErr != nil {
+		return loadErr
 	}
 
-	return false
+	return &ForbiddenError{Requirement: requirement}
 }
 
-func (z *authorizer) HasAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
-	for _, authority := range authorities {
-		if !z.HasAuthority(ctx, userDetails, authority) {
-			return false
-		}
-	}
+///=====================================
+///		    ForbiddenError
+///=====================================
 
-	return true
+func (e *ForbiddenError) Error() string {
+	return "forbidden: " + e.Requirement
 }
 
-func (z *authorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
-	for _, r := range z.realms {
-		if la, ok := r.(authc.LogoutAware); ok {
-			la.Logout(ctx, userDetails)
-		}
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Requirement describes a requirement for ForbiddenError, e.g. hasAnyRole(admin, user)
+func Requirement[T interface{ Desc() string }](name string, values ...T) string {
+	descs := make([]string, 0, len(values))
+	for _, v := range values {
+		descs = append(descs, v.Desc())
 	}
+
+	return name + "(" + strings.Join(descs, ", ") + ")"
 }
@@ -2,6 +2,7 @@ package authz
 
 import (
 	"context"
+	"errors"
 	"github.com/shrinex/shield/authc"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -71,3 +72,54 @@ func TestHasAllAuthority(t *testing.T) {
 	assert.False(t, azer.HasAllAuthority(context.Background(), mockUd, authority("read"), authority("delete")))
 	assert.False(t, azer.HasAllAuthority(context.Background(), mockUd, authority("delete"), authority("clear")))
 }
+
+type failingRealm struct {
+}
+
+func (r *failingRealm) LoadRoles(context.Context, authc.UserDetails) ([]Role, error) {
+	return nil, errors.New("database down")
+}
+
+func (r *failingRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]Authority, error) {
+	return nil, errors.New("database down")
+}
+
+func TestCheckRole(t *testing.T) {
+	azer := NewAuthorizer(&mockRealm{})
+
+	assert.NoError(t, azer.CheckRole(context.Background(), mockUd, role("a")))
+	assert.NoError(t, azer.CheckAllRole(context.Background(), mockUd, role("a"), role("b")))
+
+	err := azer.CheckRole(context.Background(), mockUd, role("d"))
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.EqualError(t, err, "forbidden: hasRole(d)")
+
+	var forbidden *ForbiddenError
+	assert.ErrorAs(t, azer.CheckAnyRole(context.Background(), mockUd, role("d"), role("e")), &forbidden)
+	assert.Equal(t, "hasAnyRole(d, e)", forbidden.Requirement)
+
+	assert.EqualError(t, azer.CheckAllRole(context.Background(), mockUd, role("a"), role("e")), "forbidden: hasRole(e)")
+
+	// a realm failure is not reported as forbidden
+	azer = NewAuthorizer(&failingRealm{})
+	err = azer.CheckRole(context.Background(), mockUd, role("a"))
+	assert.EqualError(t, err, "load roles: database down")
+	assert.NotErrorIs(t, err, ErrForbidden)
+	assert.False(t, azer.HasRole(context.Background(), mockUd, role("a")))
+
+	// unless another realm grants the role
+	azer = NewAuthorizer(&failingRealm{}, &mockRealm{})
+	assert.NoError(t, azer.CheckRole(context.Background(), mockUd, role("a")))
+}
+
+func TestCheckAuthority(t *testing.T) {
+	azer := NewAuthorizer(&mockRealm{})
+
+	assert.NoError(t, azer.CheckAuthority(context.Background(), mockUd, authority("read")))
+	assert.NoError(t, azer.CheckAnyAuthority(context.Background(), mockUd, authority("delete"), authority("read")))
+	assert.EqualError(t, azer.CheckAllAuthority(context.Background(), mockUd, authority("read"), authority("delete")), "forbidden: hasAuthority(delete)")
+	assert.EqualError(t, azer.CheckAnyAuthority(context.Background(), mockUd, authority("delete")), "forbidden: hasAnyAuthority(delete)")
+
+	err := NewAuthorizer(&failingRealm{}).CheckAuthority(context.Background(), mockUd, authority("read"))
+	assert.EqualError(t, err, "load authorities: database down")
+}
@@ -0,0 +1,254 @@
+package authz
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/internal/xcontext"
+	"sync"
+	"time"
+)
+
+type (
+	// CacheOption can be used to customize CacheOptions
+	CacheOption func(*CacheOptions)
+
+	// CacheOptions contains config attribute that can
+	// affect how CachingRealm caches roles and authorities
+	CacheOptions struct {
+		// TTL controls how long loaded roles and authorities are cached
+		TTL time.Duration
+		// MaxEntries bounds the cache, the least recently used entry is evicted,
+		// roles and authorities of a principal are two entries per domain
+		MaxEntries int
+		// LoadTimeout bounds a load of the decorated Realm, which is detached
+		// from the callers, so that a hung Realm does not block a key forever
+		LoadTimeout time.Duration
+	}
+
+	// CachingRealm decorates a Realm, roles and authorities are cached per principal
+	// and active domain, and concurrent loads of the same key are collapsed into a
+	// single call, which is not canceled if the caller that started it gives up,
+	// but is bounded by LoadTimeout, a panic of the Realm fails the call instead.
+	// Entries of a principal are invalidated on logout through authc.LogoutAware,
+	// call Invalidate or InvalidateAll once permissions change
+	CachingRealm struct {
+		realm      Realm
+		opt        *CacheOptions
+		mu         sync.Mutex
+		lru        *list.List
+		entries    map[cacheKey]*list.Element
+		calls      map[cacheKey]*cacheCall
+		generation uint64
+	}
+
+	cacheKey struct {
+		kind      string
+		principal string
+		domain    string
+	}
+
+	cacheEntry struct {
+		key       cacheKey
+		value     any
+		expiresAt time.Time
+	}
+
+	cacheCall struct {
+		done  chan struct{}
+		value any
+		err   error
+	}
+)
+
+const (
+	rolesCacheKind       = "roles"
+	authoritiesCacheKind = "authorities"
+)
+
+var (
+	_ Realm             = (*CachingRealm)(nil)
+	_ authc.LogoutAware = (*CachingRealm)(nil)
+
+	nowFunc = time.Now
+
+	// ErrRealmPanicked is returned by CachingRealm when the decorated Realm panics
+	ErrRealmPanicked = errors.New("realm panicked")
+)
+
+var defaultCacheOptions = CacheOptions{
+	TTL:         5 * time.Minute,
+	MaxEntries:  10000,
+	LoadTimeout: 30 * time.Second,
+}
+
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(opt *CacheOptions) {
+		if ttl > 0 {
+			opt.TTL = ttl
+		}
+	}
+}
+
+func WithCacheMaxEntries(maxEntries int) CacheOption {
+	return func(opt *CacheOptions) {
+		if maxEntries > 0 {
+			opt.MaxEntries = maxEntries
+		}
+	}
+}
+
+func WithCacheLoadTimeout(timeout time.Duration) CacheOption {
+	return func(opt *CacheOptions) {
+		if timeout > 0 {
+			opt.LoadTimeout = timeout
+		}
+	}
+}
+
+func NewCachingRealm(realm Realm, opts ...CacheOption) *CachingRealm {
+	opt := defaultCacheOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &CachingRealm{
+		realm:   realm,
+		opt:     &opt,
+		lru:     list.New(),
+		entries: make(map[cacheKey]*list.Element),
+		calls:   make(map[cacheKey]*cacheCall),
+	}
+}
+
+func (c *CachingRealm) LoadRoles(ctx context.Context, userDetails authc.UserDetails) ([]Role, error) {
+	key := cacheKey{kind: rolesCacheKind, principal: userDetails.Principal(), domain: DomainFrom(ctx)}
+	value, err := c.load(ctx, key, func(ctx context.Context) (any, error) {
+		return c.realm.LoadRoles(ctx, userDetails)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.([]Role), nil
+}
+
+func (c *CachingRealm) LoadAuthorities(ctx context.Context, userDetails authc.UserDetails) ([]Authority, error) {
+	key := cacheKey{kind: authoritiesCacheKind, principal: userDetails.Principal(), domain: DomainFrom(ctx)}
+	value, err := c.load(ctx, key, func(ctx context.Context) (any, error) {
+		return c.realm.LoadAuthorities(ctx, userDetails)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.([]Authority), nil
+}
+
+// Invalidate removes the cached roles and authorities of the principal in every domain
+func (c *CachingRealm) Invalidate(principal string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, elem := range c.entries {
+		if key.principal == principal {
+			c.remove(elem)
+		}
+	}
+}
+
+// InvalidateAll removes all cached roles and authorities,
+// e.g. once a role mapping has changed
+func (c *CachingRealm) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.lru.Init()
+	c.entries = make(map[cacheKey]*list.Element)
+}
+
+func (c *CachingRealm) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	c.Invalidate(userDetails.Principal())
+
+	if la, ok := c.realm.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
+
+func (c *CachingRealm) load(ctx context.Context, key cacheKey, loader func(context.Context) (any, error)) (any, error) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if nowFunc().Before(entry.expiresAt) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.value, nil
+		}
+
+		c.remove(elem)
+	}
+
+	// another goroutine may be loading the same key
+	call, ok := c.calls[key]
+	if !ok {
+		call = &cacheCall{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.call(ctx, key, call, c.generation, loader)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// call runs the loader on behalf of all waiters, so that it is detached from
+// the context of the caller that started it, but bounded by LoadTimeout
+func (c *CachingRealm) call(ctx context.Context, key cacheKey, call *cacheCall, generation uint64, loader func(context.Context) (any, error)) {
+	ctx, cancel := context.WithTimeout(xcontext.WithoutCancel(ctx), c.opt.LoadTimeout)
+	defer cancel()
+
+	defer func() {
+		// a faulty realm must not crash the process nor leave the key loading
+		if v := recover(); v != nil {
+			call.value, call.err = nil, fmt.Errorf("%w: %v", ErrRealmPanicked, v)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		delete(c.calls, key)
+		// errors are not cached, neither are values loaded before an invalidation
+		if call.err == nil && generation == c.generation {
+			c.add(key, call.value)
+		}
+
+		close(call.done)
+	}()
+
+	call.value, call.err = loader(ctx)
+}
+
+func (c *CachingRealm) add(key cacheKey, value any) {
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:       key,
+		value:     value,
+		expiresAt: nowFunc().Add(c.opt.TTL),
+	})
+
+	for c.lru.Len() > c.opt.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachingRealm) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
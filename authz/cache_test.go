@@ -0,0 +1,198 @@
+package authz
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingRealm struct {
+	rolesRealm
+	loads   int32
+	release chan struct{}
+}
+
+func (r *countingRealm) LoadRoles(ctx context.Context, userDetails authc.UserDetails) ([]Role, error) {
+	atomic.AddInt32(&r.loads, 1)
+	if r.release != nil {
+		<-r.release
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return r.rolesRealm.LoadRoles(ctx, userDetails)
+}
+
+type domainRolesRealm struct {
+	rolesRealm
+}
+
+func (r *domainRolesRealm) LoadRoles(ctx context.Context, _ authc.UserDetails) ([]Role, error) {
+	return []Role{role(DomainFrom(ctx))}, nil
+}
+
+func TestCachingRealm(t *testing.T) {
+	inner := &countingRealm{rolesRealm: rolesRealm{roles: []Role{role("a"), role("b")}}}
+	cache := NewCachingRealm(inner)
+	azer := NewAuthorizer(cache)
+
+	assert.True(t, azer.HasAllRole(context.TODO(), mockUd, role("a"), role("b")))
+	assert.True(t, azer.HasRole(context.TODO(), mockUd, role("a")))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.loads))
+
+	// permissions changed
+	inner.roles = []Role{role("a")}
+	cache.Invalidate(mockUd.Principal())
+	assert.False(t, azer.HasRole(context.TODO(), mockUd, role("b")))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inner.loads))
+
+	// logout invalidates through authc.LogoutAware
+	azer.(authc.LogoutAware).Logout(context.TODO(), mockUd)
+	assert.True(t, azer.HasRole(context.TODO(), mockUd, role("a")))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&inner.loads))
+
+	cache.InvalidateAll()
+	assert.True(t, azer.HasRole(context.TODO(), mockUd, role("a")))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&inner.loads))
+}
+
+func TestCachingRealmExpiration(t *testing.T) {
+	nowTime := time.Now()
+	defer func() { nowFunc = time.Now }()
+	nowFunc = func() time.Time { return nowTime }
+
+	inner := &countingRealm{rolesRealm: rolesRealm{roles: []Role{role("a")}}}
+	cache := NewCachingRealm(inner, WithCacheTTL(time.Minute), WithCacheMaxEntries(2))
+
+	_, _ = cache.LoadRoles(context.TODO(), ud("a"))
+	_, _ = cache.LoadRoles(context.TODO(), ud("a"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.loads))
+
+	nowFunc = func() time.Time { return nowTime.Add(2 * time.Minute) }
+	_, _ = cache.LoadRoles(context.TODO(), ud("a"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&inner.loads))
+
+	// "a" is the least recently used once "b" and "c" are loaded
+	_, _ = cache.LoadRoles(context.TODO(), ud("b"))
+	_, _ = cache.LoadRoles(context.TODO(), ud("c"))
+	_, _ = cache.LoadRoles(context.TODO(), ud("c"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&inner.loads))
+	_, _ = cache.LoadRoles(context.TODO(), ud("a"))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&inner.loads))
+}
+
+func TestCachingRealmSingleFlight(t *testing.T) {
+	inner := &countingRealm{
+		rolesRealm: rolesRealm{roles: []Role{role("a")}},
+		release:    make(chan struct{}),
+	}
+	cache := NewCachingRealm(inner)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			roles, err := cache.LoadRoles(context.TODO(), mockUd)
+			assert.NoError(t, err)
+			assert.Equal(t, []Role{role("a")}, roles)
+		}()
+	}
+
+	// let the goroutines pile up on the first load
+	time.Sleep(50 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.loads))
+}
+
+func TestCachingRealmDomain(t *testing.T) {
+	azer := NewAuthorizer(NewCachingRealm(&domainRolesRealm{}))
+
+	orgA := WithDomain(context.TODO(), "org-a")
+	orgB := WithDomain(context.TODO(), "org-b")
+	assert.True(t, azer.HasRole(orgA, mockUd, role("org-a")))
+	assert.False(t, azer.HasRole(orgB, mockUd, role("org-a")))
+	assert.True(t, azer.HasRole(orgB, mockUd, role("org-b")))
+	assert.False(t, azer.HasRole(orgA, mockUd, role("org-b")))
+}
+
+func TestCachingRealmCanceledCaller(t *testing.T) {
+	inner := &countingRealm{
+		rolesRealm: rolesRealm{roles: []Role{role("a")}},
+		release:    make(chan struct{}),
+	}
+	cache := NewCachingRealm(inner)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	first := make(chan error)
+	go func() {
+		_, err := cache.LoadRoles(ctx, mockUd)
+		first <- err
+	}()
+
+	second := make(chan error)
+	go func() {
+		// joins the load started by the first caller
+		time.Sleep(20 * time.Millisecond)
+		roles, err := cache.LoadRoles(context.TODO(), mockUd)
+		assert.Equal(t, []Role{role("a")}, roles)
+		second <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+
+	close(inner.release)
+	assert.NoError(t, <-second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.loads))
+}
+
+type faultyRealm struct {
+	rolesRealm
+	hang  bool
+	loads int32
+}
+
+func (r *faultyRealm) LoadRoles(ctx context.Context, userDetails authc.UserDetails) ([]Role, error) {
+	if atomic.AddInt32(&r.loads, 1) > 1 {
+		return r.rolesRealm.LoadRoles(ctx, userDetails)
+	}
+
+	if !r.hang {
+		panic("boom")
+	}
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCachingRealmFaultyLoad(t *testing.T) {
+	// a panic is reported to the callers instead of crashing the process
+	inner := &faultyRealm{rolesRealm: rolesRealm{roles: []Role{role("a")}}}
+	cache := NewCachingRealm(inner)
+	_, err := cache.LoadRoles(context.TODO(), mockUd)
+	assert.ErrorIs(t, err, ErrRealmPanicked)
+
+	roles, err := cache.LoadRoles(context.TODO(), mockUd)
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{role("a")}, roles)
+
+	// a hung load times out, so that the key is loaded again
+	inner = &faultyRealm{rolesRealm: rolesRealm{roles: []Role{role("a")}}, hang: true}
+	cache = NewCachingRealm(inner, WithCacheLoadTimeout(20*time.Millisecond))
+	_, err = cache.LoadRoles(context.TODO(), mockUd)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	roles, err = cache.LoadRoles(context.TODO(), mockUd)
+	assert.NoError(t, err)
+	assert.Equal(t, []Role{role("a")}, roles)
+}
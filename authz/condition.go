@@ -0,0 +1,329 @@
+package authz
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type (
+	operand func(*AccessRequest) any
+
+	compareFunc func(int) bool
+)
+
+var referencePrefixes = []string{"subject.", "resource.", "environment."}
+
+// ParseCondition parses a JSON condition, a condition is an object with a single
+// operator, all/any take an array of conditions and not takes a condition:
+//
+//	{"all": [...]} {"any": [...]} {"not": {...}}
+//
+// The other operators take an array of operands:
+//
+//	{"eq": [a, b]} {"ne": [a, b]} {"in": [a, list]} {"contains": [list, a]}
+//	{"gt": [a, b]} {"ge": [a, b]} {"lt": [a, b]} {"le": [a, b]}
+//	{"ipIn": [ip, cidr or list of cidr]}
+//
+// Strings starting with subject., resource. or environment., and the string
+// action, refer to attributes of the AccessRequest, other values are literals.
+// Comparisons with a missing attribute are false, ne included, use not to
+// match attributes that may be missing, e.g. {"not": {"eq": [a, b]}}
+func ParseCondition(data []byte) (Condition, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) != 1 {
+		return nil, fmt.Errorf("%w: condition %s must be an object with a single operator", ErrMalformedPolicy, data)
+	}
+
+	for op, arg := range raw {
+		switch op {
+		case "all", "any":
+			return parseJunction(op, arg)
+		case "not":
+			cond, err := ParseCondition(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			return ConditionFunc(func(req *AccessRequest) (bool, error) {
+				ok, err := cond.Evaluate(req)
+				return !ok, err
+			}), nil
+		case "eq", "ne", "in", "contains", "gt", "ge", "lt", "le", "ipIn":
+			return parseComparison(op, arg)
+		default:
+			return nil, fmt.Errorf("%w: unknown operator %q", ErrMalformedPolicy, op)
+		}
+	}
+
+	panic("unreachable")
+}
+
+func parseJunction(op string, arg json.RawMessage) (Condition, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(arg, &raws); err != nil || len(raws) == 0 {
+		return nil, fmt.Errorf("%w: %s expects a non-empty array of conditions", ErrMalformedPolicy, op)
+	}
+
+	conds := make([]Condition, 0, len(raws))
+	for _, raw := range raws {
+		cond, err := ParseCondition(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		conds = append(conds, cond)
+	}
+
+	all := op == "all"
+	return ConditionFunc(func(req *AccessRequest) (bool, error) {
+		for _, cond := range conds {
+			ok, err := cond.Evaluate(req)
+			if err != nil {
+				return false, err
+			}
+
+			// short-circuit
+			if ok != all {
+				return ok, nil
+			}
+		}
+
+		return all, nil
+	}), nil
+}
+
+func parseComparison(op string, arg json.RawMessage) (Condition, error) {
+	var values []any
+	if err := json.Unmarshal(arg, &values); err != nil || len(values) != 2 {
+		return nil, fmt.Errorf("%w: %s expects an array of two operands", ErrMalformedPolicy, op)
+	}
+
+	lhs, rhs := parseOperand(values[0]), parseOperand(values[1])
+	switch op {
+	case "eq":
+		return binary(lhs, rhs, func(a any, b any) (bool, error) { return equal(a, b), nil }), nil
+	case "ne":
+		return binary(lhs, rhs, func(a any, b any) (bool, error) { return !equal(a, b), nil }), nil
+	case "in":
+		return binary(lhs, rhs, func(a any, b any) (bool, error) { return member(b, a), nil }), nil
+	case "contains":
+		return binary(lhs, rhs, func(a any, b any) (bool, error) { return member(a, b), nil }), nil
+	case "gt":
+		return ordered(lhs, rhs, func(c int) bool { return c > 0 }), nil
+	case "ge":
+		return ordered(lhs, rhs, func(c int) bool { return c >= 0 }), nil
+	case "lt":
+		return ordered(lhs, rhs, func(c int) bool { return c < 0 }), nil
+	case "le":
+		return ordered(lhs, rhs, func(c int) bool { return c <= 0 }), nil
+	default:
+		return binary(lhs, rhs, ipIn), nil
+	}
+}
+
+func parseOperand(value any) operand {
+	s, ok := value.(string)
+	if !ok {
+		return func(*AccessRequest) any { return value }
+	}
+
+	if s == "action" {
+		return func(req *AccessRequest) any { return req.Action }
+	}
+
+	for _, prefix := range referencePrefixes {
+		if strings.HasPrefix(s, prefix) {
+			path := strings.Split(s[len(prefix):], ".")
+			return func(req *AccessRequest) any {
+				switch prefix {
+				case "subject.":
+					return lookup(req.Subject, path)
+				case "resource.":
+					return lookup(req.Resource, path)
+				default:
+					return lookup(req.Environment, path)
+				}
+			}
+		}
+	}
+
+	return func(*AccessRequest) any { return s }
+}
+
+// binary evaluates f against both operands, missing attributes never match,
+// whatever the operator, e.g. ne is false as well, so that a policy comparing
+// two missing attributes does not grant access
+func binary(lhs operand, rhs operand, f func(any, any) (bool, error)) Condition {
+	return ConditionFunc(func(req *AccessRequest) (bool, error) {
+		a, b := lhs(req), rhs(req)
+		if a == nil || b == nil {
+			return false, nil
+		}
+
+		return f(a, b)
+	})
+}
+
+func ordered(lhs operand, rhs operand, f compareFunc) Condition {
+	return binary(lhs, rhs, func(a any, b any) (bool, error) {
+		c, err := compare(a, b)
+		if err != nil {
+			return false, err
+		}
+
+		return f(c), nil
+	})
+}
+
+func lookup(attributes map[string]any, path []string) any {
+	var value any = attributes
+	for _, key := range path {
+		switch m := value.(type) {
+		case Attributes:
+			value = m[key]
+		case map[string]any:
+			value = m[key]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	default:
+		return v
+	}
+}
+
+func equal(a any, b any) bool {
+	a, b = normalize(a), normalize(b)
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		return ok && ta.Equal(tb)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func member(list any, value any) bool {
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if equal(rv.Index(i).Interface(), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compare(a any, b any) (int, error) {
+	a, b = normalize(a), normalize(b)
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			return compareOrdered(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return compareOrdered(x, y), nil
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return compareOrdered(x.UnixNano(), y.UnixNano()), nil
+		case string:
+			t, err := time.Parse(time.RFC3339, y)
+			if err != nil {
+				return 0, err
+			}
+
+			return compareOrdered(x.UnixNano(), t.UnixNano()), nil
+		}
+	}
+
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func compareOrdered[T float64 | string | int64](a T, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func ipIn(value any, cidrs any) (bool, error) {
+	s, ok := value.(string)
+	if !ok {
+		return false, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false, nil
+	}
+
+	var list []any
+	switch c := cidrs.(type) {
+	case string:
+		list = []any{c}
+	case []any:
+		list = c
+	case []string:
+		for _, v := range c {
+			list = append(list, v)
+		}
+	default:
+		return false, fmt.Errorf("cannot use %T as cidr", cidrs)
+	}
+
+	for _, v := range list {
+		s, _ := v.(string)
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return false, err
+		}
+
+		if network.Contains(ip) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
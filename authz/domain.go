@@ -0,0 +1,349 @@
+package authz
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// A DomainRealm loads Role(s) and Authority(s) of a user within a domain,
+	// e.g. a tenant, the domain is empty if the request has no active domain
+	DomainRealm interface {
+		// LoadDomainRoles returns the Role(s) of the user within the domain
+		LoadDomainRoles(ctx context.Context, userDetails authc.UserDetails, domain string) ([]Role, error)
+		// LoadDomainAuthorities returns the Authority(s) of the user within the domain
+		LoadDomainAuthorities(ctx context.Context, userDetails authc.UserDetails, domain string) ([]Authority, error)
+	}
+
+	// DomainPolicy grants roles to users and authorities to roles per domain,
+	// like the RBAC with domains model of Casbin. Grants in AnyDomain apply to
+	// every domain, roles can be granted to roles to form a hierarchy
+	DomainPolicy struct {
+		mu          sync.RWMutex
+		parse       func(string) (Authority, error)
+		roles       map[string]map[string][]string
+		authorities map[string]map[string][]Authority
+	}
+
+	// DomainPolicyOption can be used to customize DomainPolicy
+	DomainPolicyOption func(*DomainPolicy)
+
+	domainRealm struct {
+		realm DomainRealm
+	}
+
+	domainCtxKey struct{}
+)
+
+// AnyDomain grants roles or authorities in every domain
+const AnyDomain = "*"
+
+var (
+	_ DomainRealm = (*DomainPolicy)(nil)
+	_ Realm       = (*domainRealm)(nil)
+
+	// ErrMalformedDomainPolicy is returned when a policy cannot be loaded
+	ErrMalformedDomainPolicy = errors.New("malformed domain policy")
+)
+
+// WithDomain binds the active domain to the context
+func WithDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, domainCtxKey{}, domain)
+}
+
+// DomainFrom returns the active domain, or empty if none is bound
+func DomainFrom(ctx context.Context) string {
+	domain, _ := ctx.Value(domainCtxKey{}).(string)
+	return domain
+}
+
+// NewDomainRealm adapts the DomainRealm to a Realm which loads the Role(s) and
+// Authority(s) within the active domain, it can be wrapped by NewCachingRealm
+// since the cache is keyed by the active domain as well
+func NewDomainRealm(realm DomainRealm) Realm {
+	return &domainRealm{realm: realm}
+}
+
+// NewDomainAuthorizer returns an Authorizer which evaluates HasRole
+// and HasAuthority within the active domain bound by WithDomain
+func NewDomainAuthorizer(realm DomainRealm, realms ...DomainRealm) Authorizer {
+	adapters := make([]Realm, 0, len(realms))
+	for _, r := range realms {
+		adapters = append(adapters, NewDomainRealm(r))
+	}
+
+	return NewAuthorizer(NewDomainRealm(realm), adapters...)
+}
+
+func (r *domainRealm) LoadRoles(ctx context.Context, userDetails authc.UserDetails) ([]Role, error) {
+	return r.realm.LoadDomainRoles(ctx, userDetails, DomainFrom(ctx))
+}
+
+func (r *domainRealm) LoadAuthorities(ctx context.Context, userDetails authc.UserDetails) ([]Authority, error) {
+	return r.realm.LoadDomainAuthorities(ctx, userDetails, DomainFrom(ctx))
+}
+
+///=====================================
+///		    DomainPolicy
+///=====================================
+
+// WithDomainAuthorityParser specifies how authorities loaded by LoadCSV are created,
+// NewAuthority is used by default
+func WithDomainAuthorityParser(parse func(string) (Authority, error)) DomainPolicyOption {
+	return func(p *DomainPolicy) {
+		if parse != nil {
+			p.parse = parse
+		}
+	}
+}
+
+// NewDomainPolicy returns an empty DomainPolicy
+func NewDomainPolicy(opts ...DomainPolicyOption) *DomainPolicy {
+	p := &DomainPolicy{
+		parse: func(desc string) (Authority, error) {
+			return NewAuthority(desc), nil
+		},
+		roles:       make(map[string]map[string][]string),
+		authorities: make(map[string]map[string][]Authority),
+	}
+
+	for _, f := range opts {
+		f(p)
+	}
+
+	return p
+}
+
+// GrantRole grants the role to the subject within the domain, the
+// subject is a principal, or a role which then inherits the role
+func (p *DomainPolicy) GrantRole(subject string, role Role, domain string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grantRole(p.roles, subject, role.Desc(), domain)
+}
+
+// RevokeRole revokes the role from the subject within the domain
+func (p *DomainPolicy) RevokeRole(subject string, role Role, domain string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	roles := p.roles[domain][subject]
+	for i, r := range roles {
+		if r == role.Desc() {
+			p.roles[domain][subject] = append(roles[:i:i], roles[i+1:]...)
+			break
+		}
+	}
+}
+
+// GrantAuthority grants the authorities to the role within the domain
+func (p *DomainPolicy) GrantAuthority(role Role, domain string, authorities ...Authority) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	grantAuthority(p.authorities, role.Desc(), domain, authorities...)
+}
+
+// RevokeAuthority revokes the authorities from the role within the domain,
+// all authorities of the role are revoked if none is specified
+func (p *DomainPolicy) RevokeAuthority(role Role, domain string, authorities ...Authority) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(authorities) == 0 {
+		delete(p.authorities[domain], role.Desc())
+		return
+	}
+
+	revoked := make(map[string]bool, len(authorities))
+	for _, a := range authorities {
+		revoked[a.Desc()] = true
+	}
+
+	var kept []Authority
+	for _, a := range p.authorities[domain][role.Desc()] {
+		if !revoked[a.Desc()] {
+			kept = append(kept, a)
+		}
+	}
+
+	if granted, ok := p.authorities[domain]; ok {
+		granted[role.Desc()] = kept
+	}
+}
+
+// LoadCSV replaces the policy with Casbin style lines, e.g.
+//
+//	# role, domain, authority
+//	p, admin, org-a, doc:write
+//	# subject, role, domain
+//	g, alice, admin, org-a
+//	g, bob, viewer, *
+func (p *DomainPolicy) LoadCSV(data []byte) error {
+	roles := make(map[string]map[string][]string)
+	authorities := make(map[string]map[string][]Authority)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		if len(fields) != 4 || len(fields[1]) == 0 || len(fields[2]) == 0 || len(fields[3]) == 0 {
+			return fmt.Errorf("%w: line %d %q, expecting 4 fields", ErrMalformedDomainPolicy, n, line)
+		}
+
+		switch fields[0] {
+		case "p":
+			authority, err := p.parse(fields[3])
+			if err != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrMalformedDomainPolicy, n, err)
+			}
+
+			grantAuthority(authorities, fields[1], fields[2], authority)
+		case "g":
+			grantRole(roles, fields[1], fields[2], fields[3])
+		default:
+			return fmt.Errorf("%w: line %d %q, expecting p or g", ErrMalformedDomainPolicy, n, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.roles = roles
+	p.authorities = authorities
+	return nil
+}
+
+// Roles returns the roles of the subject within the domain, including
+// inherited roles and roles granted in AnyDomain
+func (p *DomainPolicy) Roles(subject string, domain string) []Role {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := p.roleNames(subject, domain)
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		roles = append(roles, NewRole(name))
+	}
+
+	return roles
+}
+
+// Authorities returns the authorities granted to the roles
+// of the subject within the domain and in AnyDomain
+func (p *DomainPolicy) Authorities(subject string, domain string) []Authority {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var authorities []Authority
+	for _, name := range p.roleNames(subject, domain) {
+		for _, d := range domains(domain) {
+			authorities = append(authorities, p.authorities[d][name]...)
+		}
+	}
+
+	return dedup(authorities)
+}
+
+// Domains returns the sorted domains the subject has roles in, except AnyDomain
+func (p *DomainPolicy) Domains(subject string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var result []string
+	for domain, subjects := range p.roles {
+		if domain != AnyDomain && len(subjects[subject]) != 0 {
+			result = append(result, domain)
+		}
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func (p *DomainPolicy) LoadDomainRoles(_ context.Context, userDetails authc.UserDetails, domain string) ([]Role, error) {
+	return p.Roles(userDetails.Principal(), domain), nil
+}
+
+func (p *DomainPolicy) LoadDomainAuthorities(_ context.Context, userDetails authc.UserDetails, domain string) ([]Authority, error) {
+	return p.Authorities(userDetails.Principal(), domain), nil
+}
+
+// roleNames walks the role graph breadth first, guarding against cycles
+func (p *DomainPolicy) roleNames(subject string, domain string) []string {
+	var result []string
+	seen := map[string]bool{subject: true}
+	queue := []string{subject}
+	for len(queue) != 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, d := range domains(domain) {
+			for _, role := range p.roles[d][current] {
+				if !seen[role] {
+					seen[role] = true
+					result = append(result, role)
+					queue = append(queue, role)
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// domains returns the domains whose grants apply within the
+// domain, only AnyDomain applies without active domain
+func domains(domain string) []string {
+	if len(domain) == 0 || domain == AnyDomain {
+		return []string{AnyDomain}
+	}
+
+	return []string{domain, AnyDomain}
+}
+
+func grantRole(roles map[string]map[string][]string, subject string, role string, domain string) {
+	subjects, ok := roles[domain]
+	if !ok {
+		subjects = make(map[string][]string)
+		roles[domain] = subjects
+	}
+
+	for _, r := range subjects[subject] {
+		if r == role {
+			return
+		}
+	}
+
+	subjects[subject] = append(subjects[subject], role)
+}
+
+func grantAuthority(authorities map[string]map[string][]Authority, role string, domain string, granted ...Authority) {
+	roles, ok := authorities[domain]
+	if !ok {
+		roles = make(map[string][]Authority)
+		authorities[domain] = roles
+	}
+
+	roles[role] = dedup(append(roles[role], granted...))
+}
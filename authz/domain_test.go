@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDomainPolicy(t *testing.T) {
+	p := NewDomainPolicy()
+	p.GrantRole("alice", role("admin"), "org-a")
+	p.GrantRole("alice", role("viewer"), "org-b")
+	p.GrantRole("bob", role("support"), AnyDomain)
+	p.GrantRole("admin", role("viewer"), "org-a")
+	// cycles are harmless
+	p.GrantRole("viewer", role("admin"), "org-a")
+	p.GrantAuthority(role("admin"), "org-a", authority("doc:write"), authority("doc:delete"))
+	p.GrantAuthority(role("viewer"), AnyDomain, authority("doc:read"))
+	p.GrantAuthority(role("support"), AnyDomain, authority("ticket:read"))
+
+	assert.Equal(t, []string{"admin", "viewer"}, descs(p.Roles("alice", "org-a")))
+	assert.Equal(t, []string{"viewer"}, descs(p.Roles("alice", "org-b")))
+	assert.Empty(t, p.Roles("alice", "org-c"))
+	assert.Empty(t, p.Roles("alice", ""))
+	assert.Equal(t, []string{"support"}, descs(p.Roles("bob", "org-c")))
+	assert.Equal(t, []string{"support"}, descs(p.Roles("bob", "")))
+
+	var authorities []string
+	for _, a := range p.Authorities("alice", "org-a") {
+		authorities = append(authorities, a.Desc())
+	}
+	assert.Equal(t, []string{"doc:write", "doc:delete", "doc:read"}, authorities)
+	assert.Len(t, p.Authorities("alice", "org-b"), 1)
+
+	assert.Equal(t, []string{"org-a", "org-b"}, p.Domains("alice"))
+	assert.Empty(t, p.Domains("bob"))
+
+	p.RevokeRole("alice", role("viewer"), "org-b")
+	assert.Empty(t, p.Roles("alice", "org-b"))
+
+	p.RevokeAuthority(role("admin"), "org-a", authority("doc:delete"))
+	assert.Len(t, p.Authorities("alice", "org-a"), 2)
+	p.RevokeAuthority(role("viewer"), AnyDomain)
+	assert.Len(t, p.Authorities("alice", "org-a"), 1)
+}
+
+func TestDomainAuthorizer(t *testing.T) {
+	p := NewDomainPolicy(WithDomainAuthorityParser(func(desc string) (Authority, error) {
+		return ParseWildcardAuthority(desc, true)
+	}))
+	assert.NoError(t, p.LoadCSV([]byte(`
+		# role, domain, authority
+		p, admin, org-a, doc:*
+		p, viewer, *, doc:read
+
+		# subject, role, domain
+		g, mockUd, admin, org-a
+		g, mockUd, viewer, org-b
+	`)))
+
+	azer := NewDomainAuthorizer(p)
+	orgA := WithDomain(context.Background(), "org-a")
+	orgB := WithDomain(context.Background(), "org-b")
+
+	assert.Equal(t, "org-a", DomainFrom(orgA))
+	assert.True(t, azer.HasRole(orgA, mockUd, role("admin")))
+	assert.True(t, azer.HasAuthority(orgA, mockUd, authority("doc:write")))
+	assert.False(t, azer.HasRole(orgB, mockUd, role("admin")))
+	assert.True(t, azer.HasAuthority(orgB, mockUd, authority("doc:read")))
+	assert.False(t, azer.HasAuthority(orgB, mockUd, authority("doc:write")))
+
+	// no active domain
+	err := azer.CheckRole(context.Background(), mockUd, role("admin"))
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func TestLoadCSVMalformed(t *testing.T) {
+	p := NewDomainPolicy()
+	p.GrantRole("alice", role("admin"), "org-a")
+
+	err := p.LoadCSV([]byte("g, alice, admin"))
+	assert.ErrorIs(t, err, ErrMalformedDomainPolicy)
+	assert.EqualError(t, err, `malformed domain policy: line 1 "g, alice, admin", expecting 4 fields`)
+
+	err = p.LoadCSV([]byte("g, alice, admin, org-a\nx, alice, admin, org-a"))
+	assert.EqualError(t, err, `malformed domain policy: line 2 "x, alice, admin, org-a", expecting p or g`)
+
+	p = NewDomainPolicy(WithDomainAuthorityParser(func(desc string) (Authority, error) {
+		return ParseWildcardAuthority(desc, true)
+	}))
+	p.GrantRole("alice", role("admin"), "org-a")
+	err = p.LoadCSV([]byte("g, alice, viewer, org-a\np, admin, org-a, doc::read"))
+	assert.ErrorIs(t, err, ErrMalformedDomainPolicy)
+
+	// failed loads keep the policy
+	assert.Equal(t, []string{"admin"}, descs(p.Roles("alice", "org-a")))
+}
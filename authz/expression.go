@@ -0,0 +1,817 @@
+package authz
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type (
+	// Expression is a compiled access expression, e.g.
+	//
+	//	hasRole('admin') or (hasAuthority('doc:write') and resource.owner == principal)
+	//
+	// Expressions support and/&&, or/||, not/!, the comparisons == != < <= > >=,
+	// in with a list or an attribute, string, number, boolean and null literals,
+	// [list] literals and parentheses. Identifiers refer to principal, action and
+	// the subject., resource. and environment. attributes of AccessRequest,
+	// comparisons with a missing attribute are false unless against null.
+	// The functions hasRole, hasAnyRole, hasAllRole, hasAuthority, hasAnyAuthority
+	// and hasAllAuthority call the Authorizer, hasAccess(action) calls the
+	// ResourceAuthorizer with the resource
+	Expression struct {
+		source string
+		root   exprNode
+	}
+
+	// ExpressionCache caches compiled expressions by source, the least
+	// recently used expression is evicted once the cache is full
+	ExpressionCache struct {
+		mu          sync.Mutex
+		size        int
+		lru         *list.List
+		expressions map[string]*list.Element
+	}
+
+	exprNode interface {
+		eval(*exprEnv) (any, error)
+	}
+
+	exprEnv struct {
+		ctx         context.Context
+		authorizer  Authorizer
+		userDetails authc.UserDetails
+		resource    any
+		req         *AccessRequest
+	}
+
+	literalNode struct {
+		value any
+	}
+
+	listNode struct {
+		items []exprNode
+	}
+
+	identNode struct {
+		path []string
+	}
+
+	notNode struct {
+		operand exprNode
+	}
+
+	logicalNode struct {
+		and         bool
+		left, right exprNode
+	}
+
+	compareNode struct {
+		op          string
+		left, right exprNode
+	}
+
+	callNode struct {
+		name string
+		args []exprNode
+	}
+
+	exprToken struct {
+		kind  exprTokenKind
+		text  string
+		value any
+		pos   int
+	}
+
+	exprTokenKind int
+
+	exprParser struct {
+		tokens []exprToken
+		pos    int
+		depth  int
+	}
+)
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenLiteral
+	tokenPunct
+)
+
+const (
+	// DefaultExpressionCacheSize is the size of NewExpressionCache
+	DefaultExpressionCacheSize = 1024
+
+	// maxExprDepth bounds the nesting of parentheses, lists,
+	// calls and negations so that parsing cannot exhaust the stack
+	maxExprDepth = 32
+)
+
+var (
+	// ErrMalformedExpression is returned when an expression cannot be compiled
+	ErrMalformedExpression = errors.New("malformed expression")
+
+	exprFunctions = map[string]struct {
+		minArgs, maxArgs int
+	}{
+		"hasRole":         {1, 1},
+		"hasAnyRole":      {1, -1},
+		"hasAllRole":      {1, -1},
+		"hasAuthority":    {1, 1},
+		"hasAnyAuthority": {1, -1},
+		"hasAllAuthority": {1, -1},
+		"hasAccess":       {1, 1},
+	}
+
+	exprRoots = map[string]bool{
+		"principal":   true,
+		"action":      true,
+		"subject":     true,
+		"resource":    true,
+		"environment": true,
+	}
+
+	exprKeywords = map[string]any{
+		"true":  true,
+		"false": false,
+		"null":  nil,
+	}
+)
+
+// CompileExpression parses and validates the expression, syntax errors,
+// unknown functions or identifiers, wrong argument counts and expressions
+// nested too deeply are reported as ErrMalformedExpression
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.unexpected(tok)
+	}
+
+	if err = checkBoolean(root); err != nil {
+		return nil, err
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// MustCompileExpression is like CompileExpression but panics if the expression is malformed
+func MustCompileExpression(source string) *Expression {
+	expr, err := CompileExpression(source)
+	if err != nil {
+		panic(err)
+	}
+
+	return expr
+}
+
+// String returns the source of the Expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate evaluates the Expression for the user and the resource, action
+// is available to the expression as action, the resource may be nil
+func (e *Expression) Evaluate(ctx context.Context, authorizer Authorizer, userDetails authc.UserDetails, action string, resource any) (bool, error) {
+	env := &exprEnv{
+		ctx:         ctx,
+		authorizer:  authorizer,
+		userDetails: userDetails,
+		resource:    resource,
+		req:         NewAccessRequest(ctx, userDetails, action, resource),
+	}
+
+	return evalBool(e.root, env)
+}
+
+// Check is like Evaluate but returns a ForbiddenError if the Expression
+// evaluates to false, or the failure of the Authorizer otherwise
+func (e *Expression) Check(ctx context.Context, authorizer Authorizer, userDetails authc.UserDetails, action string, resource any) error {
+	ok, err := e.Evaluate(ctx, authorizer, userDetails, action, resource)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &ForbiddenError{Requirement: e.source}
+	}
+
+	return nil
+}
+
+///=====================================
+///		    ExpressionCache
+///=====================================
+
+// NewExpressionCache returns an ExpressionCache of DefaultExpressionCacheSize
+func NewExpressionCache() *ExpressionCache {
+	return NewExpressionCacheSize(DefaultExpressionCacheSize)
+}
+
+// NewExpressionCacheSize returns an ExpressionCache which keeps at most size
+// expressions, DefaultExpressionCacheSize is used if size is not positive
+func NewExpressionCacheSize(size int) *ExpressionCache {
+	if size <= 0 {
+		size = DefaultExpressionCacheSize
+	}
+
+	return &ExpressionCache{
+		size:        size,
+		lru:         list.New(),
+		expressions: make(map[string]*list.Element),
+	}
+}
+
+// Compile returns the cached Expression, compiling it on first use,
+// malformed expressions are not cached
+func (c *ExpressionCache) Compile(source string) (*Expression, error) {
+	c.mu.Lock()
+	if elem, ok := c.expressions[source]; ok {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*Expression), nil
+	}
+	c.mu.Unlock()
+
+	expr, err := CompileExpression(source)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// keep the first one if compiled concurrently
+	if elem, ok := c.expressions[source]; ok {
+		return elem.Value.(*Expression), nil
+	}
+
+	c.expressions[source] = c.lru.PushFront(expr)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.expressions, oldest.Value.(*Expression).source)
+	}
+
+	return expr, nil
+}
+
+///=====================================
+///		    Nodes
+///=====================================
+
+func (n *literalNode) eval(*exprEnv) (any, error) {
+	return n.value, nil
+}
+
+func (n *listNode) eval(env *exprEnv) (any, error) {
+	items := make([]any, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, v)
+	}
+
+	return items, nil
+}
+
+func (n *identNode) eval(env *exprEnv) (any, error) {
+	switch n.path[0] {
+	case "principal":
+		return env.req.Subject["principal"], nil
+	case "action":
+		return env.req.Action, nil
+	case "subject":
+		return lookup(env.req.Subject, n.path[1:]), nil
+	case "resource":
+		return lookup(env.req.Resource, n.path[1:]), nil
+	default:
+		return lookup(env.req.Environment, n.path[1:]), nil
+	}
+}
+
+func (n *notNode) eval(env *exprEnv) (any, error) {
+	v, err := evalBool(n.operand, env)
+	if err != nil {
+		return false, err
+	}
+
+	return !v, nil
+}
+
+func (n *logicalNode) eval(env *exprEnv) (any, error) {
+	left, err := evalBool(n.left, env)
+	if err != nil {
+		return false, err
+	}
+
+	// short-circuit
+	if left != n.and {
+		return left, nil
+	}
+
+	return evalBool(n.right, env)
+}
+
+func (n *compareNode) eval(env *exprEnv) (any, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	// missing attributes never match, whatever the operator, so that e.g.
+	// resource.owner == subject.id is false if both are missing, only the
+	// null literal tests whether an attribute is missing, e.g. a == null
+	if (left == nil || right == nil) && !isNull(n.left) && !isNull(n.right) {
+		return false, nil
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return member(right, left), nil
+	}
+
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	c, err := compare(left, right)
+	if err != nil {
+		return false, err
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func isNull(node exprNode) bool {
+	n, ok := node.(*literalNode)
+	return ok && n.value == nil
+}
+
+func (n *callNode) eval(env *exprEnv) (any, error) {
+	args := make([]string, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return false, err
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("%s expects string arguments, got %T", n.name, v)
+		}
+
+		args = append(args, s)
+	}
+
+	var err error
+	switch n.name {
+	case "hasRole":
+		err = env.authorizer.CheckRole(env.ctx, env.userDetails, NewRole(args[0]))
+	case "hasAnyRole":
+		err = env.authorizer.CheckAnyRole(env.ctx, env.userDetails, roles(args)...)
+	case "hasAllRole":
+		err = env.authorizer.CheckAllRole(env.ctx, env.userDetails, roles(args)...)
+	case "hasAuthority":
+		err = env.authorizer.CheckAuthority(env.ctx, env.userDetails, NewAuthority(args[0]))
+	case "hasAnyAuthority":
+		err = env.authorizer.CheckAnyAuthority(env.ctx, env.userDetails, authorities(args)...)
+	case "hasAllAuthority":
+		err = env.authorizer.CheckAllAuthority(env.ctx, env.userDetails, authorities(args)...)
+	default:
+		ra, ok := env.authorizer.(ResourceAuthorizer)
+		if !ok {
+			return false, errors.New("hasAccess requires a ResourceAuthorizer")
+		}
+		err = ra.CheckAccess(env.ctx, env.userDetails, args[0], env.resource)
+	}
+
+	if errors.Is(err, ErrForbidden) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func evalBool(node exprNode, env *exprEnv) (bool, error) {
+	v, err := node.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expecting boolean, got %T", v)
+	}
+
+	return b, nil
+}
+
+// checkBoolean rejects operands that can never be boolean
+func checkBoolean(nodes ...exprNode) error {
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *literalNode:
+			if _, ok := n.value.(bool); !ok {
+				return fmt.Errorf("%w: %v is not a boolean", ErrMalformedExpression, n.value)
+			}
+		case *listNode:
+			return fmt.Errorf("%w: list is not a boolean", ErrMalformedExpression)
+		}
+	}
+
+	return nil
+}
+
+func roles(names []string) []Role {
+	result := make([]Role, 0, len(names))
+	for _, name := range names {
+		result = append(result, NewRole(name))
+	}
+
+	return result
+}
+
+func authorities(names []string) []Authority {
+	result := make([]Authority, 0, len(names))
+	for _, name := range names {
+		result = append(result, NewAuthority(name))
+	}
+
+	return result
+}
+
+///=====================================
+///		    Parser
+///=====================================
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *exprParser) accept(texts ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenPunct && tok.kind != tokenIdent {
+		return "", false
+	}
+
+	for _, text := range texts {
+		if tok.text == text {
+			p.pos++
+			return text, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *exprParser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		return p.unexpected(p.peek())
+	}
+
+	return nil
+}
+
+func (p *exprParser) unexpected(tok exprToken) error {
+	if tok.kind == tokenEOF {
+		return fmt.Errorf("%w: unexpected end", ErrMalformedExpression)
+	}
+
+	return fmt.Errorf("%w: unexpected %q at %d", ErrMalformedExpression, tok.text, tok.pos)
+}
+
+// enter is called by every recursive rule, the caller must defer leave
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return fmt.Errorf("%w: nested deeper than %d", ErrMalformedExpression, maxExprDepth)
+	}
+
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("or", "||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = checkBoolean(left, right); err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("and", "&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = checkBoolean(left, right); err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = checkBoolean(operand); err != nil {
+			return nil, err
+		}
+
+		return &notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">", "in")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &compareNode{op: op, left: left, right: right}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenLiteral:
+		return &literalNode{value: tok.value}, nil
+	case tok.kind == tokenPunct && tok.text == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		return node, p.expect(")")
+	case tok.kind == tokenPunct && tok.text == "[":
+		items, err := p.parseList("]")
+		if err != nil {
+			return nil, err
+		}
+
+		return &listNode{items: items}, nil
+	case tok.kind == tokenIdent:
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(tok)
+		}
+
+		return p.parseIdent(tok)
+	default:
+		return nil, p.unexpected(tok)
+	}
+}
+
+func (p *exprParser) parseList(end string) ([]exprNode, error) {
+	var items []exprNode
+	if _, ok := p.accept(end); ok {
+		return items, nil
+	}
+
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+		if _, ok := p.accept(end); ok {
+			return items, nil
+		}
+
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fn, ok := exprFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown function %q at %d", ErrMalformedExpression, name.text, name.pos)
+	}
+
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("%w: wrong number of arguments for %s at %d", ErrMalformedExpression, name.text, name.pos)
+	}
+
+	for _, arg := range args {
+		if lit, ok := arg.(*literalNode); ok {
+			if _, ok = lit.value.(string); !ok {
+				return nil, fmt.Errorf("%w: %s expects string arguments at %d", ErrMalformedExpression, name.text, name.pos)
+			}
+		}
+	}
+
+	return &callNode{name: name.text, args: args}, nil
+}
+
+func (p *exprParser) parseIdent(tok exprToken) (exprNode, error) {
+	if !exprRoots[tok.text] {
+		return nil, fmt.Errorf("%w: unknown identifier %q at %d", ErrMalformedExpression, tok.text, tok.pos)
+	}
+
+	path := []string{tok.text}
+	for {
+		if _, ok := p.accept("."); !ok {
+			break
+		}
+
+		field := p.next()
+		if field.kind != tokenIdent {
+			return nil, p.unexpected(field)
+		}
+
+		path = append(path, field.text)
+	}
+
+	if (tok.text == "principal" || tok.text == "action") && len(path) > 1 {
+		return nil, fmt.Errorf("%w: %s has no attributes at %d", ErrMalformedExpression, tok.text, tok.pos)
+	}
+
+	return &identNode{path: path}, nil
+}
+
+///=====================================
+///		    Lexer
+///=====================================
+
+func tokenize(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+
+			if j == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at %d", ErrMalformedExpression, i)
+			}
+
+			tokens = append(tokens, exprToken{kind: tokenLiteral, text: string(runes[i : j+1]), value: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+
+			text := string(runes[i:j])
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid number %q at %d", ErrMalformedExpression, text, i)
+			}
+
+			tokens = append(tokens, exprToken{kind: tokenLiteral, text: text, value: n, pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+
+			text := string(runes[i:j])
+			if value, ok := exprKeywords[text]; ok {
+				tokens = append(tokens, exprToken{kind: tokenLiteral, text: text, value: value, pos: i})
+			} else {
+				tokens = append(tokens, exprToken{kind: tokenIdent, text: text, pos: i})
+			}
+			i = j
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				switch pair := string(runes[i : i+2]); pair {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					text = pair
+				}
+			}
+
+			if !strings.Contains("()[],.!<>", text) && len(text) == 1 {
+				return nil, fmt.Errorf("%w: unexpected %q at %d", ErrMalformedExpression, text, i)
+			}
+
+			tokens = append(tokens, exprToken{kind: tokenPunct, text: text, pos: i})
+			i += len([]rune(text))
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokenEOF, pos: len(runes)}), nil
+}
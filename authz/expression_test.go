@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExpression(t *testing.T) {
+	azer := NewPolicyAuthorizer(NewAuthorizer(&mockRealm{}), NewPolicyEngine(&Policy{
+		Name:    "read-public",
+		Effect:  Permit,
+		Actions: []string{"read"},
+		Condition: ConditionFunc(func(req *AccessRequest) (bool, error) {
+			return req.Resource["public"] == true, nil
+		}),
+	}))
+
+	doc := Attributes{"owner": "mockUd", "level": 2, "public": true, "tags": []string{"draft"}}
+	ctx := WithSubjectAttributes(context.Background(), Attributes{"level": 3})
+
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{"hasRole('a')", true},
+		{"hasRole('admin') or (hasAuthority('write') and resource.owner == principal)", true},
+		{"hasRole('admin') || hasAuthority('delete')", false},
+		{"hasAnyRole('x', 'b') && hasAllAuthority('read', 'write')", true},
+		{"hasAllRole('a', 'x')", false},
+		{"not hasRole('x') and !hasAnyAuthority('x', 'y')", true},
+		{"resource.owner != principal", false},
+		{"subject.level > resource.level and resource.level >= 2", true},
+		{"subject.level < 3 or resource.level <= 1.5", false},
+		{"resource.missing < 3", false},
+		{"principal in ['root', \"mockUd\"]", true},
+		{"'draft' in resource.tags", true},
+		{"resource.missing == null", true},
+		{"resource.owner != null", true},
+		{"resource.missing != null", false},
+		// missing attributes never match, even on both sides
+		{"resource.missing == subject.missing", false},
+		{"resource.missing != subject.missing", false},
+		{"resource.missing != 'mockUd'", false},
+		{"resource.missing in subject.missing", false},
+		{"resource.missing in ['a']", false},
+		{"'draft' in resource.missing", false},
+		{"action == 'edit'", true},
+		{"hasAccess('read')", true},
+		{"hasAccess('edit')", false},
+		{"true and (false or resource.public)", true},
+	}
+
+	for _, tt := range tests {
+		expr, err := CompileExpression(tt.source)
+		assert.NoError(t, err, tt.source)
+
+		ok, err := expr.Evaluate(ctx, azer, mockUd, "edit", doc)
+		assert.NoError(t, err, tt.source)
+		assert.Equal(t, tt.expected, ok, tt.source)
+	}
+
+	expr := MustCompileExpression("hasRole('admin')")
+	err := expr.Check(ctx, azer, mockUd, "edit", nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.EqualError(t, err, "forbidden: hasRole('admin')")
+
+	// realm failures are not hidden as false
+	expr = MustCompileExpression("hasRole('admin') or true")
+	_, err = expr.Evaluate(ctx, NewAuthorizer(&failingRealm{}), mockUd, "", nil)
+	assert.ErrorContains(t, err, "database down")
+
+	expr = MustCompileExpression("hasAccess('read')")
+	_, err = expr.Evaluate(ctx, NewAuthorizer(&mockRealm{}), mockUd, "", nil)
+	assert.Error(t, err)
+
+	expr = MustCompileExpression("resource.owner and true")
+	_, err = expr.Evaluate(ctx, azer, mockUd, "", doc)
+	assert.EqualError(t, err, "expecting boolean, got string")
+}
+
+func TestCompileExpression(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "malformed expression: unexpected end"},
+		{"hasRole('admin'", "malformed expression: unexpected end"},
+		{"hasRole('admin') and", "malformed expression: unexpected end"},
+		{"hasRole('admin'))", `malformed expression: unexpected ")" at 16`},
+		{"isAdmin()", `malformed expression: unknown function "isAdmin" at 0`},
+		{"hasRole('a', 'b')", "malformed expression: wrong number of arguments for hasRole at 0"},
+		{"hasAnyRole()", "malformed expression: wrong number of arguments for hasAnyRole at 0"},
+		{"hasRole(1)", "malformed expression: hasRole expects string arguments at 0"},
+		{"user.name == 'a'", `malformed expression: unknown identifier "user" at 0`},
+		{"principal.name == 'a'", "malformed expression: principal has no attributes at 0"},
+		{"resource.owner = principal", `malformed expression: unexpected "=" at 15`},
+		{"'admin' or true", "malformed expression: admin is not a boolean"},
+		{"not 1", "malformed expression: 1 is not a boolean"},
+		{"'unterminated", "malformed expression: unterminated string at 0"},
+	}
+
+	for _, tt := range tests {
+		_, err := CompileExpression(tt.source)
+		assert.ErrorIs(t, err, ErrMalformedExpression, tt.source)
+		assert.EqualError(t, err, tt.err, tt.source)
+	}
+
+	assert.Panics(t, func() { MustCompileExpression("(") })
+}
+
+func TestExpressionCache(t *testing.T) {
+	cache := NewExpressionCache()
+
+	expr, err := cache.Compile("hasRole('a')")
+	assert.NoError(t, err)
+
+	cached, err := cache.Compile("hasRole('a')")
+	assert.NoError(t, err)
+	assert.Same(t, expr, cached)
+
+	_, err = cache.Compile("hasRole(")
+	assert.ErrorIs(t, err, ErrMalformedExpression)
+	assert.Len(t, cache.expressions, 1)
+
+	// the least recently used expression is evicted
+	cache = NewExpressionCacheSize(2)
+	a, _ := cache.Compile("hasRole('a')")
+	_, _ = cache.Compile("hasRole('b')")
+	_, _ = cache.Compile("hasRole('a')")
+	_, _ = cache.Compile("hasRole('c')")
+	assert.Len(t, cache.expressions, 2)
+	assert.Contains(t, cache.expressions, "hasRole('a')")
+	assert.NotContains(t, cache.expressions, "hasRole('b')")
+
+	cached, _ = cache.Compile("hasRole('a')")
+	assert.Same(t, a, cached)
+}
+
+func TestExpressionDepth(t *testing.T) {
+	nested := func(n int, open string, close string) string {
+		return strings.Repeat(open, n) + "hasRole('a')" + strings.Repeat(close, n)
+	}
+
+	_, err := CompileExpression(nested(20, "(", ")"))
+	assert.NoError(t, err)
+
+	_, err = CompileExpression(nested(10000, "(", ")"))
+	assert.ErrorIs(t, err, ErrMalformedExpression)
+
+	_, err = CompileExpression(nested(10000, "not ", ""))
+	assert.ErrorIs(t, err, ErrMalformedExpression)
+
+	_, err = CompileExpression(nested(10000, "[", "]") + " in [1]")
+	assert.ErrorIs(t, err, ErrMalformedExpression)
+}
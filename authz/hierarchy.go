@@ -0,0 +1,256 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"sort"
+	"strings"
+)
+
+type (
+	// RoleHierarchy describes which roles are implied by a role, it is built
+	// from definitions like "ADMIN > MANAGER > USER", meaning that ADMIN
+	// implies MANAGER and USER, and MANAGER implies USER
+	RoleHierarchy struct {
+		// reachable maps a role to all roles it implies, excluding itself
+		reachable map[string][]string
+		// reachedBy maps a role to all roles that imply it, excluding itself
+		reachedBy map[string][]string
+	}
+
+	hierarchicalAuthorizer struct {
+		authorizer Authorizer
+		hierarchy  *RoleHierarchy
+	}
+)
+
+var (
+	_ Authorizer        = (*hierarchicalAuthorizer)(nil)
+	_ authc.LogoutAware = (*hierarchicalAuthorizer)(nil)
+
+	// ErrMalformedHierarchy is returned when a role hierarchy definition cannot be parsed
+	ErrMalformedHierarchy = errors.New("malformed role hierarchy")
+	// ErrHierarchyCycle is returned when a role implies itself
+	ErrHierarchyCycle = errors.New("role hierarchy cycle")
+)
+
+// ParseRoleHierarchy parses the definition, each line is a chain of
+// roles separated by ">", the higher role comes first, e.g.
+//
+//	ADMIN > MANAGER > USER
+//	ADMIN > AUDITOR
+func ParseRoleHierarchy(definition string) (*RoleHierarchy, error) {
+	direct := make(map[string][]string)
+	for n, line := range strings.Split(definition, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		roles := strings.Split(line, ">")
+		if len(roles) < 2 {
+			return nil, fmt.Errorf("%w: line %d %q has no '>'", ErrMalformedHierarchy, n+1, line)
+		}
+
+		for i := range roles {
+			roles[i] = strings.TrimSpace(roles[i])
+			if len(roles[i]) == 0 {
+				return nil, fmt.Errorf("%w: line %d %q has an empty role", ErrMalformedHierarchy, n+1, line)
+			}
+		}
+
+		for i := 0; i < len(roles)-1; i++ {
+			direct[roles[i]] = append(direct[roles[i]], roles[i+1])
+		}
+	}
+
+	h := &RoleHierarchy{
+		reachable: make(map[string][]string, len(direct)),
+		reachedBy: make(map[string][]string),
+	}
+
+	for role := range direct {
+		reachable, err := reach(direct, role, []string{role}, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(reachable)
+		h.reachable[role] = reachable
+		for _, r := range reachable {
+			h.reachedBy[r] = append(h.reachedBy[r], role)
+		}
+	}
+
+	for _, roles := range h.reachedBy {
+		sort.Strings(roles)
+	}
+
+	return h, nil
+}
+
+// MustParseRoleHierarchy is like ParseRoleHierarchy but panics if the definition is malformed
+func MustParseRoleHierarchy(definition string) *RoleHierarchy {
+	h, err := ParseRoleHierarchy(definition)
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// ImpliedRoles returns the roles implied by the role, excluding itself
+func (h *RoleHierarchy) ImpliedRoles(role Role) []Role {
+	reachable := h.reachable[role.Desc()]
+	roles := make([]Role, 0, len(reachable))
+	for _, r := range reachable {
+		roles = append(roles, NewRole(r))
+	}
+
+	return roles
+}
+
+// ReachableRoles returns the roles together with all roles implied by them
+func (h *RoleHierarchy) ReachableRoles(roles ...Role) []Role {
+	seen := make(map[string]bool)
+	result := make([]Role, 0, len(roles))
+	for _, role := range roles {
+		if !seen[role.Desc()] {
+			seen[role.Desc()] = true
+			result = append(result, role)
+		}
+
+		for _, r := range h.reachable[role.Desc()] {
+			if !seen[r] {
+				seen[r] = true
+				result = append(result, NewRole(r))
+			}
+		}
+	}
+
+	return result
+}
+
+// implyingRoles returns the role together with all roles that imply it
+func (h *RoleHierarchy) implyingRoles(role Role) []Role {
+	reachedBy := h.reachedBy[role.Desc()]
+	roles := make([]Role, 0, len(reachedBy)+1)
+	roles = append(roles, role)
+	for _, r := range reachedBy {
+		roles = append(roles, NewRole(r))
+	}
+
+	return roles
+}
+
+func reach(direct map[string][]string, role string, path []string, seen map[string]bool) ([]string, error) {
+	var reachable []string
+	for _, next := range direct[role] {
+		if contains(path, next) {
+			return nil, fmt.Errorf("%w: %s", ErrHierarchyCycle, strings.Join(append(path, next), " > "))
+		}
+
+		if seen[next] {
+			continue
+		}
+
+		seen[next] = true
+		reachable = append(reachable, next)
+
+		more, err := reach(direct, next, append(path, next), seen)
+		if err != nil {
+			return nil, err
+		}
+
+		reachable = append(reachable, more...)
+	}
+
+	return reachable, nil
+}
+
+///=====================================
+///		    Authorizer
+///=====================================
+
+// NewHierarchicalAuthorizer wraps the Authorizer so that
+// HasRole, HasAnyRole and HasAllRole respect the RoleHierarchy
+func NewHierarchicalAuthorizer(authorizer Authorizer, hierarchy *RoleHierarchy) Authorizer {
+	return &hierarchicalAuthorizer{authorizer: authorizer, hierarchy: hierarchy}
+}
+
+func (z *hierarchicalAuthorizer) HasRole(ctx context.Context, userDetails authc.UserDetails, role Role) bool {
+	return z.CheckRole(ctx, userDetails, role) == nil
+}
+
+func (z *hierarchicalAuthorizer) HasAnyRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
+	return z.CheckAnyRole(ctx, userDetails, roles...) == nil
+}
+
+func (z *hierarchicalAuthorizer) HasAllRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) bool {
+	return z.CheckAllRole(ctx, userDetails, roles...) == nil
+}
+
+func (z *hierarchicalAuthorizer) HasAuthority(ctx context.Context, userDetails authc.UserDetails, authority Authority) bool {
+	return z.authorizer.HasAuthority(ctx, userDetails, authority)
+}
+
+func (z *hierarchicalAuthorizer) HasAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
+	return z.authorizer.HasAnyAuthority(ctx, userDetails, authorities...)
+}
+
+func (z *hierarchicalAuthorizer) HasAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) bool {
+	return z.authorizer.HasAllAuthority(ctx, userDetails, authorities...)
+}
+
+func (z *hierarchicalAuthorizer) CheckRole(ctx context.Context, userDetails authc.UserDetails, role Role) error {
+	return z.checkAnyRole(ctx, userDetails, Requirement("hasRole", role), role)
+}
+
+func (z *hierarchicalAuthorizer) CheckAnyRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) error {
+	return z.checkAnyRole(ctx, userDetails, Requirement("hasAnyRole", roles...), roles...)
+}
+
+func (z *hierarchicalAuthorizer) CheckAllRole(ctx context.Context, userDetails authc.UserDetails, roles ...Role) error {
+	for _, role := range roles {
+		if err := z.CheckRole(ctx, userDetails, role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *hierarchicalAuthorizer) CheckAuthority(ctx context.Context, userDetails authc.UserDetails, authority Authority) error {
+	return z.authorizer.CheckAuthority(ctx, userDetails, authority)
+}
+
+func (z *hierarchicalAuthorizer) CheckAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) error {
+	return z.authorizer.CheckAnyAuthority(ctx, userDetails, authorities...)
+}
+
+func (z *hierarchicalAuthorizer) CheckAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...Authority) error {
+	return z.authorizer.CheckAllAuthority(ctx, userDetails, authorities...)
+}
+
+func (z *hierarchicalAuthorizer) checkAnyRole(ctx context.Context, userDetails authc.UserDetails, requirement string, roles ...Role) error {
+	var implying []Role
+	for _, role := range roles {
+		implying = append(implying, z.hierarchy.implyingRoles(role)...)
+	}
+
+	err := z.authorizer.CheckAnyRole(ctx, userDetails, implying...)
+	if errors.Is(err, ErrForbidden) {
+		// report the role asked for rather than the roles implying it
+		return &ForbiddenError{Requirement: requirement}
+	}
+
+	return err
+}
+
+func (z *hierarchicalAuthorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	if la, ok := z.authorizer.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
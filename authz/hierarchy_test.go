@@ -0,0 +1,84 @@
+package authz
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type rolesRealm struct {
+	roles []Role
+}
+
+func (r *rolesRealm) LoadRoles(context.Context, authc.UserDetails) ([]Role, error) {
+	return r.roles, nil
+}
+
+func (r *rolesRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]Authority, error) {
+	return []Authority{authority("read")}, nil
+}
+
+func descs(roles []Role) []string {
+	result := make([]string, 0, len(roles))
+	for _, r := range roles {
+		result = append(result, r.Desc())
+	}
+
+	return result
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	h := MustParseRoleHierarchy(`
+		ADMIN > MANAGER > USER
+		ADMIN > AUDITOR
+		AUDITOR > GUEST
+	`)
+
+	assert.Equal(t, []string{"AUDITOR", "GUEST", "MANAGER", "USER"}, descs(h.ImpliedRoles(role("ADMIN"))))
+	assert.Equal(t, []string{"USER"}, descs(h.ImpliedRoles(role("MANAGER"))))
+	assert.Empty(t, h.ImpliedRoles(role("USER")))
+	assert.Empty(t, h.ImpliedRoles(role("UNKNOWN")))
+
+	assert.Equal(t, []string{"MANAGER", "USER", "AUDITOR", "GUEST"}, descs(h.ReachableRoles(role("MANAGER"), role("AUDITOR"))))
+}
+
+func TestParseRoleHierarchy(t *testing.T) {
+	_, err := ParseRoleHierarchy("ADMIN > MANAGER\nMANAGER > USER > ADMIN")
+	assert.ErrorIs(t, err, ErrHierarchyCycle)
+
+	_, err = ParseRoleHierarchy("ADMIN > ADMIN")
+	assert.ErrorIs(t, err, ErrHierarchyCycle)
+
+	_, err = ParseRoleHierarchy("ADMIN > > USER")
+	assert.ErrorIs(t, err, ErrMalformedHierarchy)
+
+	_, err = ParseRoleHierarchy("ADMIN > MANAGER\nUSER")
+	assert.EqualError(t, err, `malformed role hierarchy: line 2 "USER" has no '>'`)
+
+	h, err := ParseRoleHierarchy("")
+	assert.NoError(t, err)
+	assert.Empty(t, h.ImpliedRoles(role("ADMIN")))
+
+	assert.Panics(t, func() { MustParseRoleHierarchy("A > B > A") })
+}
+
+func TestHierarchicalAuthorizer(t *testing.T) {
+	h := MustParseRoleHierarchy("ADMIN > MANAGER > USER")
+	manager := NewHierarchicalAuthorizer(NewAuthorizer(&rolesRealm{roles: []Role{role("MANAGER")}}), h)
+
+	assert.True(t, manager.HasRole(context.TODO(), mockUd, role("MANAGER")))
+	assert.True(t, manager.HasRole(context.TODO(), mockUd, role("USER")))
+	assert.False(t, manager.HasRole(context.TODO(), mockUd, role("ADMIN")))
+
+	assert.True(t, manager.HasAnyRole(context.TODO(), mockUd, role("ADMIN"), role("USER")))
+	assert.False(t, manager.HasAnyRole(context.TODO(), mockUd, role("ADMIN"), role("OTHER")))
+	assert.True(t, manager.HasAllRole(context.TODO(), mockUd, role("MANAGER"), role("USER")))
+	assert.False(t, manager.HasAllRole(context.TODO(), mockUd, role("ADMIN"), role("USER")))
+
+	assert.True(t, manager.HasAuthority(context.TODO(), mockUd, authority("read")))
+
+	assert.NoError(t, manager.CheckRole(context.TODO(), mockUd, role("USER")))
+	assert.EqualError(t, manager.CheckRole(context.TODO(), mockUd, role("ADMIN")), "forbidden: hasRole(ADMIN)")
+	assert.EqualError(t, manager.CheckAllRole(context.TODO(), mockUd, role("USER"), role("ADMIN")), "forbidden: hasRole(ADMIN)")
+}
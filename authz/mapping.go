@@ -0,0 +1,237 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// RoleMapping declares which authorities are granted by a role, e.g.
+	// role "editor" grants "doc:read" and "doc:write". The expansion of
+	// roles is cached and the cache is invalidated whenever the mapping changes
+	RoleMapping struct {
+		mu     sync.RWMutex
+		parse  func(string) (Authority, error)
+		grants map[string][]Authority
+		cache  map[string][]Authority
+	}
+
+	// RoleMappingOption can be used to customize RoleMapping
+	RoleMappingOption func(*RoleMapping)
+
+	roleMappingRealm struct {
+		realm   Realm
+		mapping *RoleMapping
+	}
+)
+
+var (
+	_ Realm             = (*roleMappingRealm)(nil)
+	_ authc.LogoutAware = (*roleMappingRealm)(nil)
+)
+
+// WithAuthorityParser specifies how authorities in the mapping are created,
+// NewAuthority is used by default, e.g. use ParseWildcardAuthority to
+// grant wildcard authorities
+func WithAuthorityParser(parse func(string) (Authority, error)) RoleMappingOption {
+	return func(m *RoleMapping) {
+		if parse != nil {
+			m.parse = parse
+		}
+	}
+}
+
+// NewRoleMapping returns an empty RoleMapping
+func NewRoleMapping(opts ...RoleMappingOption) *RoleMapping {
+	m := &RoleMapping{
+		parse: func(desc string) (Authority, error) {
+			return NewAuthority(desc), nil
+		},
+		grants: make(map[string][]Authority),
+		cache:  make(map[string][]Authority),
+	}
+
+	for _, f := range opts {
+		f(m)
+	}
+
+	return m
+}
+
+// Grant grants the authorities to the role
+func (m *RoleMapping) Grant(role Role, authorities ...Authority) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.grants[role.Desc()] = dedup(append(m.grants[role.Desc()], authorities...))
+	m.invalidate()
+}
+
+// Revoke revokes the authorities from the role, all authorities
+// of the role are revoked if none is specified
+func (m *RoleMapping) Revoke(role Role, authorities ...Authority) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(authorities) == 0 {
+		delete(m.grants, role.Desc())
+		m.invalidate()
+		return
+	}
+
+	revoked := make(map[string]bool, len(authorities))
+	for _, a := range authorities {
+		revoked[a.Desc()] = true
+	}
+
+	kept := m.grants[role.Desc()][:0]
+	for _, a := range m.grants[role.Desc()] {
+		if !revoked[a.Desc()] {
+			kept = append(kept, a)
+		}
+	}
+
+	m.grants[role.Desc()] = kept
+	m.invalidate()
+}
+
+// Replace replaces the whole mapping, it is usually used to reload
+// the mapping, authorities are parsed by the authority parser
+func (m *RoleMapping) Replace(mapping map[string][]string) error {
+	grants := make(map[string][]Authority, len(mapping))
+	for role, descs := range mapping {
+		role = strings.TrimSpace(role)
+		authorities := make([]Authority, 0, len(descs))
+		for _, desc := range descs {
+			authority, err := m.parse(desc)
+			if err != nil {
+				return fmt.Errorf("role %q: %w", role, err)
+			}
+
+			authorities = append(authorities, authority)
+		}
+
+		grants[role] = dedup(append(grants[role], authorities...))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.grants = grants
+	m.invalidate()
+	return nil
+}
+
+// LoadJSON replaces the mapping with a JSON object, e.g.
+//
+//	{"editor": ["doc:read", "doc:write"]}
+func (m *RoleMapping) LoadJSON(data []byte) error {
+	var mapping map[string][]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return err
+	}
+
+	return m.Replace(mapping)
+}
+
+// LoadYAML replaces the mapping with a YAML mapping, e.g.
+//
+//	editor: [doc:read, doc:write]
+func (m *RoleMapping) LoadYAML(data []byte) error {
+	var mapping map[string][]string
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return err
+	}
+
+	return m.Replace(mapping)
+}
+
+// Authorities returns the authorities granted by the roles
+func (m *RoleMapping) Authorities(roles ...Role) []Authority {
+	descs := make([]string, 0, len(roles))
+	for _, r := range roles {
+		descs = append(descs, r.Desc())
+	}
+	sort.Strings(descs)
+	key := strings.Join(descs, "\x00")
+
+	m.mu.RLock()
+	authorities, ok := m.cache[key]
+	m.mu.RUnlock()
+	if ok {
+		return authorities
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, desc := range descs {
+		authorities = append(authorities, m.grants[desc]...)
+	}
+
+	authorities = dedup(authorities)
+	m.cache[key] = authorities
+	return authorities
+}
+
+func (m *RoleMapping) invalidate() {
+	m.cache = make(map[string][]Authority)
+}
+
+func dedup(authorities []Authority) []Authority {
+	seen := make(map[string]bool, len(authorities))
+	result := make([]Authority, 0, len(authorities))
+	for _, a := range authorities {
+		if !seen[a.Desc()] {
+			seen[a.Desc()] = true
+			result = append(result, a)
+		}
+	}
+
+	return result
+}
+
+///=====================================
+///		    Realm
+///=====================================
+
+// NewRoleMappingRealm wraps the Realm so that LoadAuthorities also
+// returns the authorities granted by the roles of the user
+func NewRoleMappingRealm(realm Realm, mapping *RoleMapping) Realm {
+	return &roleMappingRealm{realm: realm, mapping: mapping}
+}
+
+func (r *roleMappingRealm) LoadRoles(ctx context.Context, userDetails authc.UserDetails) ([]Role, error) {
+	return r.realm.LoadRoles(ctx, userDetails)
+}
+
+func (r *roleMappingRealm) LoadAuthorities(ctx context.Context, userDetails authc.UserDetails) ([]Authority, error) {
+	authorities, err := r.realm.LoadAuthorities(ctx, userDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := r.realm.LoadRoles(ctx, userDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := r.mapping.Authorities(roles...)
+	if len(granted) == 0 {
+		return authorities, nil
+	}
+
+	return dedup(append(append(make([]Authority, 0, len(authorities)+len(granted)), authorities...), granted...)), nil
+}
+
+func (r *roleMappingRealm) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	if la, ok := r.realm.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
@@ -0,0 +1,56 @@
+package authz
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRoleMapping(t *testing.T) {
+	mapping := NewRoleMapping()
+	mapping.Grant(role("editor"), authority("doc:read"), authority("doc:write"))
+	mapping.Grant(role("viewer"), authority("doc:read"))
+
+	azer := NewAuthorizer(NewRoleMappingRealm(&rolesRealm{roles: []Role{role("editor")}}, mapping))
+	assert.True(t, azer.HasAuthority(context.TODO(), mockUd, authority("doc:write")))
+	assert.True(t, azer.HasAllAuthority(context.TODO(), mockUd, authority("doc:read"), authority("read")))
+	assert.False(t, azer.HasAuthority(context.TODO(), mockUd, authority("doc:delete")))
+
+	assert.Equal(t, 2, len(mapping.Authorities(role("editor"), role("viewer"))))
+
+	// the cache is invalidated once the mapping changes
+	mapping.Revoke(role("editor"), authority("doc:write"))
+	assert.False(t, azer.HasAuthority(context.TODO(), mockUd, authority("doc:write")))
+	assert.True(t, azer.HasAuthority(context.TODO(), mockUd, authority("doc:read")))
+
+	mapping.Revoke(role("editor"))
+	assert.False(t, azer.HasAuthority(context.TODO(), mockUd, authority("doc:read")))
+}
+
+func TestLoadRoleMapping(t *testing.T) {
+	mapping := NewRoleMapping()
+	assert.NoError(t, mapping.LoadJSON([]byte(`{"editor": ["doc:read", "doc:write", "doc:read"]}`)))
+	assert.Equal(t, []Authority{authority("doc:read"), authority("doc:write")}, mapping.Authorities(role("editor")))
+
+	assert.NoError(t, mapping.LoadYAML([]byte("admin:\n  - doc:*\nviewer: [doc:read]\n")))
+	assert.Empty(t, mapping.Authorities(role("editor")))
+	assert.Equal(t, []Authority{authority("doc:*"), authority("doc:read")}, mapping.Authorities(role("viewer"), role("admin")))
+
+	assert.Error(t, mapping.LoadJSON([]byte(`{"editor": "doc:read"}`)))
+	assert.Error(t, mapping.LoadYAML([]byte("editor: {")))
+}
+
+func TestRoleMappingWithWildcard(t *testing.T) {
+	mapping := NewRoleMapping(WithAuthorityParser(func(desc string) (Authority, error) {
+		return ParseWildcardAuthority(desc, false)
+	}))
+	assert.NoError(t, mapping.LoadYAML([]byte("admin: [\"doc:*\"]\n")))
+
+	azer := NewAuthorizer(NewRoleMappingRealm(&rolesRealm{roles: []Role{role("admin")}}, mapping))
+	assert.True(t, azer.HasAuthority(context.TODO(), mockUd, NewAuthority("doc:delete:42")))
+
+	err := mapping.LoadYAML([]byte("admin: [\"doc::read\"]\n"))
+	assert.ErrorIs(t, err, ErrMalformedAuthority)
+	// the previous mapping is kept on error
+	assert.True(t, azer.HasAuthority(context.TODO(), mockUd, NewAuthority("doc:delete:42")))
+}
@@ -45,5 +45,21 @@ type (
 		HasAnyAuthority(context.Context, authc.UserDetails, ...Authority) bool
 		// HasAllAuthority specifies that a user requires all of authorities
 		HasAllAuthority(context.Context, authc.UserDetails, ...Authority) bool
+
+		// CheckRole is like HasRole but tells why it fails, a ForbiddenError
+		// is returned if the user lacks the role, or the realm failure otherwise
+		CheckRole(context.Context, authc.UserDetails, Role) error
+		// CheckAnyRole is like HasAnyRole but tells why it fails
+		CheckAnyRole(context.Context, authc.UserDetails, ...Role) error
+		// CheckAllRole is like HasAllRole but tells why it fails
+		CheckAllRole(context.Context, authc.UserDetails, ...Role) error
+
+		// CheckAuthority is like HasAuthority but tells why it fails, a ForbiddenError
+		// is returned if the user lacks the authority, or the realm failure otherwise
+		CheckAuthority(context.Context, authc.UserDetails, Authority) error
+		// CheckAnyAuthority is like HasAnyAuthority but tells why it fails
+		CheckAnyAuthority(context.Context, authc.UserDetails, ...Authority) error
+		// CheckAllAuthority is like HasAllAuthority but tells why it fails
+		CheckAllAuthority(context.Context, authc.UserDetails, ...Authority) error
 	}
 )
@@ -0,0 +1,269 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"strings"
+)
+
+type (
+	// Vote is the result of a Voter
+	Vote int
+
+	// A Voter votes on whether the user can access the object, attributes describe
+	// what is required, e.g. Role(s), Authority(s) or any custom value. Voters that
+	// do not understand any of the attributes should Abstain
+	Voter interface {
+		// Vote returns Grant, Deny or Abstain, an error is returned
+		// when the voter cannot decide, e.g. a realm failure
+		Vote(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) (Vote, error)
+	}
+
+	// VoterFunc is an adapter to allow the use of ordinary functions as Voter
+	VoterFunc func(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) (Vote, error)
+
+	// A DecisionManager combines the votes of voters
+	DecisionManager interface {
+		// Decide returns nil if access is granted, a ForbiddenError if it is
+		// denied, or the error of a voter that could not decide
+		Decide(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) error
+	}
+
+	// DecisionOption can be used to customize DecisionOptions
+	DecisionOption func(*DecisionOptions)
+
+	// DecisionOptions contains config attribute that can
+	// affect how a DecisionManager combines votes
+	DecisionOptions struct {
+		// AllowIfAllAbstain grants access when every voter abstains
+		AllowIfAllAbstain bool
+		// AllowIfEqualGrantedDenied grants access when the Consensus is a tie
+		AllowIfEqualGrantedDenied bool
+	}
+
+	vetoVoter struct {
+		voter Voter
+	}
+
+	roleVoter struct {
+		authorizer Authorizer
+	}
+
+	authorityVoter struct {
+		authorizer Authorizer
+	}
+
+	decisionManager struct {
+		name   string
+		voters []Voter
+		opt    *DecisionOptions
+		decide func(opt *DecisionOptions, grants int, denies int) bool
+	}
+)
+
+const (
+	// Abstain means the voter has no opinion
+	Abstain Vote = 0
+	// Grant means the voter grants access
+	Grant Vote = 1
+	// Deny means the voter denies access
+	Deny Vote = -1
+)
+
+var (
+	_ Voter           = (VoterFunc)(nil)
+	_ Voter           = (*vetoVoter)(nil)
+	_ Voter           = (*roleVoter)(nil)
+	_ Voter           = (*authorityVoter)(nil)
+	_ DecisionManager = (*decisionManager)(nil)
+)
+
+var defaultDecisionOptions = DecisionOptions{
+	AllowIfAllAbstain:         false,
+	AllowIfEqualGrantedDenied: true,
+}
+
+func WithAllowIfAllAbstain(allow bool) DecisionOption {
+	return func(opt *DecisionOptions) {
+		opt.AllowIfAllAbstain = allow
+	}
+}
+
+func WithAllowIfEqualGrantedDenied(allow bool) DecisionOption {
+	return func(opt *DecisionOptions) {
+		opt.AllowIfEqualGrantedDenied = allow
+	}
+}
+
+func (f VoterFunc) Vote(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) (Vote, error) {
+	return f(ctx, userDetails, object, attributes)
+}
+
+func (v Vote) String() string {
+	switch v {
+	case Grant:
+		return "grant"
+	case Deny:
+		return "deny"
+	default:
+		return "abstain"
+	}
+}
+
+// Veto wraps a Voter so that its Deny overrides grants whatever
+// the DecisionManager is, e.g. an IP blocklist
+func Veto(voter Voter) Voter {
+	return &vetoVoter{voter: voter}
+}
+
+func (v *vetoVoter) Vote(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) (Vote, error) {
+	return v.voter.Vote(ctx, userDetails, object, attributes)
+}
+
+// NewRoleVoter returns a Voter that votes on Role attributes, it grants if the
+// user has any of them, denies otherwise, and abstains if there is none
+func NewRoleVoter(authorizer Authorizer) Voter {
+	return &roleVoter{authorizer: authorizer}
+}
+
+func (v *roleVoter) Vote(ctx context.Context, userDetails authc.UserDetails, _ any, attributes []any) (Vote, error) {
+	var roles []Role
+	for _, attr := range attributes {
+		if role, ok := attr.(Role); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	if len(roles) == 0 {
+		return Abstain, nil
+	}
+
+	return vote(v.authorizer.CheckAnyRole(ctx, userDetails, roles...))
+}
+
+// NewAuthorityVoter returns a Voter that votes on Authority attributes, it grants
+// if the user has any of them, denies otherwise, and abstains if there is none
+func NewAuthorityVoter(authorizer Authorizer) Voter {
+	return &authorityVoter{authorizer: authorizer}
+}
+
+func (v *authorityVoter) Vote(ctx context.Context, userDetails authc.UserDetails, _ any, attributes []any) (Vote, error) {
+	var authorities []Authority
+	for _, attr := range attributes {
+		if authority, ok := attr.(Authority); ok {
+			authorities = append(authorities, authority)
+		}
+	}
+
+	if len(authorities) == 0 {
+		return Abstain, nil
+	}
+
+	return vote(v.authorizer.CheckAnyAuthority(ctx, userDetails, authorities...))
+}
+
+func vote(err error) (Vote, error) {
+	if err == nil {
+		return Grant, nil
+	}
+
+	if errors.Is(err, ErrForbidden) {
+		return Deny, nil
+	}
+
+	return Abstain, err
+}
+
+///=====================================
+///		    DecisionManager
+///=====================================
+
+// NewAffirmative returns a DecisionManager that grants access if any voter grants
+func NewAffirmative(voters []Voter, opts ...DecisionOption) DecisionManager {
+	return newDecisionManager("affirmative", voters, opts, func(_ *DecisionOptions, grants int, _ int) bool {
+		return grants > 0
+	})
+}
+
+// NewConsensus returns a DecisionManager that grants access if more voters grant than deny
+func NewConsensus(voters []Voter, opts ...DecisionOption) DecisionManager {
+	return newDecisionManager("consensus", voters, opts, func(opt *DecisionOptions, grants int, denies int) bool {
+		if grants == denies {
+			return opt.AllowIfEqualGrantedDenied
+		}
+
+		return grants > denies
+	})
+}
+
+// NewUnanimous returns a DecisionManager that grants access only if no voter denies
+func NewUnanimous(voters []Voter, opts ...DecisionOption) DecisionManager {
+	return newDecisionManager("unanimous", voters, opts, func(_ *DecisionOptions, grants int, denies int) bool {
+		return denies == 0
+	})
+}
+
+func newDecisionManager(name string, voters []Voter, opts []DecisionOption,
+	decide func(*DecisionOptions, int, int) bool) DecisionManager {
+	opt := defaultDecisionOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &decisionManager{
+		name:   name,
+		voters: voters,
+		opt:    &opt,
+		decide: decide,
+	}
+}
+
+func (m *decisionManager) Decide(ctx context.Context, userDetails authc.UserDetails, object any, attributes []any) error {
+	var grants, denies int
+	for _, voter := range m.voters {
+		v, err := voter.Vote(ctx, userDetails, object, attributes)
+		if err != nil {
+			// fail closed, the voter might have denied
+			return err
+		}
+
+		switch v {
+		case Grant:
+			grants++
+		case Deny:
+			if _, veto := voter.(*vetoVoter); veto {
+				return m.forbidden(attributes)
+			}
+			denies++
+		}
+	}
+
+	if grants == 0 && denies == 0 {
+		if m.opt.AllowIfAllAbstain {
+			return nil
+		}
+
+		return m.forbidden(attributes)
+	}
+
+	if m.decide(m.opt, grants, denies) {
+		return nil
+	}
+
+	return m.forbidden(attributes)
+}
+
+func (m *decisionManager) forbidden(attributes []any) error {
+	descs := make([]string, 0, len(attributes))
+	for _, attr := range attributes {
+		if d, ok := attr.(interface{ Desc() string }); ok {
+			descs = append(descs, d.Desc())
+		} else {
+			descs = append(descs, fmt.Sprint(attr))
+		}
+	}
+
+	return &ForbiddenError{Requirement: m.name + "(" + strings.Join(descs, ", ") + ")"}
+}
@@ -0,0 +1,99 @@
+package authz
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// blocklistVoter denies requests from the blocked network and abstains otherwise
+func blocklistVoter(cidr string) Voter {
+	_, blocked, _ := net.ParseCIDR(cidr)
+	return VoterFunc(func(_ context.Context, _ authc.UserDetails, object any, _ []any) (Vote, error) {
+		ip, ok := object.(net.IP)
+		if !ok {
+			return Abstain, nil
+		}
+
+		if blocked.Contains(ip) {
+			return Deny, nil
+		}
+
+		return Abstain, nil
+	})
+}
+
+func fixedVoter(v Vote) Voter {
+	return VoterFunc(func(context.Context, authc.UserDetails, any, []any) (Vote, error) {
+		return v, nil
+	})
+}
+
+func TestBuiltinVoters(t *testing.T) {
+	azer := NewAuthorizer(&mockRealm{})
+	roleVoter := NewRoleVoter(azer)
+	authorityVoter := NewAuthorityVoter(azer)
+
+	v, err := roleVoter.Vote(context.TODO(), mockUd, nil, []any{role("d"), role("a")})
+	assert.NoError(t, err)
+	assert.Equal(t, Grant, v)
+
+	v, err = roleVoter.Vote(context.TODO(), mockUd, nil, []any{role("d"), authority("read")})
+	assert.NoError(t, err)
+	assert.Equal(t, Deny, v)
+
+	v, err = roleVoter.Vote(context.TODO(), mockUd, nil, []any{authority("read")})
+	assert.NoError(t, err)
+	assert.Equal(t, Abstain, v)
+
+	v, err = authorityVoter.Vote(context.TODO(), mockUd, nil, []any{authority("read")})
+	assert.NoError(t, err)
+	assert.Equal(t, Grant, v)
+
+	_, err = NewRoleVoter(NewAuthorizer(&failingRealm{})).Vote(context.TODO(), mockUd, nil, []any{role("a")})
+	assert.EqualError(t, err, "load roles: database down")
+}
+
+func TestDecisionManagers(t *testing.T) {
+	voters := []Voter{fixedVoter(Grant), fixedVoter(Deny), fixedVoter(Deny)}
+
+	assert.NoError(t, NewAffirmative(voters).Decide(context.TODO(), mockUd, nil, nil))
+	assert.ErrorIs(t, NewConsensus(voters).Decide(context.TODO(), mockUd, nil, nil), ErrForbidden)
+	assert.ErrorIs(t, NewUnanimous(voters).Decide(context.TODO(), mockUd, nil, nil), ErrForbidden)
+
+	tie := []Voter{fixedVoter(Grant), fixedVoter(Deny)}
+	assert.NoError(t, NewConsensus(tie).Decide(context.TODO(), mockUd, nil, nil))
+	assert.ErrorIs(t, NewConsensus(tie, WithAllowIfEqualGrantedDenied(false)).Decide(context.TODO(), mockUd, nil, nil), ErrForbidden)
+
+	abstain := []Voter{fixedVoter(Abstain)}
+	assert.ErrorIs(t, NewAffirmative(abstain).Decide(context.TODO(), mockUd, nil, nil), ErrForbidden)
+	assert.NoError(t, NewUnanimous(abstain, WithAllowIfAllAbstain(true)).Decide(context.TODO(), mockUd, nil, nil))
+
+	granted := []Voter{fixedVoter(Grant), fixedVoter(Abstain)}
+	assert.NoError(t, NewUnanimous(granted).Decide(context.TODO(), mockUd, nil, nil))
+}
+
+func TestCustomVoters(t *testing.T) {
+	azer := NewAuthorizer(&mockRealm{})
+	manager := NewAffirmative([]Voter{
+		NewRoleVoter(azer),
+		NewAuthorityVoter(azer),
+		Veto(blocklistVoter("10.0.0.0/8")),
+	})
+
+	attributes := []any{role("a"), authority("delete")}
+	assert.NoError(t, manager.Decide(context.TODO(), mockUd, net.ParseIP("192.168.1.1"), attributes))
+
+	// the veto overrides the grant of RoleVoter
+	err := manager.Decide(context.TODO(), mockUd, net.ParseIP("10.1.2.3"), attributes)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.EqualError(t, err, "forbidden: affirmative(a, delete)")
+
+	// a voter failure is not a denial
+	manager = NewAffirmative([]Voter{NewRoleVoter(NewAuthorizer(&failingRealm{}))})
+	err = manager.Decide(context.TODO(), mockUd, nil, attributes)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrForbidden)
+}
@@ -0,0 +1,144 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WildcardAuthority is an Authority with Apache Shiro wildcard semantics, it consists
+// of colon-separated parts, e.g. domain:action:instance, each part may contain
+// comma-separated sub-parts, and "*" matches anything in that part.
+// An authority with fewer parts implies the missing parts, so "printer" implies
+// "printer:print:lp7200", while "printer:print" does not imply "printer"
+type WildcardAuthority struct {
+	desc          string
+	parts         [][]string
+	caseSensitive bool
+}
+
+const (
+	wildcardToken         = "*"
+	wildcardPartDivider   = ":"
+	wildcardSubDivider    = ","
+	wildcardDefaultFormat = "domain:action:instance"
+)
+
+var (
+	_ Authority = (*WildcardAuthority)(nil)
+
+	// ErrMalformedAuthority is returned when a wildcard authority cannot be parsed
+	ErrMalformedAuthority = errors.New("malformed authority")
+)
+
+// ParseWildcardAuthority parses text such as "printer:print,query:lp7200", parts
+// are lower-cased unless caseSensitive is true
+func ParseWildcardAuthority(text string, caseSensitive bool) (*WildcardAuthority, error) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return nil, fmt.Errorf("%w: empty, expecting %s", ErrMalformedAuthority, wildcardDefaultFormat)
+	}
+
+	if !caseSensitive {
+		text = strings.ToLower(text)
+	}
+
+	parts := strings.Split(text, wildcardPartDivider)
+	authority := &WildcardAuthority{
+		parts:         make([][]string, 0, len(parts)),
+		caseSensitive: caseSensitive,
+	}
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			return nil, fmt.Errorf("%w: %q has an empty part at position %d", ErrMalformedAuthority, text, i+1)
+		}
+
+		subs := strings.Split(part, wildcardSubDivider)
+		for j, sub := range subs {
+			subs[j] = strings.TrimSpace(sub)
+			if len(subs[j]) == 0 {
+				return nil, fmt.Errorf("%w: %q has an empty sub-part in part %q", ErrMalformedAuthority, text, part)
+			}
+		}
+
+		authority.parts = append(authority.parts, subs)
+		parts[i] = strings.Join(subs, wildcardSubDivider)
+	}
+
+	authority.desc = strings.Join(parts, wildcardPartDivider)
+	return authority, nil
+}
+
+// MustParseWildcardAuthority is like ParseWildcardAuthority but panics if text is malformed
+func MustParseWildcardAuthority(text string, caseSensitive bool) *WildcardAuthority {
+	authority, err := ParseWildcardAuthority(text, caseSensitive)
+	if err != nil {
+		panic(err)
+	}
+
+	return authority
+}
+
+func (a *WildcardAuthority) Desc() string {
+	return a.desc
+}
+
+// Implies returns true if the specified authority is implied by this authority,
+// authorities other than WildcardAuthority are parsed from their Desc first
+func (a *WildcardAuthority) Implies(authority Authority) bool {
+	other, ok := authority.(*WildcardAuthority)
+	if !ok || other.caseSensitive != a.caseSensitive {
+		var err error
+		other, err = ParseWildcardAuthority(authority.Desc(), a.caseSensitive)
+		if err != nil {
+			return false
+		}
+	}
+
+	for i, otherPart := range other.parts {
+		// this authority has fewer parts, the missing ones are implied
+		if i >= len(a.parts) {
+			return true
+		}
+
+		part := a.parts[i]
+		if !contains(part, wildcardToken) && !containsAll(part, otherPart) {
+			return false
+		}
+	}
+
+	// this authority has more parts, the extra ones must be wildcards
+	for i := len(other.parts); i < len(a.parts); i++ {
+		if !contains(a.parts[i], wildcardToken) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *WildcardAuthority) String() string {
+	return a.desc
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAll(values []string, others []string) bool {
+	for _, o := range others {
+		if !contains(values, o) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package authz
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func wildcard(text string) *WildcardAuthority {
+	return MustParseWildcardAuthority(text, false)
+}
+
+func TestWildcardImplies(t *testing.T) {
+	cases := []struct {
+		granted  string
+		required string
+		implied  bool
+	}{
+		{"document:*", "document:read", true},
+		{"document:*", "document:read:42", true},
+		{"document:read", "document:write", false},
+		{"document", "document:read:42", true},
+		{"document:read", "document", false},
+		{"document:read:*", "document:read", true},
+		{"printer:print,query:lp7200", "printer:print:lp7200", true},
+		{"printer:print,query:lp7200", "printer:query:lp7200", true},
+		{"printer:print,query:lp7200", "printer:manage:lp7200", false},
+		{"printer:print,query:lp7200", "printer:print,query:lp7200", true},
+		{"printer:print:lp7200", "printer:print,query:lp7200", false},
+		{"printer:*:lp7200", "printer:print:lp7201", false},
+		{"*:read", "document:read", true},
+		{"*", "anything:at:all", true},
+		{"Document:Read", "document:read", true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.implied, wildcard(c.granted).Implies(wildcard(c.required)), c.granted+" => "+c.required)
+	}
+
+	// plain authorities are parsed from their Desc
+	assert.True(t, wildcard("document:*").Implies(NewAuthority("document:read")))
+	assert.False(t, wildcard("document:*").Implies(NewAuthority("document::read")))
+}
+
+func TestWildcardCaseSensitive(t *testing.T) {
+	granted := MustParseWildcardAuthority("Document:Read", true)
+	assert.Equal(t, "Document:Read", granted.Desc())
+	assert.True(t, granted.Implies(NewAuthority("Document:Read")))
+	assert.False(t, granted.Implies(NewAuthority("document:read")))
+
+	assert.Equal(t, "document:read", wildcard("Document:Read").Desc())
+}
+
+func TestParseWildcardAuthority(t *testing.T) {
+	for _, text := range []string{"", "  ", "document::read", "document:", ":read", "printer:print,,query", "printer:print,"} {
+		_, err := ParseWildcardAuthority(text, false)
+		assert.ErrorIs(t, err, ErrMalformedAuthority, text)
+	}
+
+	_, err := ParseWildcardAuthority("document::read", false)
+	assert.EqualError(t, err, `malformed authority: "document::read" has an empty part at position 2`)
+
+	authority, err := ParseWildcardAuthority(" printer : print , query : lp7200 ", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "printer:print,query:lp7200", authority.Desc())
+	assert.True(t, authority.Implies(wildcard("printer:query:lp7200")))
+
+	assert.Panics(t, func() { MustParseWildcardAuthority("a::b", false) })
+}
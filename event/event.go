@@ -0,0 +1,125 @@
+package event
+
+import (
+	"context"
+	"github.com/shrinex/shield/internal/xcontext"
+	"sync"
+)
+
+type (
+	// Event is anything published by a Publisher, e.g. authc.AuthenticationSuccessEvent
+	Event any
+
+	// A Listener is notified of published events
+	Listener interface {
+		// OnEvent is called once per published Event
+		OnEvent(context.Context, Event)
+	}
+
+	// ListenerFunc is an adapter to allow the use of ordinary functions as Listener
+	ListenerFunc func(context.Context, Event)
+
+	// A Publisher delivers events to listeners
+	Publisher interface {
+		// Publish delivers the Event to all registered listeners
+		Publish(context.Context, Event)
+	}
+
+	registration struct {
+		listener Listener
+		async    bool
+	}
+
+	// Multicaster is a Publisher that delivers events to registered listeners,
+	// synchronous listeners run in the publishing goroutine in registration
+	// order, asynchronous listeners run in their own goroutine
+	Multicaster struct {
+		mu            sync.RWMutex
+		registrations []registration
+	}
+
+	noopPublisher struct {
+	}
+)
+
+var (
+	_ Listener  = (ListenerFunc)(nil)
+	_ Publisher = (*Multicaster)(nil)
+	_ Publisher = (*noopPublisher)(nil)
+
+	// Default is the Multicaster used when no Publisher is supplied
+	Default = NewMulticaster()
+
+	// NoopPublisher drops all events
+	NoopPublisher Publisher = &noopPublisher{}
+)
+
+func (f ListenerFunc) OnEvent(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// On returns a Listener that is only notified of events of type E
+func On[E any](f func(context.Context, E)) Listener {
+	return ListenerFunc(func(ctx context.Context, e Event) {
+		if v, ok := e.(E); ok {
+			f(ctx, v)
+		}
+	})
+}
+
+func NewMulticaster() *Multicaster {
+	return &Multicaster{}
+}
+
+// AddListener registers a Listener that is notified synchronously
+func (m *Multicaster) AddListener(listener Listener) {
+	m.addListener(listener, false)
+}
+
+// AddAsyncListener registers a Listener that is notified asynchronously,
+// the context passed to it keeps the values but not the cancellation
+func (m *Multicaster) AddAsyncListener(listener Listener) {
+	m.addListener(listener, true)
+}
+
+func (m *Multicaster) Publish(ctx context.Context, e Event) {
+	m.mu.RLock()
+	registrations := m.registrations
+	m.mu.RUnlock()
+
+	for _, r := range registrations {
+		if !r.async {
+			r.listener.OnEvent(ctx, e)
+			continue
+		}
+
+		go func(listener Listener) {
+			// a faulty listener must not crash the process
+			defer func() { _ = recover() }()
+			listener.OnEvent(xcontext.WithoutCancel(ctx), e)
+		}(r.listener)
+	}
+}
+
+func (m *Multicaster) addListener(listener Listener, async bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// copy on write so that Publish never holds the lock while notifying
+	registrations := make([]registration, len(m.registrations), len(m.registrations)+1)
+	copy(registrations, m.registrations)
+	m.registrations = append(registrations, registration{listener: listener, async: async})
+}
+
+// AddListener registers a synchronous Listener to the Default Multicaster
+func AddListener(listener Listener) {
+	Default.AddListener(listener)
+}
+
+// AddAsyncListener registers an asynchronous Listener to the Default Multicaster
+func AddAsyncListener(listener Listener) {
+	Default.AddAsyncListener(listener)
+}
+
+func (p *noopPublisher) Publish(context.Context, Event) {
+}
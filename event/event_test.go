@@ -0,0 +1,56 @@
+package event
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type (
+	loginEvent  string
+	logoutEvent string
+	ctxKey      struct{}
+)
+
+func TestSyncListeners(t *testing.T) {
+	m := NewMulticaster()
+
+	var received []string
+	m.AddListener(On(func(_ context.Context, e loginEvent) {
+		received = append(received, "login:"+string(e))
+	}))
+	m.AddListener(ListenerFunc(func(_ context.Context, e Event) {
+		received = append(received, "any")
+	}))
+
+	m.Publish(context.TODO(), loginEvent("archer"))
+	m.Publish(context.TODO(), logoutEvent("archer"))
+
+	assert.Equal(t, []string{"login:archer", "any", "any"}, received)
+}
+
+func TestAsyncListeners(t *testing.T) {
+	m := NewMulticaster()
+
+	received := make(chan any, 1)
+	m.AddAsyncListener(On(func(ctx context.Context, e logoutEvent) {
+		// cancellation of the publisher does not leak into async listeners
+		assert.NoError(t, ctx.Err())
+		received <- ctx.Value(ctxKey{})
+	}))
+	m.AddAsyncListener(ListenerFunc(func(context.Context, Event) {
+		panic("faulty listener")
+	}))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.TODO(), ctxKey{}, "value"))
+	m.Publish(ctx, logoutEvent("archer"))
+	cancel()
+
+	select {
+	case v := <-received:
+		assert.Equal(t, "value", v)
+	case <-time.After(time.Second):
+		assert.Fail(t, "listener not notified")
+	}
+}
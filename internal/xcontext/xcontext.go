@@ -0,0 +1,36 @@
+// Package xcontext provides context helpers shared by the packages of shield
+package xcontext
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// withoutCancel keeps the values of the parent but is never canceled
+	withoutCancel struct {
+		parent context.Context
+	}
+)
+
+// WithoutCancel returns a context that keeps the values of the parent but is
+// not canceled when the parent is, like context.WithoutCancel of Go 1.21
+func WithoutCancel(parent context.Context) context.Context {
+	return withoutCancel{parent: parent}
+}
+
+func (withoutCancel) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (withoutCancel) Done() <-chan struct{} {
+	return nil
+}
+
+func (withoutCancel) Err() error {
+	return nil
+}
+
+func (c withoutCancel) Value(key any) any {
+	return c.parent.Value(key)
+}
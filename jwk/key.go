@@ -0,0 +1,350 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+type (
+	// Key is a JSON Web Key, see RFC 7517
+	Key struct {
+		// KeyId is the "kid" parameter
+		KeyId string
+		// Algorithm is the "alg" parameter, e.g. RS256
+		Algorithm string
+		// Use is the "use" parameter, e.g. sig
+		Use string
+		// Key is one of []byte, *rsa.PublicKey, *rsa.PrivateKey, *ecdsa.PublicKey,
+		// *ecdsa.PrivateKey, ed25519.PublicKey or ed25519.PrivateKey
+		Key any
+	}
+
+	// Set is a JSON Web Key Set
+	Set struct {
+		Keys []*Key `json:"keys"`
+	}
+
+	rawKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid,omitempty"`
+		Use string `json:"use,omitempty"`
+		Alg string `json:"alg,omitempty"`
+		Crv string `json:"crv,omitempty"`
+		K   string `json:"k,omitempty"`
+		N   string `json:"n,omitempty"`
+		E   string `json:"e,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
+		D   string `json:"d,omitempty"`
+		P   string `json:"p,omitempty"`
+		Q   string `json:"q,omitempty"`
+	}
+)
+
+var (
+	_ json.Marshaler   = (*Key)(nil)
+	_ json.Unmarshaler = (*Key)(nil)
+
+	b64 = base64.RawURLEncoding
+)
+
+// ParseSet decodes a JSON Web Key Set, keys with unknown "kty" are skipped
+func ParseSet(data []byte) (*Set, error) {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	set := &Set{Keys: make([]*Key, 0, len(raw.Keys))}
+	for _, data := range raw.Keys {
+		key := &Key{}
+		if err := key.UnmarshalJSON(data); err != nil {
+			if err == ErrUnsupportedKeyType {
+				continue
+			}
+			return nil, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+
+	return set, nil
+}
+
+// LookupKeyId returns the Key with the specified kid
+func (s *Set) LookupKeyId(kid string) (*Key, bool) {
+	for _, k := range s.Keys {
+		if k.KeyId == kid {
+			return k, true
+		}
+	}
+
+	return nil, false
+}
+
+// Public returns a Set without any private or symmetric key,
+// it is safe to be published by a JWKS endpoint
+func (s *Set) Public() *Set {
+	set := &Set{Keys: make([]*Key, 0, len(s.Keys))}
+	for _, k := range s.Keys {
+		if public, ok := k.Public(); ok {
+			set.Keys = append(set.Keys, public)
+		}
+	}
+
+	return set
+}
+
+// Public returns the public part of the Key, false if the Key is symmetric
+func (k *Key) Public() (*Key, bool) {
+	var public any
+	switch key := k.Key.(type) {
+	case []byte:
+		return nil, false
+	case crypto.Signer:
+		public = key.Public()
+	default:
+		public = key
+	}
+
+	return &Key{
+		KeyId:     k.KeyId,
+		Algorithm: k.Algorithm,
+		Use:       k.Use,
+		Key:       public,
+	}, true
+}
+
+// Private returns true if the Key can be used to sign
+func (k *Key) Private() bool {
+	switch k.Key.(type) {
+	case []byte, *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func (k *Key) MarshalJSON() ([]byte, error) {
+	raw := rawKey{Kid: k.KeyId, Alg: k.Algorithm, Use: k.Use}
+
+	switch key := k.Key.(type) {
+	case []byte:
+		raw.Kty = "oct"
+		raw.K = b64.EncodeToString(key)
+	case *rsa.PrivateKey:
+		raw.Kty = "RSA"
+		setRSAPublic(&raw, &key.PublicKey)
+		raw.D = encodeInt(key.D)
+		if len(key.Primes) == 2 {
+			raw.P = encodeInt(key.Primes[0])
+			raw.Q = encodeInt(key.Primes[1])
+		}
+	case *rsa.PublicKey:
+		raw.Kty = "RSA"
+		setRSAPublic(&raw, key)
+	case *ecdsa.PrivateKey:
+		raw.Kty = "EC"
+		setECPublic(&raw, &key.PublicKey)
+		raw.D = b64.EncodeToString(key.D.FillBytes(make([]byte, curveSize(key.Curve))))
+	case *ecdsa.PublicKey:
+		raw.Kty = "EC"
+		setECPublic(&raw, key)
+	case ed25519.PrivateKey:
+		raw.Kty = "OKP"
+		raw.Crv = "Ed25519"
+		raw.X = b64.EncodeToString(key.Public().(ed25519.PublicKey))
+		raw.D = b64.EncodeToString(key.Seed())
+	case ed25519.PublicKey:
+		raw.Kty = "OKP"
+		raw.Crv = "Ed25519"
+		raw.X = b64.EncodeToString(key)
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+
+	return json.Marshal(raw)
+}
+
+func (k *Key) UnmarshalJSON(data []byte) error {
+	var raw rawKey
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch raw.Kty {
+	case "oct":
+		key, err = b64.DecodeString(raw.K)
+	case "RSA":
+		key, err = parseRSA(&raw)
+	case "EC":
+		key, err = parseEC(&raw)
+	case "OKP":
+		key, err = parseOKP(&raw)
+	default:
+		return ErrUnsupportedKeyType
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: kid %q: %v", ErrMalformed, raw.Kid, err)
+	}
+
+	k.KeyId = raw.Kid
+	k.Algorithm = raw.Alg
+	k.Use = raw.Use
+	k.Key = key
+	return nil
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func parseRSA(raw *rawKey) (any, error) {
+	n, err := decodeInt(raw.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeInt(raw.E)
+	if err != nil {
+		return nil, err
+	}
+
+	public := rsa.PublicKey{N: n, E: int(e.Int64())}
+	if len(raw.D) == 0 {
+		return &public, nil
+	}
+
+	d, err := decodeInt(raw.D)
+	if err != nil {
+		return nil, err
+	}
+
+	private := &rsa.PrivateKey{PublicKey: public, D: d}
+	if len(raw.P) != 0 && len(raw.Q) != 0 {
+		p, err := decodeInt(raw.P)
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := decodeInt(raw.Q)
+		if err != nil {
+			return nil, err
+		}
+
+		private.Primes = []*big.Int{p, q}
+		if err = private.Validate(); err != nil {
+			return nil, err
+		}
+		private.Precompute()
+	}
+
+	return private, nil
+}
+
+func parseEC(raw *rawKey) (any, error) {
+	var curve elliptic.Curve
+	switch raw.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", raw.Crv)
+	}
+
+	x, err := decodeInt(raw.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeInt(raw.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, fmt.Errorf("point not on curve %q", raw.Crv)
+	}
+
+	public := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if len(raw.D) == 0 {
+		return &public, nil
+	}
+
+	d, err := decodeInt(raw.D)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PrivateKey{PublicKey: public, D: d}, nil
+}
+
+func parseOKP(raw *rawKey) (any, error) {
+	if raw.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", raw.Crv)
+	}
+
+	if len(raw.D) != 0 {
+		seed, err := b64.DecodeString(raw.D)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid ed25519 seed")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+
+	x, err := b64.DecodeString(raw.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+func setRSAPublic(raw *rawKey, key *rsa.PublicKey) {
+	raw.N = encodeInt(key.N)
+	raw.E = encodeInt(big.NewInt(int64(key.E)))
+}
+
+func setECPublic(raw *rawKey, key *ecdsa.PublicKey) {
+	size := curveSize(key.Curve)
+	raw.Crv = key.Curve.Params().Name
+	raw.X = b64.EncodeToString(key.X.FillBytes(make([]byte, size)))
+	raw.Y = b64.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+}
+
+func curveSize(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+func encodeInt(v *big.Int) string {
+	return b64.EncodeToString(v.Bytes())
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	if len(s) == 0 {
+		return nil, fmt.Errorf("missing parameter")
+	}
+
+	data, err := b64.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,79 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newPrivateSet() *Set {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	return &Set{Keys: []*Key{
+		{KeyId: "rsa", Use: "sig", Key: rsaKey},
+		{KeyId: "ec", Key: ecKey},
+		{KeyId: "ed", Algorithm: "EdDSA", Key: edKey},
+		{KeyId: "oct", Key: []byte("secret")},
+	}}
+}
+
+func TestSetRoundTrip(t *testing.T) {
+	set := newPrivateSet()
+
+	data, err := json.Marshal(set)
+	assert.NoError(t, err)
+
+	parsed, err := ParseSet(data)
+	assert.NoError(t, err)
+	assert.Equal(t, len(set.Keys), len(parsed.Keys))
+
+	for i, key := range set.Keys {
+		assert.Equal(t, key.KeyId, parsed.Keys[i].KeyId)
+		assert.Equal(t, key.Algorithm, parsed.Keys[i].Algorithm)
+		assert.Equal(t, key.Use, parsed.Keys[i].Use)
+		assert.True(t, parsed.Keys[i].Private())
+	}
+
+	assert.True(t, parsed.Keys[0].Key.(*rsa.PrivateKey).Equal(set.Keys[0].Key))
+	assert.True(t, parsed.Keys[1].Key.(*ecdsa.PrivateKey).Equal(set.Keys[1].Key))
+	assert.True(t, parsed.Keys[2].Key.(ed25519.PrivateKey).Equal(set.Keys[2].Key))
+	assert.Equal(t, []byte("secret"), parsed.Keys[3].Key)
+}
+
+func TestPublicSet(t *testing.T) {
+	public := newPrivateSet().Public()
+	assert.Equal(t, 3, len(public.Keys))
+
+	data, err := json.Marshal(public)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"d"`)
+	assert.NotContains(t, string(data), `"k"`)
+
+	parsed, err := ParseSet(data)
+	assert.NoError(t, err)
+	for _, key := range parsed.Keys {
+		assert.False(t, key.Private())
+	}
+
+	_, ok := parsed.LookupKeyId("ec")
+	assert.True(t, ok)
+	_, ok = parsed.LookupKeyId("oct")
+	assert.False(t, ok)
+}
+
+func TestParseSetSkipsUnknownKeyType(t *testing.T) {
+	set, err := ParseSet([]byte(`{"keys":[{"kty":"unknown","kid":"a"},{"kty":"oct","kid":"b","k":"c2VjcmV0"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(set.Keys))
+	assert.Equal(t, "b", set.Keys[0].KeyId)
+
+	_, err = ParseSet([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AA","y":"AA"}]}`))
+	assert.ErrorIs(t, err, ErrMalformed)
+}
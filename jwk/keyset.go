@@ -0,0 +1,328 @@
+package jwk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"github.com/shrinex/shield/jwt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Option can be used to customize Options
+	Option func(*Options)
+
+	// Options contains config attribute that can
+	// affect how KeySet refreshes its keys
+	Options struct {
+		// RefreshInterval controls how often the Set is refreshed in background,
+		// background refresh is disabled if it is not positive
+		RefreshInterval time.Duration
+		// MinRefreshInterval throttles refreshes triggered by unknown kid
+		MinRefreshInterval time.Duration
+		// ActiveKeyId selects the Key used by Sign, the first private
+		// key of the Set is used if it is empty
+		ActiveKeyId string
+	}
+
+	// KeySet caches the Set fetched from a Source and keeps it up-to-date,
+	// it is a jwt.KeyResolver and can be plugged into any token-verifying realm
+	KeySet struct {
+		mu          sync.RWMutex
+		refreshMu   sync.Mutex
+		stopGuard   sync.Once
+		stopChan    chan struct{}
+		source      Source
+		opt         Options
+		set         *Set
+		lastErr     error
+		lastRefresh time.Time
+		generation  uint64
+	}
+)
+
+var _ jwt.KeyResolver = (*KeySet)(nil)
+
+var defaultOptions = Options{
+	RefreshInterval:    time.Hour,
+	MinRefreshInterval: time.Minute,
+}
+
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(opt *Options) {
+		opt.RefreshInterval = interval
+	}
+}
+
+func WithMinRefreshInterval(interval time.Duration) Option {
+	return func(opt *Options) {
+		if interval >= 0 {
+			opt.MinRefreshInterval = interval
+		}
+	}
+}
+
+func WithActiveKeyId(kid string) Option {
+	return func(opt *Options) {
+		opt.ActiveKeyId = kid
+	}
+}
+
+// NewKeySet returns a KeySet backed by the Source, the Set is fetched
+// lazily on first use and then refreshed every RefreshInterval
+func NewKeySet(source Source, opts ...Option) *KeySet {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	s := &KeySet{
+		source:   source,
+		opt:      opt,
+		stopChan: make(chan struct{}),
+	}
+
+	if opt.RefreshInterval > 0 {
+		go s.startRefresh()
+	}
+
+	return s
+}
+
+// ResolveKey returns the verification key for the jwt.Header, the Set is
+// refreshed once if the "kid" is unknown, the cached Set is still used if
+// the Source is unavailable
+func (s *KeySet) ResolveKey(ctx context.Context, header *jwt.Header) (any, error) {
+	set, err := s.Set(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := match(set, header); ok {
+		return key.Key, nil
+	}
+
+	s.mu.RLock()
+	throttled := nowFunc().Sub(s.lastRefresh) < s.opt.MinRefreshInterval
+	s.mu.RUnlock()
+
+	if throttled {
+		return nil, jwt.ErrKeyNotFound
+	}
+
+	// key may have been rotated
+	if err = s.Refresh(ctx); err != nil {
+		return nil, jwt.ErrKeyNotFound
+	}
+
+	set, _ = s.Set(ctx)
+	if key, ok := match(set, header); ok {
+		return key.Key, nil
+	}
+
+	return nil, jwt.ErrKeyNotFound
+}
+
+// Set returns the cached Set, it is fetched if nothing is cached yet
+func (s *KeySet) Set(ctx context.Context) (*Set, error) {
+	s.mu.RLock()
+	set := s.set
+	s.mu.RUnlock()
+
+	if set != nil {
+		return set, nil
+	}
+
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.set, nil
+}
+
+// Refresh fetches the latest Set from Source, the cached Set is kept on failure
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.mu.RLock()
+	generation := s.generation
+	s.mu.RUnlock()
+
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	s.mu.RLock()
+	refreshed, lastErr := s.generation != generation, s.lastErr
+	s.mu.RUnlock()
+
+	// concurrent callers share the same refresh
+	if refreshed {
+		return lastErr
+	}
+
+	set, err := s.source.Fetch(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generation++
+	s.lastErr = err
+	s.lastRefresh = nowFunc()
+	if err != nil {
+		return err
+	}
+
+	s.set = set
+	return nil
+}
+
+// SetActiveKeyId switches the Key used by Sign
+func (s *KeySet) SetActiveKeyId(kid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.opt.ActiveKeyId = kid
+}
+
+// ActiveKey returns the Key used by Sign
+func (s *KeySet) ActiveKey(ctx context.Context) (*Key, error) {
+	set, err := s.Set(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	kid := s.opt.ActiveKeyId
+	s.mu.RUnlock()
+
+	if len(kid) != 0 {
+		key, ok := set.LookupKeyId(kid)
+		if !ok || !key.Private() {
+			return nil, ErrNoActiveKey
+		}
+		return key, nil
+	}
+
+	for _, key := range set.Keys {
+		if key.Private() && (len(key.Use) == 0 || key.Use == "sig") {
+			return key, nil
+		}
+	}
+
+	return nil, ErrNoActiveKey
+}
+
+// Sign returns a JWT signed by the active Key, the "kid" header is set accordingly
+func (s *KeySet) Sign(ctx context.Context, claims jwt.Claims) (string, error) {
+	key, err := s.ActiveKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	alg, ok := algorithmOf(key)
+	if !ok {
+		return "", jwt.ErrUnsupportedAlgorithm
+	}
+
+	return jwt.Sign(claims, alg, key.Key, key.KeyId)
+}
+
+func (s *KeySet) StopRefresh() error {
+	s.stopGuard.Do(func() {
+		close(s.stopChan)
+	})
+
+	return nil
+}
+
+func (s *KeySet) startRefresh() {
+	ticker := time.NewTicker(s.opt.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = s.Refresh(context.Background())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func match(set *Set, header *jwt.Header) (*Key, bool) {
+	if len(header.KeyId) != 0 {
+		key, ok := set.LookupKeyId(header.KeyId)
+		if !ok || !verifies(key, header.Algorithm) {
+			return nil, false
+		}
+		return key, true
+	}
+
+	for _, key := range set.Keys {
+		if verifies(key, header.Algorithm) {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func verifies(key *Key, alg string) bool {
+	if len(key.Use) != 0 && key.Use != "sig" {
+		return false
+	}
+
+	if len(key.Algorithm) != 0 {
+		return key.Algorithm == alg
+	}
+
+	expected, ok := algorithmOf(key)
+	if !ok {
+		return false
+	}
+
+	// alg family decides the key type, e.g. RS256 and RS512 share the same RSA key
+	return strings.HasPrefix(alg, expected.Name()[:2])
+}
+
+func algorithmOf(key *Key) (jwt.Algorithm, bool) {
+	if len(key.Algorithm) != 0 {
+		return jwt.LookupAlgorithm(key.Algorithm)
+	}
+
+	switch k := key.Key.(type) {
+	case []byte:
+		return jwt.HS256, true
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return jwt.RS256, true
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve.Params().Name)
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve.Params().Name)
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return jwt.EdDSA, true
+	default:
+		return nil, false
+	}
+}
+
+func ecdsaAlgorithm(crv string) (jwt.Algorithm, bool) {
+	switch crv {
+	case "P-256":
+		return jwt.ES256, true
+	case "P-384":
+		return jwt.ES384, true
+	case "P-521":
+		return jwt.ES512, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,150 @@
+package jwk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/jwt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mockProvider struct {
+	mu    sync.Mutex
+	set   *Set
+	down  bool
+	calls int32
+}
+
+func (p *mockProvider) rotate(kid string) *Key {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key := &Key{KeyId: kid, Algorithm: "ES256", Use: "sig", Key: ecKey}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.set == nil {
+		p.set = &Set{}
+	}
+	p.set.Keys = append([]*Key{key}, p.set.Keys...)
+	return key
+}
+
+func (p *mockProvider) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	atomic.AddInt32(&p.calls, 1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(p.set.Public())
+}
+
+func newClaims() jwt.Claims {
+	return jwt.Claims{
+		jwt.ClaimSubject:   "archer",
+		jwt.ClaimExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	provider := &mockProvider{}
+	first := provider.rotate("k1")
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	keys := NewKeySet(NewHTTPSource(server.URL, server.Client()), WithMinRefreshInterval(0))
+	defer keys.StopRefresh()
+	realm := jwt.NewRealm(keys)
+
+	raw, _ := jwt.Sign(newClaims(), jwt.ES256, first.Key, first.KeyId)
+	userDetails, err := realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", userDetails.Principal())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+
+	// unknown kid triggers a refresh
+	second := provider.rotate("k2")
+	raw, _ = jwt.Sign(newClaims(), jwt.ES256, second.Key, second.KeyId)
+	_, err = realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&provider.calls))
+
+	// cached keys still work while the provider is down
+	provider.mu.Lock()
+	provider.down = true
+	provider.mu.Unlock()
+
+	raw, _ = jwt.Sign(newClaims(), jwt.ES256, first.Key, first.KeyId)
+	_, err = realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+
+	raw, _ = jwt.Sign(newClaims(), jwt.ES256, first.Key, "k3")
+	_, err = realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.ErrorIs(t, err, jwt.ErrKeyNotFound)
+
+	set, err := keys.Set(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(set.Keys))
+}
+
+func TestKeySetThrottlesRefresh(t *testing.T) {
+	provider := &mockProvider{}
+	provider.rotate("k1")
+	server := httptest.NewServer(provider)
+	defer server.Close()
+
+	keys := NewKeySet(NewHTTPSource(server.URL, server.Client()), WithRefreshInterval(0))
+
+	for i := 0; i < 3; i++ {
+		_, err := keys.ResolveKey(context.TODO(), &jwt.Header{Algorithm: "ES256", KeyId: "unknown"})
+		assert.ErrorIs(t, err, jwt.ErrKeyNotFound)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&provider.calls))
+}
+
+func TestKeySetSign(t *testing.T) {
+	set := newPrivateSet()
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	keys := NewKeySet(NewFileSource(path), WithRefreshInterval(0))
+	realm := jwt.NewRealm(keys)
+
+	// first private key is active by default
+	raw, err := keys.Sign(context.TODO(), newClaims())
+	assert.NoError(t, err)
+	token, _ := jwt.Parse(raw)
+	assert.Equal(t, "rsa", token.Header.KeyId)
+	assert.Equal(t, "RS256", token.Header.Algorithm)
+	_, err = realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+
+	keys.SetActiveKeyId("ed")
+	raw, err = keys.Sign(context.TODO(), newClaims())
+	assert.NoError(t, err)
+	token, _ = jwt.Parse(raw)
+	assert.Equal(t, "ed", token.Header.KeyId)
+	assert.Equal(t, "EdDSA", token.Header.Algorithm)
+	_, err = realm.LoadUserDetails(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+
+	keys.SetActiveKeyId("missing")
+	_, err = keys.Sign(context.TODO(), newClaims())
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+}
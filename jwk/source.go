@@ -0,0 +1,94 @@
+package jwk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+type (
+	// A Source is responsible for fetching the latest Set
+	Source interface {
+		// Fetch returns the latest Set
+		Fetch(context.Context) (*Set, error)
+	}
+
+	// SourceFunc is an adapter to allow the use of ordinary functions as Source
+	SourceFunc func(context.Context) (*Set, error)
+
+	fileSource struct {
+		path string
+	}
+
+	httpSource struct {
+		url    string
+		client *http.Client
+	}
+)
+
+var (
+	_ Source = (SourceFunc)(nil)
+	_ Source = (*fileSource)(nil)
+	_ Source = (*httpSource)(nil)
+)
+
+func (f SourceFunc) Fetch(ctx context.Context) (*Set, error) {
+	return f(ctx)
+}
+
+// NewFileSource returns a Source that reads the Set from a local file
+func NewFileSource(path string) Source {
+	return &fileSource{path: path}
+}
+
+func (s *fileSource) Fetch(ctx context.Context) (*Set, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSet(data)
+}
+
+// NewHTTPSource returns a Source that downloads the Set from a JWKS endpoint,
+// http.DefaultClient is used if client is nil
+func NewHTTPSource(url string, client *http.Client) Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &httpSource{url: url, client: client}
+}
+
+func (s *httpSource) Fetch(ctx context.Context) (*Set, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSetSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSet(data)
+}
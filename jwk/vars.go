@@ -0,0 +1,20 @@
+package jwk
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	nowFunc = time.Now
+
+	// ErrMalformed is returned when the JSON Web Key (Set) can not be parsed
+	ErrMalformed = errors.New("malformed jwk")
+	// ErrUnsupportedKeyType is returned when the "kty" parameter is unknown
+	ErrUnsupportedKeyType = errors.New("unsupported jwk key type")
+	// ErrNoActiveKey is returned when there is no Key can be used to sign
+	ErrNoActiveKey = errors.New("no active jwk")
+)
+
+// maxSetSize limits the size of a downloaded JSON Web Key Set
+const maxSetSize = 1 << 20
@@ -0,0 +1,245 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"math/big"
+)
+
+type (
+	// An Algorithm signs and verifies the signing input of a JWT
+	Algorithm interface {
+		// Name returns the "alg" header value, e.g. HS256
+		Name() string
+		// Sign returns the signature of the input using the private or shared key
+		Sign(input []byte, key any) ([]byte, error)
+		// Verify returns nil if the signature matches the input using the public or shared key
+		Verify(input []byte, signature []byte, key any) error
+	}
+
+	hmacAlgorithm struct {
+		name string
+		hash crypto.Hash
+	}
+
+	rsaAlgorithm struct {
+		name string
+		hash crypto.Hash
+	}
+
+	ecdsaAlgorithm struct {
+		name    string
+		hash    crypto.Hash
+		curve   elliptic.Curve
+		keySize int
+	}
+
+	eddsaAlgorithm struct {
+	}
+)
+
+var (
+	_ Algorithm = (*hmacAlgorithm)(nil)
+	_ Algorithm = (*rsaAlgorithm)(nil)
+	_ Algorithm = (*ecdsaAlgorithm)(nil)
+	_ Algorithm = (*eddsaAlgorithm)(nil)
+
+	// builtin algorithms, see RFC 7518 and RFC 8037
+	HS256 Algorithm = &hmacAlgorithm{name: "HS256", hash: crypto.SHA256}
+	HS384 Algorithm = &hmacAlgorithm{name: "HS384", hash: crypto.SHA384}
+	HS512 Algorithm = &hmacAlgorithm{name: "HS512", hash: crypto.SHA512}
+	RS256 Algorithm = &rsaAlgorithm{name: "RS256", hash: crypto.SHA256}
+	RS384 Algorithm = &rsaAlgorithm{name: "RS384", hash: crypto.SHA384}
+	RS512 Algorithm = &rsaAlgorithm{name: "RS512", hash: crypto.SHA512}
+	ES256 Algorithm = &ecdsaAlgorithm{name: "ES256", hash: crypto.SHA256, curve: elliptic.P256(), keySize: 32}
+	ES384 Algorithm = &ecdsaAlgorithm{name: "ES384", hash: crypto.SHA384, curve: elliptic.P384(), keySize: 48}
+	ES512 Algorithm = &ecdsaAlgorithm{name: "ES512", hash: crypto.SHA512, curve: elliptic.P521(), keySize: 66}
+	EdDSA Algorithm = &eddsaAlgorithm{}
+
+	algorithms = map[string]Algorithm{}
+)
+
+func init() {
+	for _, alg := range []Algorithm{HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512, EdDSA} {
+		algorithms[alg.Name()] = alg
+	}
+}
+
+// LookupAlgorithm returns the builtin Algorithm with the specified name
+func LookupAlgorithm(name string) (Algorithm, bool) {
+	alg, ok := algorithms[name]
+	return alg, ok
+}
+
+///=====================================
+///		    HMAC
+///=====================================
+
+func (a *hmacAlgorithm) Name() string {
+	return a.name
+}
+
+func (a *hmacAlgorithm) Sign(input []byte, key any) ([]byte, error) {
+	secret, ok := key.([]byte)
+	if !ok || len(secret) == 0 {
+		return nil, ErrInvalidKey
+	}
+
+	mac := hmac.New(a.hash.New, secret)
+	mac.Write(input)
+	return mac.Sum(nil), nil
+}
+
+func (a *hmacAlgorithm) Verify(input []byte, signature []byte, key any) error {
+	expected, err := a.Sign(input, key)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, signature) {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+///=====================================
+///		    RSA
+///=====================================
+
+func (a *rsaAlgorithm) Name() string {
+	return a.name
+}
+
+func (a *rsaAlgorithm) Sign(input []byte, key any) ([]byte, error) {
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	return rsa.SignPKCS1v15(rand.Reader, privateKey, a.hash, digest(a.hash, input))
+}
+
+func (a *rsaAlgorithm) Verify(input []byte, signature []byte, key any) error {
+	var publicKey *rsa.PublicKey
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		publicKey = k
+	case *rsa.PrivateKey:
+		publicKey = &k.PublicKey
+	default:
+		return ErrInvalidKey
+	}
+
+	if err := rsa.VerifyPKCS1v15(publicKey, a.hash, digest(a.hash, input), signature); err != nil {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+///=====================================
+///		    ECDSA
+///=====================================
+
+func (a *ecdsaAlgorithm) Name() string {
+	return a.name
+}
+
+func (a *ecdsaAlgorithm) Sign(input []byte, key any) ([]byte, error) {
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || privateKey.Curve.Params().Name != a.curve.Params().Name {
+		return nil, ErrInvalidKey
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest(a.hash, input))
+	if err != nil {
+		return nil, err
+	}
+
+	// signature is the fixed size R || S
+	signature := make([]byte, 2*a.keySize)
+	r.FillBytes(signature[:a.keySize])
+	s.FillBytes(signature[a.keySize:])
+	return signature, nil
+}
+
+func (a *ecdsaAlgorithm) Verify(input []byte, signature []byte, key any) error {
+	var publicKey *ecdsa.PublicKey
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		publicKey = k
+	case *ecdsa.PrivateKey:
+		publicKey = &k.PublicKey
+	default:
+		return ErrInvalidKey
+	}
+
+	if publicKey.Curve.Params().Name != a.curve.Params().Name {
+		return ErrInvalidKey
+	}
+
+	if len(signature) != 2*a.keySize {
+		return ErrSignatureInvalid
+	}
+
+	r := new(big.Int).SetBytes(signature[:a.keySize])
+	s := new(big.Int).SetBytes(signature[a.keySize:])
+	if !ecdsa.Verify(publicKey, digest(a.hash, input), r, s) {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+///=====================================
+///		    EdDSA
+///=====================================
+
+func (a *eddsaAlgorithm) Name() string {
+	return "EdDSA"
+}
+
+func (a *eddsaAlgorithm) Sign(input []byte, key any) ([]byte, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return nil, ErrInvalidKey
+	}
+
+	return ed25519.Sign(privateKey, input), nil
+}
+
+func (a *eddsaAlgorithm) Verify(input []byte, signature []byte, key any) error {
+	var publicKey ed25519.PublicKey
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		publicKey = k
+	case ed25519.PrivateKey:
+		publicKey = k.Public().(ed25519.PublicKey)
+	default:
+		return ErrInvalidKey
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidKey
+	}
+
+	if !ed25519.Verify(publicKey, input, signature) {
+		return ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+func digest(hash crypto.Hash, input []byte) []byte {
+	h := hash.New()
+	h.Write(input)
+	return h.Sum(nil)
+}
@@ -0,0 +1,168 @@
+package jwt
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	// Option can be used to customize Options
+	Option func(*Options)
+
+	// Options contains config attribute that can
+	// affect how a JWT is validated and mapped
+	Options struct {
+		// Algorithms restricts the accepted "alg" header values,
+		// all builtin algorithms are accepted if empty
+		Algorithms []string
+		// Issuers restricts the accepted "iss" claim values, not checked if empty
+		Issuers []string
+		// Audiences restricts the accepted "aud" claim values, the token must
+		// contain at least one of them, not checked if empty
+		Audiences []string
+		// Leeway is the clock skew tolerance applied to "exp", "nbf" and "iat"
+		Leeway time.Duration
+		// RequireExpiresAt rejects tokens without an "exp" claim
+		RequireExpiresAt bool
+		// PrincipalClaim names the claim mapped to authc.UserDetails Principal
+		PrincipalClaim string
+		// RolesClaim names the claim mapped to authz.Role(s)
+		RolesClaim string
+		// AuthoritiesClaim names the claim mapped to authz.Authority(s)
+		AuthoritiesClaim string
+	}
+)
+
+var defaultOptions = Options{
+	Leeway:           time.Minute,
+	RequireExpiresAt: true,
+	PrincipalClaim:   ClaimSubject,
+	RolesClaim:       "roles",
+	AuthoritiesClaim: "scope",
+}
+
+func WithAlgorithms(algorithms ...string) Option {
+	return func(opt *Options) {
+		opt.Algorithms = algorithms
+	}
+}
+
+func WithIssuers(issuers ...string) Option {
+	return func(opt *Options) {
+		opt.Issuers = issuers
+	}
+}
+
+func WithAudiences(audiences ...string) Option {
+	return func(opt *Options) {
+		opt.Audiences = audiences
+	}
+}
+
+func WithLeeway(leeway time.Duration) Option {
+	return func(opt *Options) {
+		if leeway >= 0 {
+			opt.Leeway = leeway
+		}
+	}
+}
+
+func WithoutExpiresAt() Option {
+	return func(opt *Options) {
+		opt.RequireExpiresAt = false
+	}
+}
+
+func WithPrincipalClaim(name string) Option {
+	return func(opt *Options) {
+		if len(name) != 0 {
+			opt.PrincipalClaim = name
+		}
+	}
+}
+
+func WithRolesClaim(name string) Option {
+	return func(opt *Options) {
+		if len(name) != 0 {
+			opt.RolesClaim = name
+		}
+	}
+}
+
+func WithAuthoritiesClaim(name string) Option {
+	return func(opt *Options) {
+		if len(name) != 0 {
+			opt.AuthoritiesClaim = name
+		}
+	}
+}
+
+// Validate checks registered claims "exp", "nbf", "iat", "iss" and "aud"
+func Validate(claims Claims, opts ...Option) error {
+	return apply(opts...).validate(claims)
+}
+
+func (opt *Options) acceptAlgorithm(name string) (Algorithm, bool) {
+	alg, ok := LookupAlgorithm(name)
+	if !ok || len(opt.Algorithms) == 0 {
+		return alg, ok
+	}
+
+	return alg, contains(opt.Algorithms, name)
+}
+
+func (opt *Options) validate(claims Claims) error {
+	nowTime := nowFunc()
+
+	exp, ok := claims.ExpiresAt()
+	if !ok && opt.RequireExpiresAt {
+		return fmt.Errorf("%w: %s", ErrMissingClaim, ClaimExpiresAt)
+	}
+
+	if ok && !nowTime.Before(exp.Add(opt.Leeway)) {
+		return ErrExpired
+	}
+
+	if nbf, ok := claims.NotBefore(); ok && nowTime.Add(opt.Leeway).Before(nbf) {
+		return ErrNotValidYet
+	}
+
+	if iat, ok := claims.IssuedAt(); ok && nowTime.Add(opt.Leeway).Before(iat) {
+		return ErrIssuedInFuture
+	}
+
+	if len(opt.Issuers) != 0 && !contains(opt.Issuers, claims.Issuer()) {
+		return ErrInvalidIssuer
+	}
+
+	if len(opt.Audiences) != 0 {
+		for _, aud := range claims.Audience() {
+			if contains(opt.Audiences, aud) {
+				return nil
+			}
+		}
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+func apply(opts ...Option) *Options {
+	opt := defaultOptions
+
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &opt
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package jwt
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"strings"
+)
+
+type (
+	// UserDetails is the authc.UserDetails mapped from JWT claims
+	UserDetails struct {
+		principal   string
+		claims      Claims
+		roles       []authz.Role
+		authorities []authz.Authority
+	}
+
+	// Realm is a stateless authc.Realm that authenticates authc.BearerToken(s)
+	// carrying a signed JWT, it is also an authz.Realm that grants the Role(s)
+	// and Authority(s) mapped from the claims
+	Realm struct {
+		keys KeyResolver
+		opt  *Options
+	}
+)
+
+var (
+	_ authc.UserDetails = (*UserDetails)(nil)
+	_ authc.Stateless   = (*UserDetails)(nil)
+	_ authc.Realm       = (*Realm)(nil)
+	_ authz.Realm       = (*Realm)(nil)
+)
+
+// NewRealm returns a Realm that verifies signatures with keys resolved by KeyResolver
+func NewRealm(keys KeyResolver, opts ...Option) *Realm {
+	return &Realm{keys: keys, opt: apply(opts...)}
+}
+
+// Supports returns true if the token is an authc.BearerToken which looks like a JWT,
+// opaque bearer tokens are left to other realm(s)
+func (r *Realm) Supports(token authc.Token) bool {
+	if _, ok := token.(*authc.BearerToken); !ok {
+		return false
+	}
+
+	return strings.Count(token.Credentials(), ".") == 2
+}
+
+func (r *Realm) LoadUserDetails(ctx context.Context, token authc.Token) (authc.UserDetails, error) {
+	jwt, err := r.Verify(ctx, token.Credentials())
+	if err != nil {
+		return nil, err
+	}
+
+	principal := jwt.Claims.String(r.opt.PrincipalClaim)
+	if len(principal) == 0 {
+		return nil, ErrMissingSubject
+	}
+
+	userDetails := &UserDetails{
+		principal: principal,
+		claims:    jwt.Claims,
+	}
+	for _, name := range jwt.Claims.Strings(r.opt.RolesClaim) {
+		userDetails.roles = append(userDetails.roles, authz.NewRole(name))
+	}
+	for _, name := range jwt.Claims.Strings(r.opt.AuthoritiesClaim) {
+		userDetails.authorities = append(userDetails.authorities, authz.NewAuthority(name))
+	}
+
+	return userDetails, nil
+}
+
+// Verify parses the raw JWT, verifies its signature and validates its claims
+func (r *Realm) Verify(ctx context.Context, raw string) (*Token, error) {
+	jwt, err := Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, ok := r.opt.acceptAlgorithm(jwt.Header.Algorithm)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	key, err := r.keys.ResolveKey(ctx, &jwt.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = jwt.Verify(alg, key); err != nil {
+		return nil, err
+	}
+
+	if err = r.opt.validate(jwt.Claims); err != nil {
+		return nil, err
+	}
+
+	return jwt, nil
+}
+
+func (r *Realm) LoadRoles(_ context.Context, userDetails authc.UserDetails) ([]authz.Role, error) {
+	if ud, ok := authc.As[*UserDetails](userDetails); ok {
+		return ud.roles, nil
+	}
+
+	return nil, nil
+}
+
+func (r *Realm) LoadAuthorities(_ context.Context, userDetails authc.UserDetails) ([]authz.Authority, error) {
+	if ud, ok := authc.As[*UserDetails](userDetails); ok {
+		return ud.authorities, nil
+	}
+
+	return nil, nil
+}
+
+///=====================================
+///		    UserDetails
+///=====================================
+
+func (u *UserDetails) Principal() string {
+	return u.principal
+}
+
+// Stateless returns true since the JWT is presented with every request
+func (u *UserDetails) Stateless() bool {
+	return true
+}
+
+// Claims returns all claims conveyed by the JWT
+func (u *UserDetails) Claims() Claims {
+	return u.claims
+}
+
+// Roles returns the Role(s) mapped from the roles claim
+func (u *UserDetails) Roles() []authz.Role {
+	return u.roles
+}
+
+// Authorities returns the Authority(s) mapped from the authorities claim
+func (u *UserDetails) Authorities() []authz.Authority {
+	return u.authorities
+}
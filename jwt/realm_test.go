@@ -0,0 +1,173 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newClaims() Claims {
+	nowTime := nowFunc()
+	return Claims{
+		ClaimSubject:   "archer",
+		ClaimIssuer:    "shield",
+		ClaimAudience:  []string{"api"},
+		ClaimIssuedAt:  nowTime.Unix(),
+		ClaimExpiresAt: nowTime.Add(time.Hour).Unix(),
+		"roles":        []string{"admin"},
+		"scope":        "doc:read doc:write",
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		alg       Algorithm
+		signKey   any
+		verifyKey any
+	}{
+		{HS256, []byte("secret"), []byte("secret")},
+		{RS256, rsaKey, &rsaKey.PublicKey},
+		{ES256, ecKey, &ecKey.PublicKey},
+		{EdDSA, edKey, edKey.Public()},
+	}
+
+	for _, c := range cases {
+		raw, err := Sign(newClaims(), c.alg, c.signKey, "kid")
+		assert.NoError(t, err)
+
+		token, err := Parse(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, c.alg.Name(), token.Header.Algorithm)
+		assert.Equal(t, "kid", token.Header.KeyId)
+		assert.Equal(t, "archer", token.Claims.Subject())
+
+		assert.NoError(t, token.Verify(c.alg, c.verifyKey), c.alg.Name())
+
+		tampered, _ := Parse(raw[:len(raw)-4] + "AAAA")
+		assert.ErrorIs(t, tampered.Verify(c.alg, c.verifyKey), ErrSignatureInvalid, c.alg.Name())
+	}
+}
+
+func TestVerifyRejectsKeyConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	raw, err := Sign(newClaims(), HS256, []byte("secret"), "")
+	assert.NoError(t, err)
+
+	token, err := Parse(raw)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, token.Verify(HS256, &rsaKey.PublicKey), ErrInvalidKey)
+	assert.ErrorIs(t, token.Verify(RS256, &rsaKey.PublicKey), ErrUnsupportedAlgorithm)
+}
+
+func TestValidate(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	nowTime := time.Unix(1000000, 0)
+	nowFunc = func() time.Time { return nowTime }
+
+	claims := newClaims()
+	assert.NoError(t, Validate(claims, WithIssuers("shield"), WithAudiences("api", "web")))
+	assert.ErrorIs(t, Validate(claims, WithIssuers("other")), ErrInvalidIssuer)
+	assert.ErrorIs(t, Validate(claims, WithAudiences("web")), ErrInvalidAudience)
+
+	nowFunc = func() time.Time { return nowTime.Add(time.Hour + 30*time.Second) }
+	assert.NoError(t, Validate(claims))
+	assert.ErrorIs(t, Validate(claims, WithLeeway(0)), ErrExpired)
+
+	nowFunc = func() time.Time { return nowTime.Add(-30 * time.Second) }
+	assert.NoError(t, Validate(claims))
+	assert.ErrorIs(t, Validate(claims, WithLeeway(0)), ErrIssuedInFuture)
+
+	claims[ClaimNotBefore] = nowTime.Add(time.Minute).Unix()
+	assert.ErrorIs(t, Validate(claims, WithLeeway(0)), ErrNotValidYet)
+
+	delete(claims, ClaimExpiresAt)
+	delete(claims, ClaimNotBefore)
+	nowFunc = func() time.Time { return nowTime }
+	assert.ErrorIs(t, Validate(claims), ErrMissingClaim)
+	assert.NoError(t, Validate(claims, WithoutExpiresAt()))
+
+	// NumericDate beyond year 2262 must not overflow
+	claims[ClaimExpiresAt] = 1e11
+	assert.NoError(t, Validate(claims))
+	claims[ClaimNotBefore] = 1e11
+	assert.ErrorIs(t, Validate(claims), ErrNotValidYet)
+}
+
+func TestClaimsTime(t *testing.T) {
+	claims := Claims{ClaimExpiresAt: 1e11, ClaimNotBefore: 1500000000.25}
+
+	exp, ok := claims.ExpiresAt()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1e11), exp.Unix())
+
+	nbf, ok := claims.NotBefore()
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1500000000, 250000000), nbf)
+}
+
+func TestRealm(t *testing.T) {
+	secret := []byte("secret")
+	realm := NewRealm(NewStaticKeyResolver(secret), WithAlgorithms("HS256"), WithIssuers("shield"))
+	ac := authc.NewAuthenticator(realm)
+	az := authz.NewAuthorizer(realm)
+
+	raw, _ := Sign(newClaims(), HS256, secret, "")
+	assert.True(t, realm.Supports(authc.NewBearerToken(raw)))
+	assert.False(t, realm.Supports(authc.NewBearerToken("opaque")))
+	assert.False(t, realm.Supports(authc.NewUsernamePasswordToken("archer", raw)))
+
+	userDetails, err := ac.Authenticate(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", userDetails.Principal())
+
+	assert.True(t, az.HasRole(context.TODO(), userDetails, authz.NewRole("admin")))
+	assert.True(t, az.HasAllAuthority(context.TODO(), userDetails,
+		authz.NewAuthority("doc:read"), authz.NewAuthority("doc:write")))
+	assert.False(t, az.HasAuthority(context.TODO(), userDetails, authz.NewAuthority("doc:delete")))
+
+	// roles are still granted when merged with other realms
+	composite, err := authc.NewBuilder().Realms(realm, &stubRealm{}).
+		Strategy(authc.AllSuccessful).Build().Authenticate(context.TODO(), authc.NewBearerToken(raw))
+	assert.NoError(t, err)
+	assert.IsType(t, &authc.CompositeUserDetails{}, composite)
+	assert.True(t, az.HasRole(context.TODO(), composite, authz.NewRole("admin")))
+
+	raw, _ = Sign(newClaims(), HS512, secret, "")
+	_, err = ac.Authenticate(context.TODO(), authc.NewBearerToken(raw))
+	assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+	claims := newClaims()
+	delete(claims, ClaimSubject)
+	raw, _ = Sign(claims, HS256, secret, "")
+	_, err = ac.Authenticate(context.TODO(), authc.NewBearerToken(raw))
+	assert.ErrorIs(t, err, ErrMissingSubject)
+
+	// rejected tokens are authentication failures
+	_, err = ac.Authenticate(context.TODO(), authc.NewBearerToken("a.b.c"))
+	assert.ErrorIs(t, err, ErrMalformed)
+	assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+}
+
+type stubRealm struct {
+}
+
+func (r *stubRealm) Supports(authc.Token) bool {
+	return true
+}
+
+func (r *stubRealm) LoadUserDetails(context.Context, authc.Token) (authc.UserDetails, error) {
+	return authc.NewBearerToken("archer"), nil
+}
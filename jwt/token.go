@@ -0,0 +1,95 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+var b64 = base64.RawURLEncoding
+
+// Parse decodes the compact serialized JWT without verifying it
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	token := &Token{Raw: raw}
+	if err := decodeSegment(parts[0], &token.Header); err != nil {
+		return nil, err
+	}
+
+	if err := decodeSegment(parts[1], &token.Claims); err != nil {
+		return nil, err
+	}
+
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	token.Signature = signature
+
+	return token, nil
+}
+
+// Verify checks the signature of the token
+func (t *Token) Verify(alg Algorithm, key any) error {
+	if alg.Name() != t.Header.Algorithm {
+		return ErrUnsupportedAlgorithm
+	}
+
+	return alg.Verify([]byte(t.signingInput()), t.Signature, key)
+}
+
+// Sign returns the compact serialized JWT signed by the specified algorithm and key,
+// the "kid" header is omitted if kid is empty
+func Sign(claims Claims, alg Algorithm, key any, kid string) (string, error) {
+	header, err := encodeSegment(Header{
+		Algorithm: alg.Name(),
+		Type:      "JWT",
+		KeyId:     kid,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := header + "." + payload
+	signature, err := alg.Sign([]byte(input), key)
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + b64.EncodeToString(signature), nil
+}
+
+func (t *Token) signingInput() string {
+	return t.Raw[:strings.LastIndex(t.Raw, ".")]
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := b64.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+func encodeSegment(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return b64.EncodeToString(data), nil
+}
@@ -0,0 +1,153 @@
+package jwt
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+)
+
+type (
+	// Header is the JOSE header of a JWT
+	Header struct {
+		// Algorithm identifies the algorithm used to secure the JWT
+		Algorithm string `json:"alg"`
+		// Type declares the media type of the JWT
+		Type string `json:"typ,omitempty"`
+		// KeyId is a hint indicating which key was used to secure the JWT
+		KeyId string `json:"kid,omitempty"`
+	}
+
+	// Claims is the JSON object of claims conveyed by the JWT
+	Claims map[string]any
+
+	// Token is a parsed JWT
+	Token struct {
+		// Raw is the compact serialized token
+		Raw string
+		// Header of the token
+		Header Header
+		// Claims of the token
+		Claims Claims
+		// Signature of the token
+		Signature []byte
+	}
+
+	// A KeyResolver is responsible for finding out the key
+	// that should be used to verify the specified token
+	KeyResolver interface {
+		// ResolveKey returns the verification key for the Header,
+		// ErrKeyNotFound is returned if there is no such key
+		ResolveKey(context.Context, *Header) (any, error)
+	}
+
+	// KeyResolverFunc is an adapter to allow the use of ordinary functions as KeyResolver
+	KeyResolverFunc func(context.Context, *Header) (any, error)
+
+	staticKeyResolver struct {
+		key any
+	}
+)
+
+var (
+	_ KeyResolver = (KeyResolverFunc)(nil)
+	_ KeyResolver = (*staticKeyResolver)(nil)
+)
+
+func (f KeyResolverFunc) ResolveKey(ctx context.Context, header *Header) (any, error) {
+	return f(ctx, header)
+}
+
+// NewStaticKeyResolver returns a KeyResolver that always resolves the specified key
+func NewStaticKeyResolver(key any) KeyResolver {
+	return &staticKeyResolver{key: key}
+}
+
+func (r *staticKeyResolver) ResolveKey(_ context.Context, _ *Header) (any, error) {
+	return r.key, nil
+}
+
+///=====================================
+///		    Claims
+///=====================================
+
+// Issuer returns the "iss" claim
+func (c Claims) Issuer() string {
+	return c.String(ClaimIssuer)
+}
+
+// Subject returns the "sub" claim
+func (c Claims) Subject() string {
+	return c.String(ClaimSubject)
+}
+
+// Audience returns the "aud" claim which is either a string or an array of strings
+func (c Claims) Audience() []string {
+	return c.Strings(ClaimAudience)
+}
+
+// ExpiresAt returns the "exp" claim
+func (c Claims) ExpiresAt() (time.Time, bool) {
+	return c.Time(ClaimExpiresAt)
+}
+
+// NotBefore returns the "nbf" claim
+func (c Claims) NotBefore() (time.Time, bool) {
+	return c.Time(ClaimNotBefore)
+}
+
+// IssuedAt returns the "iat" claim
+func (c Claims) IssuedAt() (time.Time, bool) {
+	return c.Time(ClaimIssuedAt)
+}
+
+// Id returns the "jti" claim
+func (c Claims) Id() string {
+	return c.String(ClaimId)
+}
+
+// String returns the claim as string or empty if the claim is absent or not a string
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Strings returns the claim as strings, a single string is split by whitespace
+// so that OAuth2 "scope" claims are supported as well
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Time returns the claim which is a NumericDate as time.Time
+func (c Claims) Time(name string) (time.Time, bool) {
+	var seconds float64
+	switch v := c[name].(type) {
+	case float64:
+		seconds = v
+	case int64:
+		seconds = float64(v)
+	case int:
+		seconds = float64(v)
+	default:
+		return time.Time{}, false
+	}
+
+	// nanoseconds since epoch overflow int64 after year 2262
+	sec, frac := math.Modf(seconds)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+}
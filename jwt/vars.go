@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"time"
+)
+
+// The errors wrap authc.ErrUnauthenticated so that a rejected JWT
+// is handled as any other bad credentials, e.g. counted by lockout
+var (
+	nowFunc = time.Now
+
+	// ErrMalformed is returned when the token is not a compact serialized JWT
+	ErrMalformed = fmt.Errorf("%w: malformed jwt", authc.ErrUnauthenticated)
+	// ErrUnsupportedAlgorithm is returned when the "alg" header is not accepted
+	ErrUnsupportedAlgorithm = fmt.Errorf("%w: unsupported jwt algorithm", authc.ErrUnauthenticated)
+	// ErrInvalidKey is returned when the key does not fit the algorithm
+	ErrInvalidKey = fmt.Errorf("%w: invalid jwt key", authc.ErrUnauthenticated)
+	// ErrKeyNotFound is returned when no key can be resolved for the token
+	ErrKeyNotFound = fmt.Errorf("%w: jwt key not found", authc.ErrUnauthenticated)
+	// ErrSignatureInvalid is returned when the signature verification failed
+	ErrSignatureInvalid = fmt.Errorf("%w: jwt signature invalid", authc.ErrUnauthenticated)
+	// ErrExpired is returned when the "exp" claim is in the past
+	ErrExpired = fmt.Errorf("%w: jwt expired", authc.ErrUnauthenticated)
+	// ErrNotValidYet is returned when the "nbf" claim is in the future
+	ErrNotValidYet = fmt.Errorf("%w: jwt not valid yet", authc.ErrUnauthenticated)
+	// ErrIssuedInFuture is returned when the "iat" claim is in the future
+	ErrIssuedInFuture = fmt.Errorf("%w: jwt issued in the future", authc.ErrUnauthenticated)
+	// ErrInvalidIssuer is returned when the "iss" claim is not accepted
+	ErrInvalidIssuer = fmt.Errorf("%w: jwt issuer invalid", authc.ErrUnauthenticated)
+	// ErrInvalidAudience is returned when the "aud" claim is not accepted
+	ErrInvalidAudience = fmt.Errorf("%w: jwt audience invalid", authc.ErrUnauthenticated)
+	// ErrMissingClaim is returned when a required claim is absent
+	ErrMissingClaim = fmt.Errorf("%w: jwt claim missing", authc.ErrUnauthenticated)
+	// ErrMissingSubject is returned when the principal claim is absent
+	ErrMissingSubject = fmt.Errorf("%w: jwt subject missing", authc.ErrUnauthenticated)
+)
+
+const (
+	// ClaimIssuer identifies the principal that issued the JWT
+	ClaimIssuer = "iss"
+	// ClaimSubject identifies the principal that is the subject of the JWT
+	ClaimSubject = "sub"
+	// ClaimAudience identifies the recipients that the JWT is intended for
+	ClaimAudience = "aud"
+	// ClaimExpiresAt identifies the expiration time of the JWT
+	ClaimExpiresAt = "exp"
+	// ClaimNotBefore identifies the time before which the JWT must not be accepted
+	ClaimNotBefore = "nbf"
+	// ClaimIssuedAt identifies the time at which the JWT was issued
+	ClaimIssuedAt = "iat"
+	// ClaimId provides a unique identifier for the JWT
+	ClaimId = "jti"
+)
@@ -0,0 +1,153 @@
+package otp
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"sync"
+)
+
+type (
+	// A KeyStore is responsible for loading the Key provisioned to a principal
+	KeyStore interface {
+		// LoadKey returns nil if the principal has not enrolled
+		LoadKey(context.Context, string) (*Key, error)
+	}
+
+	// A CounterStore keeps the last accepted counter (HOTP counter or TOTP
+	// time step) of every principal so that a code can only be used once
+	CounterStore interface {
+		// LastCounter returns the last accepted counter of the principal
+		LastCounter(context.Context, string) (uint64, bool, error)
+		// Advance atomically sets the last accepted counter,
+		// false is returned if it is not greater than the current one
+		Advance(context.Context, string, uint64) (bool, error)
+	}
+
+	// MapCounterStore is a CounterStore backed by a map
+	MapCounterStore struct {
+		mu       sync.Mutex
+		counters map[string]uint64
+	}
+
+	secondFactor struct {
+		keys     KeyStore
+		counters CounterStore
+		window   int
+	}
+)
+
+var (
+	_ CounterStore       = (*MapCounterStore)(nil)
+	_ authc.SecondFactor = (*secondFactor)(nil)
+)
+
+func NewCounterStore() *MapCounterStore {
+	return &MapCounterStore{counters: make(map[string]uint64)}
+}
+
+func (s *MapCounterStore) LastCounter(ctx context.Context, principal string) (uint64, bool, error) {
+	select {
+	case <-ctx.Done():
+		return 0, false, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counter, ok := s.counters[principal]
+	return counter, ok, nil
+}
+
+func (s *MapCounterStore) Advance(ctx context.Context, principal string, counter uint64) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.counters[principal]; ok && counter <= last {
+		return false, nil
+	}
+
+	s.counters[principal] = counter
+	return true, nil
+}
+
+// NewSecondFactor returns an authc.SecondFactor that verifies one-time passwords
+// of principals who have enrolled in KeyStore, only WithWindow is honored since
+// the other Options are carried by each Key
+func NewSecondFactor(keys KeyStore, counters CounterStore, opts ...Option) authc.SecondFactor {
+	return &secondFactor{
+		keys:     keys,
+		counters: counters,
+		window:   apply(opts...).Window,
+	}
+}
+
+func (f *secondFactor) Required(ctx context.Context, userDetails authc.UserDetails) (bool, error) {
+	key, err := f.keys.LoadKey(ctx, userDetails.Principal())
+	if err != nil {
+		return false, err
+	}
+
+	return key != nil, nil
+}
+
+func (f *secondFactor) Verify(ctx context.Context, userDetails authc.UserDetails, token authc.Token) error {
+	principal := userDetails.Principal()
+	key, err := f.keys.LoadKey(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		return ErrNotEnrolled
+	}
+
+	last, found, err := f.counters.LastCounter(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	opt := key.Options
+	opt.Window = f.window
+	opt.sanitize()
+
+	var (
+		counter uint64
+		ok      bool
+	)
+	if key.Type == TypeHOTP {
+		start := key.Counter
+		if found && last+1 > start {
+			start = last + 1
+		}
+		counter, ok = validateHOTP(token.Credentials(), key.Secret, start, &opt)
+	} else {
+		counter, ok = validateTOTP(token.Credentials(), key.Secret, nowFunc(), &opt)
+	}
+
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	if found && counter <= last {
+		return ErrReplayed
+	}
+
+	advanced, err := f.counters.Advance(ctx, principal, counter)
+	if err != nil {
+		return err
+	}
+
+	// lost the race against a concurrent verification
+	if !advanced {
+		return ErrReplayed
+	}
+
+	return nil
+}
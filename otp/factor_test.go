@@ -0,0 +1,86 @@
+package otp
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type mockKeyStore map[string]*Key
+
+func (s mockKeyStore) LoadKey(_ context.Context, principal string) (*Key, error) {
+	return s[principal], nil
+}
+
+func TestSecondFactorTOTP(t *testing.T) {
+	defer func() { nowFunc = time.Now }()
+	nowTime := time.Unix(1111111109, 0)
+	nowFunc = func() time.Time { return nowTime }
+
+	key, _ := GenerateKey("Shield", "archer")
+	factor := NewSecondFactor(mockKeyStore{"archer": key}, NewCounterStore())
+	archer := authc.NewBearerToken("archer")
+
+	required, err := factor.Required(context.TODO(), archer)
+	assert.NoError(t, err)
+	assert.True(t, required)
+
+	required, err = factor.Required(context.TODO(), authc.NewBearerToken("nobody"))
+	assert.NoError(t, err)
+	assert.False(t, required)
+
+	err = factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", "000000"))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	code := TOTP(key.Secret, nowTime)
+	err = factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", code))
+	assert.NoError(t, err)
+
+	err = factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", code))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	// previous step is still inside the drift window but older than the used one
+	err = factor.Verify(context.TODO(), archer,
+		authc.NewOneTimePasswordToken("archer", TOTP(key.Secret, nowTime.Add(-30*time.Second))))
+	assert.ErrorIs(t, err, ErrReplayed)
+
+	nowFunc = func() time.Time { return nowTime.Add(30 * time.Second) }
+	err = factor.Verify(context.TODO(), archer,
+		authc.NewOneTimePasswordToken("archer", TOTP(key.Secret, nowTime.Add(30*time.Second))))
+	assert.NoError(t, err)
+}
+
+func TestSecondFactorHOTP(t *testing.T) {
+	key := &Key{Type: TypeHOTP, Secret: rfcSecret, Options: defaultOptions}
+	factor := NewSecondFactor(mockKeyStore{"archer": key}, NewCounterStore(), WithWindow(3))
+	archer := authc.NewBearerToken("archer")
+
+	// counter 2 is within the look-ahead window
+	err := factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", "359152"))
+	assert.NoError(t, err)
+
+	err = factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", "287082"))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	err = factor.Verify(context.TODO(), archer, authc.NewOneTimePasswordToken("archer", "969429"))
+	assert.NoError(t, err)
+
+	err = factor.Verify(context.TODO(), authc.NewBearerToken("nobody"), authc.NewOneTimePasswordToken("nobody", "969429"))
+	assert.ErrorIs(t, err, ErrNotEnrolled)
+}
+
+func TestSecondFactorZeroOptions(t *testing.T) {
+	totp := &Key{Type: TypeTOTP, Secret: rfcSecret}
+	hotp := &Key{Type: TypeHOTP, Secret: rfcSecret}
+	factor := NewSecondFactor(mockKeyStore{"archer": totp, "bob": hotp}, NewCounterStore())
+
+	// zero Options fall back to defaults instead of panicking
+	err := factor.Verify(context.TODO(), authc.NewBearerToken("archer"),
+		authc.NewOneTimePasswordToken("archer", TOTP(rfcSecret, nowFunc())))
+	assert.NoError(t, err)
+
+	err = factor.Verify(context.TODO(), authc.NewBearerToken("bob"), authc.NewOneTimePasswordToken("bob", "755224"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,146 @@
+package otp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Key is a provisioned one-time password secret of an account
+	Key struct {
+		// Type is either "totp" or "hotp"
+		Type string
+		// Issuer names the provider or service the account belongs to
+		Issuer string
+		// Account names the account, usually the principal
+		Account string
+		// Secret is the shared secret
+		Secret []byte
+		// Counter is the initial HOTP counter
+		Counter uint64
+		// Options used to generate and verify codes, invalid
+		// attributes, e.g. of zero Options, fall back to defaults
+		Options Options
+	}
+)
+
+const (
+	// TypeTOTP is the Key type of time-based one-time password
+	TypeTOTP = "totp"
+	// TypeHOTP is the Key type of HMAC-based one-time password
+	TypeHOTP = "hotp"
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateKey provisions a TOTP Key with a random secret,
+// the secret size follows the HMAC output size of the algorithm
+func GenerateKey(issuer string, account string, opts ...Option) (*Key, error) {
+	opt := apply(opts...)
+
+	secret := make([]byte, opt.Algorithm.hash()().Size())
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Type:    TypeTOTP,
+		Issuer:  issuer,
+		Account: account,
+		Secret:  secret,
+		Options: *opt,
+	}, nil
+}
+
+// EncodedSecret returns the base32 encoded secret which can be typed in by users
+func (k *Key) EncodedSecret() string {
+	return b32.EncodeToString(k.Secret)
+}
+
+// URI returns the otpauth:// URI, it is usually rendered
+// as a QR code and scanned by authenticator apps
+func (k *Key) URI() string {
+	label := url.PathEscape(k.Account)
+	if len(k.Issuer) != 0 {
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+
+	query := url.Values{}
+	query.Set("secret", k.EncodedSecret())
+	if len(k.Issuer) != 0 {
+		query.Set("issuer", k.Issuer)
+	}
+	query.Set("algorithm", string(k.Options.Algorithm))
+	query.Set("digits", strconv.Itoa(k.Options.Digits))
+	if k.Type == TypeHOTP {
+		query.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(int(k.Options.Period/time.Second)))
+	}
+
+	return "otpauth://" + k.Type + "/" + label + "?" + query.Encode()
+}
+
+// ParseURI decodes an otpauth:// URI into Key
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "otpauth" {
+		return nil, ErrMalformedURI
+	}
+
+	key := &Key{Type: strings.ToLower(u.Host), Options: defaultOptions}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, ErrMalformedURI
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(label, ":"); i >= 0 {
+		key.Issuer, key.Account = label[:i], strings.TrimSpace(label[i+1:])
+	} else {
+		key.Account = label
+	}
+
+	query := u.Query()
+	key.Secret, err = b32.DecodeString(strings.ToUpper(strings.TrimRight(query.Get("secret"), "=")))
+	if err != nil || len(key.Secret) == 0 {
+		return nil, ErrMalformedURI
+	}
+
+	if issuer := query.Get("issuer"); len(issuer) != 0 {
+		key.Issuer = issuer
+	}
+
+	if alg := query.Get("algorithm"); len(alg) != 0 {
+		key.Options.Algorithm = Algorithm(strings.ToUpper(alg))
+		if key.Options.Algorithm.hash() == nil {
+			return nil, ErrMalformedURI
+		}
+	}
+
+	if v := query.Get("digits"); len(v) != 0 {
+		key.Options.Digits, err = strconv.Atoi(v)
+		if err != nil || key.Options.Digits < 6 || key.Options.Digits > 8 {
+			return nil, ErrMalformedURI
+		}
+	}
+
+	if v := query.Get("period"); len(v) != 0 {
+		period, err := strconv.Atoi(v)
+		if err != nil || period <= 0 {
+			return nil, ErrMalformedURI
+		}
+		key.Options.Period = time.Duration(period) * time.Second
+	}
+
+	if v := query.Get("counter"); len(v) != 0 {
+		if key.Counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, ErrMalformedURI
+		}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,203 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"time"
+)
+
+type (
+	// Algorithm is the HMAC hash function used to generate codes
+	Algorithm string
+
+	// Option can be used to customize Options
+	Option func(*Options)
+
+	// Options contains config attribute that can
+	// affect how one-time passwords are generated and verified
+	Options struct {
+		// Algorithm is the HMAC hash function
+		Algorithm Algorithm
+		// Digits is the length of a code, 6 or 8
+		Digits int
+		// Period is the TOTP time step
+		Period time.Duration
+		// Window is the number of steps accepted around the expected one,
+		// for TOTP it tolerates clock drift in both direction, for HOTP
+		// it is the look-ahead window used to resynchronize the counter
+		Window int
+	}
+)
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+var defaultOptions = Options{
+	Algorithm: SHA1,
+	Digits:    6,
+	Period:    30 * time.Second,
+	Window:    1,
+}
+
+func WithAlgorithm(alg Algorithm) Option {
+	return func(opt *Options) {
+		if alg.hash() != nil {
+			opt.Algorithm = alg
+		}
+	}
+}
+
+func WithDigits(digits int) Option {
+	return func(opt *Options) {
+		if digits >= 6 && digits <= 8 {
+			opt.Digits = digits
+		}
+	}
+}
+
+func WithPeriod(period time.Duration) Option {
+	return func(opt *Options) {
+		if period >= time.Second {
+			opt.Period = period
+		}
+	}
+}
+
+func WithWindow(window int) Option {
+	return func(opt *Options) {
+		if window >= 0 {
+			opt.Window = window
+		}
+	}
+}
+
+// HOTP generates the code for the counter as defined in RFC 4226
+func HOTP(secret []byte, counter uint64, opts ...Option) string {
+	return apply(opts...).generate(secret, counter)
+}
+
+// TOTP generates the code for the time as defined in RFC 6238
+func TOTP(secret []byte, t time.Time, opts ...Option) string {
+	opt := apply(opts...)
+	return opt.generate(secret, opt.counter(t))
+}
+
+// ValidateHOTP verifies the code against counters in [counter, counter+Window],
+// the matched counter is returned so that the caller can store counter+1
+func ValidateHOTP(code string, secret []byte, counter uint64, opts ...Option) (uint64, bool) {
+	return validateHOTP(code, secret, counter, apply(opts...))
+}
+
+// ValidateTOTP verifies the code against time steps in [t-Window, t+Window],
+// the matched time step is returned so that replay can be detected
+func ValidateTOTP(code string, secret []byte, t time.Time, opts ...Option) (uint64, bool) {
+	return validateTOTP(code, secret, t, apply(opts...))
+}
+
+func validateHOTP(code string, secret []byte, counter uint64, opt *Options) (uint64, bool) {
+	for i := 0; i <= opt.Window; i++ {
+		if opt.matches(code, secret, counter+uint64(i)) {
+			return counter + uint64(i), true
+		}
+	}
+
+	return 0, false
+}
+
+func validateTOTP(code string, secret []byte, t time.Time, opt *Options) (uint64, bool) {
+	counter := opt.counter(t)
+
+	for i := -opt.Window; i <= opt.Window; i++ {
+		if i < 0 && uint64(-i) > counter {
+			continue
+		}
+
+		step := uint64(int64(counter) + int64(i))
+		if opt.matches(code, secret, step) {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+func (alg Algorithm) hash() func() hash.Hash {
+	switch alg {
+	case SHA1:
+		return sha1.New
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return nil
+	}
+}
+
+// sanitize replaces invalid attributes, e.g. of zero Options, by the defaults,
+// so that generating codes neither panics nor overflows
+func (opt *Options) sanitize() {
+	if opt.Algorithm.hash() == nil {
+		opt.Algorithm = defaultOptions.Algorithm
+	}
+
+	if opt.Digits < 6 || opt.Digits > 8 {
+		opt.Digits = defaultOptions.Digits
+	}
+
+	if opt.Period < time.Second {
+		opt.Period = defaultOptions.Period
+	}
+
+	if opt.Window < 0 {
+		opt.Window = defaultOptions.Window
+	}
+}
+
+func (opt *Options) counter(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(opt.Period/time.Second)
+}
+
+func (opt *Options) matches(code string, secret []byte, counter uint64) bool {
+	expected := opt.generate(secret, counter)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1
+}
+
+func (opt *Options) generate(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(opt.Algorithm.hash(), secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < opt.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", opt.Digits, value%mod)
+}
+
+func apply(opts ...Option) *Options {
+	opt := defaultOptions
+
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &opt
+}
@@ -0,0 +1,95 @@
+package otp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 Appendix D
+	expected := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	for i, code := range expected {
+		assert.Equal(t, code, HOTP(rfcSecret, uint64(i)))
+	}
+
+	_, ok := ValidateHOTP("338314", rfcSecret, 2, WithWindow(1))
+	assert.False(t, ok)
+	counter, ok := ValidateHOTP("338314", rfcSecret, 2, WithWindow(2))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(4), counter)
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 Appendix B
+	secrets := map[Algorithm][]byte{
+		SHA1:   rfcSecret,
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	cases := []struct {
+		time int64
+		alg  Algorithm
+		code string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1234567890, SHA512, "93441116"},
+		{20000000000, SHA1, "65353130"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.code, TOTP(secrets[c.alg], time.Unix(c.time, 0), WithAlgorithm(c.alg), WithDigits(8)))
+	}
+}
+
+func TestValidateTOTPWithDrift(t *testing.T) {
+	nowTime := time.Unix(1111111109, 0)
+	code := TOTP(rfcSecret, nowTime)
+
+	step, ok := ValidateTOTP(code, rfcSecret, nowTime.Add(30*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1111111109/30), step)
+
+	_, ok = ValidateTOTP(code, rfcSecret, nowTime.Add(30*time.Second), WithWindow(0))
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(code, rfcSecret, nowTime.Add(-60*time.Second))
+	assert.False(t, ok)
+}
+
+func TestKeyURI(t *testing.T) {
+	key, err := GenerateKey("Shield", "archer@example.com", WithDigits(8))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, len(key.Secret))
+
+	uri := key.URI()
+	assert.Contains(t, uri, "otpauth://totp/Shield:archer@example.com?")
+	assert.Contains(t, uri, "secret="+key.EncodedSecret())
+	assert.Contains(t, uri, "digits=8")
+
+	parsed, err := ParseURI(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, key.Type, parsed.Type)
+	assert.Equal(t, key.Issuer, parsed.Issuer)
+	assert.Equal(t, key.Account, parsed.Account)
+	assert.Equal(t, key.Secret, parsed.Secret)
+	assert.Equal(t, key.Options.Digits, parsed.Options.Digits)
+	assert.Equal(t, key.Options.Period, parsed.Options.Period)
+
+	_, err = ParseURI("otpauth://totp/Shield:archer?secret=!!!")
+	assert.ErrorIs(t, err, ErrMalformedURI)
+
+	// codes longer than 8 digits overflow
+	for _, digits := range []string{"0", "5", "9", "32"} {
+		_, err = ParseURI("otpauth://totp/Shield:archer?secret=" + key.EncodedSecret() + "&digits=" + digits)
+		assert.ErrorIs(t, err, ErrMalformedURI, digits)
+	}
+}
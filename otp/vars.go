@@ -0,0 +1,21 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"time"
+)
+
+var (
+	nowFunc = time.Now
+
+	// ErrMalformedURI is returned when the otpauth:// URI can not be parsed
+	ErrMalformedURI = errors.New("malformed otpauth uri")
+	// ErrNotEnrolled is returned when the principal has no Key provisioned
+	ErrNotEnrolled = errors.New("one-time password not enrolled")
+	// ErrInvalidCode is returned when the code does not match
+	ErrInvalidCode = fmt.Errorf("%w: invalid one-time password", authc.ErrUnauthenticated)
+	// ErrReplayed is returned when the code has already been used
+	ErrReplayed = fmt.Errorf("%w: one-time password replayed", authc.ErrUnauthenticated)
+)
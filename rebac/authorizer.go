@@ -0,0 +1,174 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+)
+
+type (
+	// Authorizer is an authz.Authorizer which is also able to check relations,
+	// authorities of the form object#relation, e.g. doc:readme#viewer, are
+	// checked against the Checker so that relations can be required alongside
+	// roles and authorities, e.g. by authz.NewAuthorityVoter
+	Authorizer interface {
+		authz.Authorizer
+		// HasRelation returns true if the user has the relation with the object
+		HasRelation(ctx context.Context, userDetails authc.UserDetails, object Object, relation string) bool
+		// CheckRelation is like HasRelation but tells why it fails, an authz.ForbiddenError
+		// is returned if the user lacks the relation, or the TupleStore failure otherwise
+		CheckRelation(ctx context.Context, userDetails authc.UserDetails, object Object, relation string) error
+	}
+
+	// Options of Authorizer
+	Options struct {
+		// UserNamespace is the namespace of authc.UserDetails principals
+		UserNamespace string
+	}
+
+	Option func(*Options)
+
+	authorizer struct {
+		authz.Authorizer
+		checker *Checker
+		opt     *Options
+	}
+)
+
+var (
+	_ Authorizer = (*authorizer)(nil)
+
+	defaultOptions = Options{
+		UserNamespace: "user",
+	}
+)
+
+// WithUserNamespace sets the namespace of principals, user by default
+func WithUserNamespace(namespace string) Option {
+	return func(opt *Options) {
+		if len(namespace) != 0 {
+			opt.UserNamespace = namespace
+		}
+	}
+}
+
+// NewAuthorizer wraps the authz.Authorizer so that it also checks relations
+func NewAuthorizer(az authz.Authorizer, checker *Checker, opts ...Option) Authorizer {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &authorizer{Authorizer: az, checker: checker, opt: &opt}
+}
+
+func (z *authorizer) HasRelation(ctx context.Context, userDetails authc.UserDetails, object Object, relation string) bool {
+	return z.CheckRelation(ctx, userDetails, object, relation) == nil
+}
+
+func (z *authorizer) CheckRelation(ctx context.Context, userDetails authc.UserDetails, object Object, relation string) error {
+	user := User(NewObject(z.opt.UserNamespace, userDetails.Principal()))
+	ok, err := z.checker.Check(ctx, object, relation, user)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &authz.ForbiddenError{Requirement: "hasRelation(" + Userset(object, relation).String() + ")"}
+	}
+
+	return nil
+}
+
+func (z *authorizer) HasAuthority(ctx context.Context, userDetails authc.UserDetails, authority authz.Authority) bool {
+	return z.CheckAuthority(ctx, userDetails, authority) == nil
+}
+
+func (z *authorizer) HasAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...authz.Authority) bool {
+	return z.CheckAnyAuthority(ctx, userDetails, authorities...) == nil
+}
+
+func (z *authorizer) HasAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...authz.Authority) bool {
+	return z.CheckAllAuthority(ctx, userDetails, authorities...) == nil
+}
+
+func (z *authorizer) CheckAuthority(ctx context.Context, userDetails authc.UserDetails, authority authz.Authority) error {
+	return z.checkAnyAuthority(ctx, userDetails, authz.Requirement("hasAuthority", authority), authority)
+}
+
+func (z *authorizer) CheckAnyAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...authz.Authority) error {
+	return z.checkAnyAuthority(ctx, userDetails, authz.Requirement("hasAnyAuthority", authorities...), authorities...)
+}
+
+func (z *authorizer) CheckAllAuthority(ctx context.Context, userDetails authc.UserDetails, authorities ...authz.Authority) error {
+	for _, authority := range authorities {
+		if err := z.CheckAuthority(ctx, userDetails, authority); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (z *authorizer) Logout(ctx context.Context, userDetails authc.UserDetails) {
+	if la, ok := z.Authorizer.(authc.LogoutAware); ok {
+		la.Logout(ctx, userDetails)
+	}
+}
+
+// checkAnyAuthority checks relations directly, the other authorities are
+// left to the wrapped authz.Authorizer, a failure is only reported if
+// access is not granted otherwise, like authz.Authorizer does
+func (z *authorizer) checkAnyAuthority(ctx context.Context, userDetails authc.UserDetails, requirement string, authorities ...authz.Authority) error {
+	var checkErr error
+	var others []authz.Authority
+	for _, authority := range authorities {
+		userset, ok := z.relation(authority)
+		if !ok {
+			others = append(others, authority)
+			continue
+		}
+
+		err := z.CheckRelation(ctx, userDetails, userset.Object, userset.Relation)
+		if err == nil {
+			return nil
+		}
+
+		if checkErr == nil && !errors.Is(err, authz.ErrForbidden) {
+			checkErr = err
+		}
+	}
+
+	if len(others) != 0 {
+		err := z.Authorizer.CheckAnyAuthority(ctx, userDetails, others...)
+		if err == nil {
+			return nil
+		}
+
+		if checkErr == nil && !errors.Is(err, authz.ErrForbidden) {
+			checkErr = err
+		}
+	}
+
+	if checkErr != nil {
+		return checkErr
+	}
+
+	return &authz.ForbiddenError{Requirement: requirement}
+}
+
+// relation parses the authority as object#relation,
+// it is false unless the relation is configured
+func (z *authorizer) relation(authority authz.Authority) (Subject, bool) {
+	userset, err := ParseSubject(authority.Desc())
+	if err != nil || len(userset.Relation) == 0 {
+		return Subject{}, false
+	}
+
+	if _, err = z.checker.config.rewrite(userset.Object.Namespace, userset.Relation); err != nil {
+		return Subject{}, false
+	}
+
+	return userset, true
+}
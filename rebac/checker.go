@@ -0,0 +1,252 @@
+package rebac
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// Checker evaluates relations against the Config and the TupleStore
+	Checker struct {
+		config *Config
+		store  TupleStore
+	}
+
+	// Tree is the userset tree returned by Expand
+	Tree struct {
+		// Op is leaf, union, intersection or exclusion
+		Op string
+		// Userset is the object#relation the node expands, if any
+		Userset *Subject
+		// Subjects are the direct subjects of a leaf,
+		// usersets are left for the caller to expand
+		Subjects []Subject
+		// Children of union, intersection and exclusion
+		Children []*Tree
+	}
+
+	// visited usersets on the current path, guards against cycles
+	visited map[Subject]bool
+)
+
+const (
+	OpLeaf         = "leaf"
+	OpUnion        = "union"
+	OpIntersection = "intersection"
+	OpExclusion    = "exclusion"
+)
+
+func NewChecker(config *Config, store TupleStore) *Checker {
+	return &Checker{config: config, store: store}
+}
+
+// Check returns true if the subject has the relation with the object, the
+// subject can be a user, e.g. user:alice, or a userset, e.g. group:eng#member
+func (c *Checker) Check(ctx context.Context, object Object, relation string, subject Subject) (bool, error) {
+	return c.check(ctx, object, relation, subject, make(visited))
+}
+
+// Expand returns the userset tree of object#relation
+func (c *Checker) Expand(ctx context.Context, object Object, relation string) (*Tree, error) {
+	return c.expand(ctx, object, relation, make(visited))
+}
+
+// ListObjects returns the objects of the namespace the subject has the relation with
+func (c *Checker) ListObjects(ctx context.Context, namespace string, relation string, subject Subject) ([]Object, error) {
+	if _, err := c.config.rewrite(namespace, relation); err != nil {
+		return nil, err
+	}
+
+	objects, err := c.store.Objects(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Object
+	for _, object := range objects {
+		ok, err := c.Check(ctx, object, relation, subject)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			result = append(result, object)
+		}
+	}
+
+	return result, nil
+}
+
+///=====================================
+///		    Private
+///=====================================
+
+func (c *Checker) check(ctx context.Context, object Object, relation string, subject Subject, path visited) (bool, error) {
+	userset := Userset(object, relation)
+	if userset == subject {
+		return true, nil
+	}
+
+	if path[userset] {
+		return false, nil
+	}
+
+	rewrite, err := c.config.rewrite(object.Namespace, relation)
+	if err != nil {
+		return false, err
+	}
+
+	path[userset] = true
+	defer delete(path, userset)
+
+	return c.checkRewrite(ctx, object, relation, rewrite, subject, path)
+}
+
+func (c *Checker) checkRewrite(ctx context.Context, object Object, relation string, rewrite *Rewrite, subject Subject, path visited) (bool, error) {
+	switch rewrite.kind {
+	case rewriteThis:
+		subjects, err := c.store.Read(ctx, object, relation)
+		if err != nil {
+			return false, err
+		}
+
+		for _, s := range subjects {
+			if s == subject {
+				return true, nil
+			}
+
+			if len(s.Relation) == 0 {
+				continue
+			}
+
+			ok, err := c.check(ctx, s.Object, s.Relation, subject, path)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	case rewriteComputed:
+		return c.check(ctx, object, rewrite.relation, subject, path)
+	case rewriteTupleToUserset:
+		subjects, err := c.store.Read(ctx, object, rewrite.tupleset)
+		if err != nil {
+			return false, err
+		}
+
+		for _, s := range subjects {
+			ok, err := c.check(ctx, s.Object, rewrite.relation, subject, path)
+			if errors.Is(err, ErrUnknownRelation) || errors.Is(err, ErrUnknownNamespace) {
+				// the related object does not define the relation
+				continue
+			}
+
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	case rewriteUnion:
+		for _, child := range rewrite.children {
+			ok, err := c.checkRewrite(ctx, object, relation, child, subject, path)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	case rewriteIntersection:
+		for _, child := range rewrite.children {
+			ok, err := c.checkRewrite(ctx, object, relation, child, subject, path)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	default:
+		ok, err := c.checkRewrite(ctx, object, relation, rewrite.children[0], subject, path)
+		if err != nil || !ok {
+			return false, err
+		}
+
+		excluded, err := c.checkRewrite(ctx, object, relation, rewrite.children[1], subject, path)
+		return !excluded, err
+	}
+}
+
+func (c *Checker) expand(ctx context.Context, object Object, relation string, path visited) (*Tree, error) {
+	userset := Userset(object, relation)
+	if path[userset] {
+		return &Tree{Op: OpLeaf, Userset: &userset}, nil
+	}
+
+	rewrite, err := c.config.rewrite(object.Namespace, relation)
+	if err != nil {
+		return nil, err
+	}
+
+	path[userset] = true
+	defer delete(path, userset)
+
+	tree, err := c.expandRewrite(ctx, object, relation, rewrite, path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree.Userset = &userset
+	return tree, nil
+}
+
+func (c *Checker) expandRewrite(ctx context.Context, object Object, relation string, rewrite *Rewrite, path visited) (*Tree, error) {
+	switch rewrite.kind {
+	case rewriteThis:
+		subjects, err := c.store.Read(ctx, object, relation)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Tree{Op: OpLeaf, Subjects: subjects}, nil
+	case rewriteComputed:
+		return c.expand(ctx, object, rewrite.relation, path)
+	case rewriteTupleToUserset:
+		subjects, err := c.store.Read(ctx, object, rewrite.tupleset)
+		if err != nil {
+			return nil, err
+		}
+
+		tree := &Tree{Op: OpUnion}
+		for _, s := range subjects {
+			child, err := c.expand(ctx, s.Object, rewrite.relation, path)
+			if errors.Is(err, ErrUnknownRelation) || errors.Is(err, ErrUnknownNamespace) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			tree.Children = append(tree.Children, child)
+		}
+
+		return tree, nil
+	default:
+		tree := &Tree{Op: map[rewriteKind]string{
+			rewriteUnion:        OpUnion,
+			rewriteIntersection: OpIntersection,
+			rewriteExclusion:    OpExclusion,
+		}[rewrite.kind]}
+
+		for _, r := range rewrite.children {
+			child, err := c.expandRewrite(ctx, object, relation, r, path)
+			if err != nil {
+				return nil, err
+			}
+
+			tree.Children = append(tree.Children, child)
+		}
+
+		return tree, nil
+	}
+}
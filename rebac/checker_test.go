@@ -0,0 +1,215 @@
+package rebac
+
+import (
+	"context"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ud string
+
+func (o ud) Principal() string {
+	return string(o)
+}
+
+type rolesRealm struct {
+}
+
+func (r *rolesRealm) LoadRoles(context.Context, authc.UserDetails) ([]authz.Role, error) {
+	return []authz.Role{authz.NewRole("staff")}, nil
+}
+
+func (r *rolesRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return []authz.Authority{authz.NewAuthority("doc:read")}, nil
+}
+
+type listingStore struct {
+	TupleStore
+	listed int
+}
+
+func (s *listingStore) Objects(ctx context.Context, namespace string) ([]Object, error) {
+	s.listed++
+	return s.TupleStore.Objects(ctx, namespace)
+}
+
+func newChecker(t *testing.T) *Checker {
+	config := MustNewConfig(
+		NewNamespace("user"),
+		NewNamespace("group").
+			Relation("member", nil),
+		NewNamespace("folder").
+			Relation("parent", nil).
+			Relation("viewer", Union(This(), TupleToUserset("parent", "viewer"))),
+		NewNamespace("doc").
+			Relation("parent", nil).
+			Relation("owner", nil).
+			Relation("banned", nil).
+			Relation("editor", Union(This(), Computed("owner"))).
+			Relation("viewer", Exclusion(
+				Union(This(), Computed("editor"), TupleToUserset("parent", "viewer")),
+				Computed("banned"),
+			)).
+			Relation("auditor", Intersection(Computed("viewer"), TupleToUserset("parent", "viewer"))),
+	)
+
+	store := NewTupleStore()
+	var tuples []Tuple
+	for _, text := range []string{
+		"group:eng#member@user:alice",
+		"group:eng#member@group:ops#member",
+		"group:ops#member@user:bob",
+		"folder:root#viewer@group:eng#member",
+		"folder:src#parent@folder:root",
+		"doc:readme#parent@folder:src",
+		"doc:readme#owner@user:carol",
+		"doc:readme#banned@user:bob",
+		"doc:plan#editor@user:dave",
+		"doc:plan#viewer@user:erin",
+	} {
+		tuples = append(tuples, MustParseTuple(text))
+	}
+
+	assert.NoError(t, store.Write(context.Background(), tuples...))
+	return NewChecker(config, store)
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	checker := newChecker(t)
+	readme := NewObject("doc", "readme")
+	user := func(id string) Subject {
+		return User(NewObject("user", id))
+	}
+
+	cases := []struct {
+		relation string
+		user     string
+		expected bool
+	}{
+		{"owner", "carol", true},
+		{"editor", "carol", true},
+		{"viewer", "carol", true},
+		// inherited from folder:root through folder:src
+		{"viewer", "alice", true},
+		// group:ops is nested in group:eng, but bob is banned
+		{"viewer", "bob", false},
+		{"editor", "alice", false},
+		{"viewer", "mallory", false},
+		// carol is a viewer but not of the parent folder
+		{"auditor", "carol", false},
+		{"auditor", "alice", true},
+	}
+
+	for _, c := range cases {
+		ok, err := checker.Check(ctx, readme, c.relation, user(c.user))
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, ok, c.relation+"@"+c.user)
+	}
+
+	ok, err := checker.Check(ctx, NewObject("folder", "src"), "viewer", Userset(NewObject("group", "eng"), "member"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = checker.Check(ctx, readme, "commenter", user("alice"))
+	assert.ErrorIs(t, err, ErrUnknownRelation)
+
+	_, err = checker.Check(ctx, NewObject("video", "1"), "viewer", user("alice"))
+	assert.ErrorIs(t, err, ErrUnknownNamespace)
+}
+
+func TestCheckCycle(t *testing.T) {
+	ctx := context.Background()
+	checker := newChecker(t)
+	assert.NoError(t, checker.store.Write(ctx,
+		MustParseTuple("group:ops#member@group:eng#member"),
+		MustParseTuple("folder:root#parent@folder:src"),
+	))
+
+	ok, err := checker.Check(ctx, NewObject("group", "eng"), "member", User(NewObject("user", "bob")))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = checker.Check(ctx, NewObject("folder", "src"), "viewer", User(NewObject("user", "mallory")))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	tree, err := checker.Expand(ctx, NewObject("group", "eng"), "member")
+	assert.NoError(t, err)
+	assert.Equal(t, OpLeaf, tree.Op)
+}
+
+func TestExpand(t *testing.T) {
+	checker := newChecker(t)
+
+	tree, err := checker.Expand(context.Background(), NewObject("doc", "plan"), "viewer")
+	assert.NoError(t, err)
+	assert.Equal(t, "doc:plan#viewer", tree.Userset.String())
+	assert.Equal(t, OpExclusion, tree.Op)
+
+	union := tree.Children[0]
+	assert.Equal(t, OpUnion, union.Op)
+	assert.Equal(t, []Subject{User(NewObject("user", "erin"))}, union.Children[0].Subjects)
+
+	editor := union.Children[1]
+	assert.Equal(t, "doc:plan#editor", editor.Userset.String())
+	assert.Equal(t, []Subject{User(NewObject("user", "dave"))}, editor.Children[0].Subjects)
+
+	// no parent
+	assert.Empty(t, union.Children[2].Children)
+}
+
+func TestListObjects(t *testing.T) {
+	ctx := context.Background()
+	checker := newChecker(t)
+
+	objects, err := checker.ListObjects(ctx, "doc", "viewer", User(NewObject("user", "alice")))
+	assert.NoError(t, err)
+	assert.Equal(t, []Object{NewObject("doc", "readme")}, objects)
+
+	objects, err = checker.ListObjects(ctx, "doc", "viewer", User(NewObject("user", "dave")))
+	assert.NoError(t, err)
+	assert.Equal(t, []Object{NewObject("doc", "plan")}, objects)
+
+	_, err = checker.ListObjects(ctx, "doc", "commenter", User(NewObject("user", "dave")))
+	assert.ErrorIs(t, err, ErrUnknownRelation)
+}
+
+func TestAuthorizer(t *testing.T) {
+	ctx := context.Background()
+	checker := newChecker(t)
+	azer := NewAuthorizer(authz.NewAuthorizer(&rolesRealm{}), checker)
+
+	assert.True(t, azer.HasRelation(ctx, ud("alice"), NewObject("doc", "readme"), "viewer"))
+	err := azer.CheckRelation(ctx, ud("bob"), NewObject("doc", "readme"), "viewer")
+	assert.ErrorIs(t, err, authz.ErrForbidden)
+	assert.EqualError(t, err, "forbidden: hasRelation(doc:readme#viewer)")
+	assert.True(t, azer.HasRole(ctx, ud("bob"), authz.NewRole("staff")))
+
+	// relations as authorities, alongside roles and authorities
+	assert.True(t, azer.HasAllAuthority(ctx, ud("carol"), authz.NewAuthority("doc:readme#owner"), authz.NewAuthority("doc:readme#viewer")))
+	assert.False(t, azer.HasAuthority(ctx, ud("carol"), authz.NewAuthority("doc:plan#viewer")))
+	assert.True(t, azer.HasAnyAuthority(ctx, ud("carol"), authz.NewAuthority("doc:plan#viewer"), authz.NewAuthority("doc:read")))
+	assert.False(t, azer.HasAuthority(ctx, ud("carol"), authz.NewAuthority("doc:readme#commenter")))
+	err = azer.CheckAnyAuthority(ctx, ud("carol"), authz.NewAuthority("doc:plan#viewer"), authz.NewAuthority("doc:delete"))
+	assert.EqualError(t, err, "forbidden: hasAnyAuthority(doc:plan#viewer, doc:delete)")
+
+	voter := authz.NewAuthorityVoter(azer)
+	vote, err := voter.Vote(ctx, ud("alice"), nil, []any{authz.NewAuthority("doc:readme#viewer")})
+	assert.NoError(t, err)
+	assert.Equal(t, authz.Grant, vote)
+	vote, err = voter.Vote(ctx, ud("bob"), nil, []any{authz.NewAuthority("doc:readme#viewer")})
+	assert.NoError(t, err)
+	assert.Equal(t, authz.Deny, vote)
+
+	// relations are checked directly, objects are never listed
+	store := &listingStore{TupleStore: checker.store}
+	listing := NewAuthorizer(authz.NewAuthorizer(&rolesRealm{}), NewChecker(checker.config, store))
+	assert.True(t, listing.HasAuthority(ctx, ud("alice"), authz.NewAuthority("doc:readme#viewer")))
+	assert.Equal(t, 0, store.listed)
+
+	members := NewAuthorizer(authz.NewAuthorizer(&rolesRealm{}), checker, WithUserNamespace("group"))
+	assert.False(t, members.HasRelation(ctx, ud("eng"), NewObject("doc", "readme"), "viewer"))
+}
@@ -0,0 +1,322 @@
+package rebac
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type (
+	// Rewrite computes the subjects of a relation, see This, Computed,
+	// TupleToUserset, Union, Intersection and Exclusion
+	Rewrite struct {
+		kind     rewriteKind
+		relation string
+		tupleset string
+		children []*Rewrite
+	}
+
+	// Namespace defines the relations of the objects of a namespace
+	Namespace struct {
+		Name      string
+		relations map[string]*Rewrite
+	}
+
+	// Config is a set of namespaces
+	Config struct {
+		namespaces map[string]*Namespace
+	}
+
+	rewriteKind int
+
+	rewriteJSON struct {
+		This           *struct{} `json:"this"`
+		Computed       string    `json:"computedUserset"`
+		TupleToUserset *struct {
+			Tupleset string `json:"tupleset"`
+			Computed string `json:"computedUserset"`
+		} `json:"tupleToUserset"`
+		Union        []*rewriteJSON `json:"union"`
+		Intersection []*rewriteJSON `json:"intersection"`
+		Exclusion    []*rewriteJSON `json:"exclusion"`
+	}
+
+	configJSON struct {
+		Namespaces []struct {
+			Name      string                  `json:"name"`
+			Relations map[string]*rewriteJSON `json:"relations"`
+		} `json:"namespaces"`
+	}
+)
+
+const (
+	rewriteThis rewriteKind = iota
+	rewriteComputed
+	rewriteTupleToUserset
+	rewriteUnion
+	rewriteIntersection
+	rewriteExclusion
+)
+
+var (
+	// ErrMalformedConfig is returned when a Config is invalid
+	ErrMalformedConfig = errors.New("malformed namespace config")
+	// ErrUnknownNamespace is returned when a namespace is not configured
+	ErrUnknownNamespace = errors.New("unknown namespace")
+	// ErrUnknownRelation is returned when a relation is not configured
+	ErrUnknownRelation = errors.New("unknown relation")
+)
+
+// This returns the subjects of the tuples stored for the relation
+func This() *Rewrite {
+	return &Rewrite{kind: rewriteThis}
+}
+
+// Computed returns the subjects of another relation of the same object,
+// e.g. editors are viewers
+func Computed(relation string) *Rewrite {
+	return &Rewrite{kind: rewriteComputed, relation: relation}
+}
+
+// TupleToUserset follows the tupleset relation to other objects and returns
+// their subjects of the computed relation, e.g. viewers of the parent folder
+// are viewers of the document
+func TupleToUserset(tupleset string, computed string) *Rewrite {
+	return &Rewrite{kind: rewriteTupleToUserset, tupleset: tupleset, relation: computed}
+}
+
+// Union returns the subjects of any child
+func Union(children ...*Rewrite) *Rewrite {
+	return &Rewrite{kind: rewriteUnion, children: children}
+}
+
+// Intersection returns the subjects of every child
+func Intersection(children ...*Rewrite) *Rewrite {
+	return &Rewrite{kind: rewriteIntersection, children: children}
+}
+
+// Exclusion returns the subjects of base except those of subtract
+func Exclusion(base *Rewrite, subtract *Rewrite) *Rewrite {
+	return &Rewrite{kind: rewriteExclusion, children: []*Rewrite{base, subtract}}
+}
+
+///=====================================
+///		    Namespace
+///=====================================
+
+func NewNamespace(name string) *Namespace {
+	return &Namespace{Name: name, relations: make(map[string]*Rewrite)}
+}
+
+// Relation defines the relation, a nil Rewrite is This
+func (ns *Namespace) Relation(name string, rewrite *Rewrite) *Namespace {
+	if rewrite == nil {
+		rewrite = This()
+	}
+
+	ns.relations[name] = rewrite
+	return ns
+}
+
+// Relations returns the sorted relation names
+func (ns *Namespace) Relations() []string {
+	names := make([]string, 0, len(ns.relations))
+	for name := range ns.relations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (ns *Namespace) validate(rewrite *Rewrite, relation string) error {
+	switch rewrite.kind {
+	case rewriteComputed:
+		if _, ok := ns.relations[rewrite.relation]; !ok {
+			return fmt.Errorf("%w: %s#%s computes unknown relation %s", ErrMalformedConfig, ns.Name, relation, rewrite.relation)
+		}
+	case rewriteTupleToUserset:
+		if _, ok := ns.relations[rewrite.tupleset]; !ok {
+			return fmt.Errorf("%w: %s#%s follows unknown relation %s", ErrMalformedConfig, ns.Name, relation, rewrite.tupleset)
+		}
+
+		if len(rewrite.relation) == 0 {
+			return fmt.Errorf("%w: %s#%s has empty computed relation", ErrMalformedConfig, ns.Name, relation)
+		}
+	case rewriteUnion, rewriteIntersection, rewriteExclusion:
+		if len(rewrite.children) == 0 {
+			return fmt.Errorf("%w: %s#%s has empty set operation", ErrMalformedConfig, ns.Name, relation)
+		}
+
+		for _, child := range rewrite.children {
+			if child == nil {
+				return fmt.Errorf("%w: %s#%s has nil rewrite", ErrMalformedConfig, ns.Name, relation)
+			}
+
+			if err := ns.validate(child, relation); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+///=====================================
+///		    Config
+///=====================================
+
+// NewConfig validates and returns the Config of the namespaces
+func NewConfig(namespaces ...*Namespace) (*Config, error) {
+	c := &Config{namespaces: make(map[string]*Namespace, len(namespaces))}
+	for _, ns := range namespaces {
+		if len(ns.Name) == 0 {
+			return nil, fmt.Errorf("%w: namespace has no name", ErrMalformedConfig)
+		}
+
+		if _, ok := c.namespaces[ns.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate namespace %s", ErrMalformedConfig, ns.Name)
+		}
+
+		for _, relation := range ns.Relations() {
+			if err := ns.validate(ns.relations[relation], relation); err != nil {
+				return nil, err
+			}
+		}
+
+		c.namespaces[ns.Name] = ns
+	}
+
+	return c, nil
+}
+
+// MustNewConfig is like NewConfig but panics if the Config is invalid
+func MustNewConfig(namespaces ...*Namespace) *Config {
+	c, err := NewConfig(namespaces...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// ParseConfig parses the JSON format of Config, e.g.
+//
+//	{"namespaces": [
+//	  {"name": "user"},
+//	  {"name": "folder", "relations": {"viewer": {}}},
+//	  {"name": "doc", "relations": {
+//	    "parent": {},
+//	    "owner": {"this": {}},
+//	    "viewer": {"union": [
+//	      {"this": {}},
+//	      {"computedUserset": "owner"},
+//	      {"tupleToUserset": {"tupleset": "parent", "computedUserset": "viewer"}}
+//	    ]}
+//	  }}
+//	]}
+//
+// An empty rewrite is this, exclusion takes the base and the subtracted rewrite
+func ParseConfig(data []byte) (*Config, error) {
+	var doc configJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedConfig, err)
+	}
+
+	namespaces := make([]*Namespace, 0, len(doc.Namespaces))
+	for _, n := range doc.Namespaces {
+		ns := NewNamespace(n.Name)
+		for relation, raw := range n.Relations {
+			rewrite, err := raw.rewrite()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s#%s: %v", ErrMalformedConfig, n.Name, relation, err)
+			}
+
+			ns.Relation(relation, rewrite)
+		}
+
+		namespaces = append(namespaces, ns)
+	}
+
+	return NewConfig(namespaces...)
+}
+
+// Namespace returns the Namespace with the name
+func (c *Config) Namespace(name string) (*Namespace, bool) {
+	ns, ok := c.namespaces[name]
+	return ns, ok
+}
+
+func (c *Config) rewrite(namespace string, relation string) (*Rewrite, error) {
+	ns, ok := c.namespaces[namespace]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownNamespace, namespace)
+	}
+
+	rewrite, ok := ns.relations[relation]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s#%s", ErrUnknownRelation, namespace, relation)
+	}
+
+	return rewrite, nil
+}
+
+func (r *rewriteJSON) rewrite() (*Rewrite, error) {
+	if r == nil {
+		return This(), nil
+	}
+
+	var rewrites []*Rewrite
+	if r.This != nil {
+		rewrites = append(rewrites, This())
+	}
+
+	if len(r.Computed) != 0 {
+		rewrites = append(rewrites, Computed(r.Computed))
+	}
+
+	if r.TupleToUserset != nil {
+		rewrites = append(rewrites, TupleToUserset(r.TupleToUserset.Tupleset, r.TupleToUserset.Computed))
+	}
+
+	for _, op := range []struct {
+		children []*rewriteJSON
+		f        func(...*Rewrite) *Rewrite
+	}{
+		{r.Union, Union},
+		{r.Intersection, Intersection},
+		{r.Exclusion, func(children ...*Rewrite) *Rewrite {
+			return &Rewrite{kind: rewriteExclusion, children: children}
+		}},
+	} {
+		if op.children == nil {
+			continue
+		}
+
+		children := make([]*Rewrite, 0, len(op.children))
+		for _, child := range op.children {
+			rewrite, err := child.rewrite()
+			if err != nil {
+				return nil, err
+			}
+
+			children = append(children, rewrite)
+		}
+
+		rewrites = append(rewrites, op.f(children...))
+	}
+
+	switch len(rewrites) {
+	case 0:
+		return This(), nil
+	case 1:
+		if rewrites[0].kind == rewriteExclusion && len(rewrites[0].children) != 2 {
+			return nil, errors.New("exclusion expects base and subtract")
+		}
+
+		return rewrites[0], nil
+	default:
+		return nil, errors.New("rewrite must have a single operation")
+	}
+}
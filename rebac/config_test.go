@@ -0,0 +1,110 @@
+package rebac
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseTuple(t *testing.T) {
+	tuple, err := ParseTuple("doc:readme#viewer@group:eng#member")
+	assert.NoError(t, err)
+	assert.Equal(t, NewObject("doc", "readme"), tuple.Object)
+	assert.Equal(t, "viewer", tuple.Relation)
+	assert.Equal(t, Userset(NewObject("group", "eng"), "member"), tuple.Subject)
+	assert.Equal(t, "doc:readme#viewer@group:eng#member", tuple.String())
+
+	for _, text := range []string{
+		"doc:readme#viewer",
+		"doc:readme@user:alice",
+		"doc:readme#@user:alice",
+		"readme#viewer@user:alice",
+		"doc:readme#viewer@user:alice#",
+		"doc:readme#viewer@alice",
+	} {
+		_, err = ParseTuple(text)
+		assert.ErrorIs(t, err, ErrMalformedTuple, text)
+	}
+
+	assert.Panics(t, func() { MustParseTuple("doc") })
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"namespaces": [
+		{"name": "user"},
+		{"name": "folder", "relations": {"viewer": {}}},
+		{"name": "doc", "relations": {
+			"parent": {},
+			"owner": {"this": {}},
+			"banned": null,
+			"viewer": {"exclusion": [
+				{"union": [
+					{"this": {}},
+					{"computedUserset": "owner"},
+					{"tupleToUserset": {"tupleset": "parent", "computedUserset": "viewer"}}
+				]},
+				{"computedUserset": "banned"}
+			]}
+		}}
+	]}`))
+	assert.NoError(t, err)
+
+	ns, ok := config.Namespace("doc")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"banned", "owner", "parent", "viewer"}, ns.Relations())
+
+	ctx := context.Background()
+	store := NewTupleStore()
+	assert.NoError(t, store.Write(ctx,
+		MustParseTuple("folder:root#viewer@user:alice"),
+		MustParseTuple("doc:readme#parent@folder:root"),
+	))
+
+	ok, err = NewChecker(config, store).Check(ctx, NewObject("doc", "readme"), "viewer", User(NewObject("user", "alice")))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestConfigMalformed(t *testing.T) {
+	cases := []string{
+		`{"namespaces": [{"name": "doc", "relations": {"viewer": {"computedUserset": "editor"}}}]}`,
+		`{"namespaces": [{"name": "doc", "relations": {"viewer": {"tupleToUserset": {"tupleset": "parent", "computedUserset": "viewer"}}}}]}`,
+		`{"namespaces": [{"name": "doc", "relations": {"viewer": {"this": {}, "computedUserset": "viewer"}}}]}`,
+		`{"namespaces": [{"name": "doc", "relations": {"viewer": {"exclusion": [{}]}}}]}`,
+		`{"namespaces": [{"name": "doc", "relations": {"viewer": {"union": []}}}]}`,
+		`{"namespaces": [{"name": "doc"}, {"name": "doc"}]}`,
+		`{"namespaces": [{"name": ""}]}`,
+		`{"namespaces": {}}`,
+	}
+
+	for _, c := range cases {
+		_, err := ParseConfig([]byte(c))
+		assert.ErrorIs(t, err, ErrMalformedConfig, c)
+	}
+
+	assert.Panics(t, func() {
+		MustNewConfig(NewNamespace("doc").Relation("viewer", Computed("editor")))
+	})
+}
+
+func TestMapTupleStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewTupleStore()
+	readme := NewObject("doc", "readme")
+	alice := MustParseTuple("doc:readme#viewer@user:alice")
+
+	assert.NoError(t, store.Write(ctx, alice, alice, MustParseTuple("doc:readme#viewer@user:bob")))
+	subjects, err := store.Read(ctx, readme, "viewer")
+	assert.NoError(t, err)
+	assert.Len(t, subjects, 2)
+
+	assert.NoError(t, store.Delete(ctx, alice, MustParseTuple("doc:plan#viewer@user:bob")))
+	subjects, err = store.Read(ctx, readme, "viewer")
+	assert.NoError(t, err)
+	assert.Equal(t, []Subject{User(NewObject("user", "bob"))}, subjects)
+
+	assert.NoError(t, store.Delete(ctx, MustParseTuple("doc:readme#viewer@user:bob")))
+	objects, err := store.Objects(ctx, "doc")
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
@@ -0,0 +1,138 @@
+package rebac
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+type (
+	// TupleStore persists relation tuples
+	TupleStore interface {
+		// Write stores the tuples, existing tuples are ignored
+		Write(context.Context, ...Tuple) error
+		// Delete removes the tuples, missing tuples are ignored
+		Delete(context.Context, ...Tuple) error
+		// Read returns the subjects of the tuples object#relation@...
+		Read(ctx context.Context, object Object, relation string) ([]Subject, error)
+		// Objects returns the objects of the namespace having any tuple
+		Objects(ctx context.Context, namespace string) ([]Object, error)
+	}
+
+	// MapTupleStore is a TupleStore backed by a map
+	MapTupleStore struct {
+		mu     sync.RWMutex
+		tuples map[Object]map[string][]Subject
+	}
+)
+
+var _ TupleStore = (*MapTupleStore)(nil)
+
+func NewTupleStore() *MapTupleStore {
+	return &MapTupleStore{tuples: make(map[Object]map[string][]Subject)}
+}
+
+func (s *MapTupleStore) Write(ctx context.Context, tuples ...Tuple) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range tuples {
+		relations, ok := s.tuples[t.Object]
+		if !ok {
+			relations = make(map[string][]Subject)
+			s.tuples[t.Object] = relations
+		}
+
+		if !containsSubject(relations[t.Relation], t.Subject) {
+			relations[t.Relation] = append(relations[t.Relation], t.Subject)
+		}
+	}
+
+	return nil
+}
+
+func (s *MapTupleStore) Delete(ctx context.Context, tuples ...Tuple) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range tuples {
+		relations := s.tuples[t.Object]
+		subjects := relations[t.Relation]
+		for i, subject := range subjects {
+			if subject == t.Subject {
+				// copy, readers may hold the old slice
+				relations[t.Relation] = append(append([]Subject(nil), subjects[:i]...), subjects[i+1:]...)
+				break
+			}
+		}
+
+		if len(relations[t.Relation]) == 0 {
+			delete(relations, t.Relation)
+		}
+
+		if len(relations) == 0 {
+			delete(s.tuples, t.Object)
+		}
+	}
+
+	return nil
+}
+
+func (s *MapTupleStore) Read(ctx context.Context, object Object, relation string) ([]Subject, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Subject(nil), s.tuples[object][relation]...), nil
+}
+
+func (s *MapTupleStore) Objects(ctx context.Context, namespace string) ([]Object, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var objects []Object
+	for object := range s.tuples {
+		if object.Namespace == namespace {
+			objects = append(objects, object)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ID < objects[j].ID
+	})
+
+	return objects, nil
+}
+
+func containsSubject(subjects []Subject, subject Subject) bool {
+	for _, s := range subjects {
+		if s == subject {
+			return true
+		}
+	}
+
+	return false
+}
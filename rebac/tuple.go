@@ -0,0 +1,125 @@
+package rebac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	// Object is a namespaced object, e.g. doc:readme
+	Object struct {
+		Namespace string
+		ID        string
+	}
+
+	// Subject is either a user, e.g. user:alice, or a userset, e.g. group:eng#member,
+	// which stands for all subjects having the relation with the object
+	Subject struct {
+		Object Object
+		// Relation is empty for users
+		Relation string
+	}
+
+	// Tuple is a relation tuple object#relation@subject, e.g.
+	// doc:readme#viewer@user:alice or doc:readme#viewer@group:eng#member
+	Tuple struct {
+		Object   Object
+		Relation string
+		Subject  Subject
+	}
+)
+
+// ErrMalformedTuple is returned when a tuple, an object or a subject cannot be parsed
+var ErrMalformedTuple = errors.New("malformed tuple")
+
+// NewObject returns the object of the namespace
+func NewObject(namespace string, id string) Object {
+	return Object{Namespace: namespace, ID: id}
+}
+
+// ParseObject parses namespace:id
+func ParseObject(text string) (Object, error) {
+	namespace, id, found := strings.Cut(text, ":")
+	if !found || len(namespace) == 0 || len(id) == 0 || strings.ContainsAny(text, "#@") {
+		return Object{}, fmt.Errorf("%w: object %q, expecting namespace:id", ErrMalformedTuple, text)
+	}
+
+	return Object{Namespace: namespace, ID: id}, nil
+}
+
+func (o Object) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+// User returns the user subject of the object
+func User(object Object) Subject {
+	return Subject{Object: object}
+}
+
+// Userset returns the subjects having the relation with the object
+func Userset(object Object, relation string) Subject {
+	return Subject{Object: object, Relation: relation}
+}
+
+// ParseSubject parses namespace:id or namespace:id#relation
+func ParseSubject(text string) (Subject, error) {
+	objectText, relation, found := strings.Cut(text, "#")
+	if found && len(relation) == 0 {
+		return Subject{}, fmt.Errorf("%w: subject %q has empty relation", ErrMalformedTuple, text)
+	}
+
+	object, err := ParseObject(objectText)
+	if err != nil {
+		return Subject{}, err
+	}
+
+	return Subject{Object: object, Relation: relation}, nil
+}
+
+func (s Subject) String() string {
+	if len(s.Relation) == 0 {
+		return s.Object.String()
+	}
+
+	return s.Object.String() + "#" + s.Relation
+}
+
+// ParseTuple parses object#relation@subject
+func ParseTuple(text string) (Tuple, error) {
+	userset, subjectText, found := strings.Cut(text, "@")
+	if !found {
+		return Tuple{}, fmt.Errorf("%w: %q has no subject", ErrMalformedTuple, text)
+	}
+
+	objectText, relation, found := strings.Cut(userset, "#")
+	if !found || len(relation) == 0 {
+		return Tuple{}, fmt.Errorf("%w: %q has no relation", ErrMalformedTuple, text)
+	}
+
+	object, err := ParseObject(objectText)
+	if err != nil {
+		return Tuple{}, err
+	}
+
+	subject, err := ParseSubject(subjectText)
+	if err != nil {
+		return Tuple{}, err
+	}
+
+	return Tuple{Object: object, Relation: relation, Subject: subject}, nil
+}
+
+// MustParseTuple is like ParseTuple but panics if the tuple is malformed
+func MustParseTuple(text string) Tuple {
+	tuple, err := ParseTuple(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return tuple
+}
+
+func (t Tuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
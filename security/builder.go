@@ -3,6 +3,7 @@ package security
 import (
 	"github.com/shrinex/shield/authc"
 	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/event"
 	"github.com/shrinex/shield/semgt"
 )
 
@@ -10,13 +11,16 @@ import (
 type Builder[S semgt.Session] struct {
 	authenticator authc.Authenticator
 	authorizer    authz.Authorizer
+	secondFactor  authc.SecondFactor
+	lockoutStore  authc.LockoutStore
 	repository    semgt.Repository[S]
 	registry      semgt.Registry[S]
+	publisher     event.Publisher
 }
 
 // NewBuilder returns a newly created Builder
 func NewBuilder[S semgt.Session]() *Builder[S] {
-	return &Builder[S]{}
+	return &Builder[S]{publisher: event.Default}
 }
 
 // Authenticator supplies an authenticator used by Subject
@@ -31,6 +35,19 @@ func (b *Builder[S]) Authorizer(authorizer authz.Authorizer) *Builder[S] {
 	return b
 }
 
+// SecondFactor supplies an optional second authentication step used by Subject
+func (b *Builder[S]) SecondFactor(secondFactor authc.SecondFactor) *Builder[S] {
+	b.secondFactor = secondFactor
+	return b
+}
+
+// LockoutStore supplies a store that counts failed second factor attempts,
+// authc.NewLockoutStore is used by default
+func (b *Builder[S]) LockoutStore(store authc.LockoutStore) *Builder[S] {
+	b.lockoutStore = store
+	return b
+}
+
 // Repository supplies a repository to help Subject manipulates semgt.Session
 func (b *Builder[S]) Repository(repository semgt.Repository[S]) *Builder[S] {
 	b.repository = repository
@@ -43,16 +60,32 @@ func (b *Builder[S]) Registry(registry semgt.Registry[S]) *Builder[S] {
 	return b
 }
 
+// Publisher supplies a Publisher that receives LogoutEvent, SessionReplacedEvent
+// and SessionOverflowEvent, event.Default is used by default
+func (b *Builder[S]) Publisher(publisher event.Publisher) *Builder[S] {
+	b.publisher = publisher
+	return b
+}
+
 // Build creates the Subject
 func (b *Builder[S]) Build() Subject {
 	if b.authenticator == nil || b.authorizer == nil ||
 		b.repository == nil || b.registry == nil {
 		panic("nil")
 	}
+
+	lockoutStore := b.lockoutStore
+	if lockoutStore == nil && b.secondFactor != nil {
+		lockoutStore = authc.NewLockoutStore()
+	}
+
 	return &subject[S]{
 		authenticator: b.authenticator,
 		authorizer:    b.authorizer,
+		secondFactor:  b.secondFactor,
+		lockoutStore:  lockoutStore,
 		repository:    b.repository,
 		registry:      b.registry,
+		publisher:     b.publisher,
 	}
 }
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/semgt"
+)
+
+type (
+	// LogoutEvent is published once a Subject logged out
+	LogoutEvent struct {
+		// UserDetails of the logged-out user
+		UserDetails authc.UserDetails
+		// Session that has been stopped, nil for authc.Stateless users
+		Session semgt.Session
+	}
+
+	// SessionReplacedEvent is published once a Session is kicked out
+	// by a newer login on the same platform
+	SessionReplacedEvent struct {
+		// UserDetails of the user who logged in again
+		UserDetails authc.UserDetails
+		// Session that has been replaced
+		Session semgt.Session
+		// Platform of both sessions
+		Platform string
+	}
+
+	// SessionOverflowEvent is published once a Session is kicked out
+	// because the user exceeds the maximum number of concurrent sessions
+	SessionOverflowEvent struct {
+		// UserDetails of the user who logged in again
+		UserDetails authc.UserDetails
+		// Session that has been kicked out
+		Session semgt.Session
+	}
+)
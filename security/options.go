@@ -28,6 +28,13 @@ type (
 		SamePlatformProhibited bool
 		// NewToken is a factory method that generates session token
 		NewToken func(authc.UserDetails) string
+		// MaxSecondFactorFailures is the number of failed second factor attempts
+		// per principal before the partially authenticated session is invalidated
+		MaxSecondFactorFailures int
+		// SecondFactorLockDuration controls how long failed second factor attempts
+		// are remembered, and how long the second factor is rejected once
+		// MaxSecondFactorFailures is reached
+		SecondFactorLockDuration time.Duration
 	}
 
 	// LoginOption can be used to customize LoginOptions
@@ -63,6 +70,22 @@ func (opt *Options) GetIdleTimeout() time.Duration {
 	return opt.GetTimeout()
 }
 
+func (opt *Options) GetMaxSecondFactorFailures() int {
+	if opt.MaxSecondFactorFailures > 0 {
+		return opt.MaxSecondFactorFailures
+	}
+
+	return 5
+}
+
+func (opt *Options) GetSecondFactorLockDuration() time.Duration {
+	if opt.SecondFactorLockDuration > 0 {
+		return opt.SecondFactorLockDuration
+	}
+
+	return 15 * time.Minute
+}
+
 func SetGlobalOptions(opts Options) {
 	globalOptions.Store(&opts)
 }
@@ -75,10 +98,12 @@ var globalOptions = defaultGlobalOptions()
 
 func defaultGlobalOptions() *atomic.Value {
 	options := Options{
-		Timeout:                12 * time.Hour,
-		IdleTimeout:            time.Hour,
-		Concurrency:            2,
-		SamePlatformProhibited: true,
+		Timeout:                  12 * time.Hour,
+		IdleTimeout:              time.Hour,
+		Concurrency:              2,
+		SamePlatformProhibited:   true,
+		MaxSecondFactorFailures:  5,
+		SecondFactorLockDuration: 15 * time.Minute,
 		NewToken: func(authc.UserDetails) string {
 			return strings.ReplaceAll(uuid.NewString(), "-", "")
 		},
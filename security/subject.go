@@ -2,10 +2,15 @@ package security
 
 import (
 	"context"
+	"errors"
 	"github.com/shrinex/shield/authc"
 	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/event"
 	"github.com/shrinex/shield/semgt"
+	"reflect"
 	"sort"
+	"strings"
+	"time"
 )
 
 type (
@@ -18,7 +23,12 @@ type (
 		// during their current session by providing valid credentials matching
 		// those known to the system, false otherwise
 		Authenticated(context.Context) bool
-		// Session returns the application Session associated with this Subject
+		// PartiallyAuthenticated returns true if this Subject/user has logged in
+		// but not passed the second authentication step yet
+		PartiallyAuthenticated(context.Context) bool
+		// Session returns the application Session associated with this Subject,
+		// authc.ErrUnauthenticated is returned if there is none, e.g. for
+		// authc.Stateless users
 		Session(context.Context) (semgt.Session, error)
 		// UserDetails returns the authenticated user
 		UserDetails(context.Context) (authc.UserDetails, error)
@@ -37,8 +47,53 @@ type (
 		// HasAllAuthority specifies that a user requires all of authorities
 		HasAllAuthority(context.Context, ...authz.Authority) bool
 
-		// Login performs a login attempt for this Subject
+		// CheckRole is like HasRole but tells why it fails, authc.ErrUnauthenticated
+		// or authc.ErrSecondFactorRequired is returned if this Subject is not fully
+		// authenticated, an authz.ForbiddenError if it lacks the role, or the realm
+		// failure otherwise
+		CheckRole(context.Context, authz.Role) error
+		// CheckAnyRole is like HasAnyRole but tells why it fails
+		CheckAnyRole(context.Context, ...authz.Role) error
+		// CheckAllRole is like HasAllRole but tells why it fails
+		CheckAllRole(context.Context, ...authz.Role) error
+
+		// CheckAuthority is like HasAuthority but tells why it fails,
+		// the errors are the same as CheckRole
+		CheckAuthority(context.Context, authz.Authority) error
+		// CheckAnyAuthority is like HasAnyAuthority but tells why it fails
+		CheckAnyAuthority(context.Context, ...authz.Authority) error
+		// CheckAllAuthority is like HasAllAuthority but tells why it fails
+		CheckAllAuthority(context.Context, ...authz.Authority) error
+
+		// HasAccess returns true if this Subject can perform the action on the
+		// resource, the authorizer must be an authz.ResourceAuthorizer
+		HasAccess(ctx context.Context, action string, resource any) bool
+		// CheckAccess is like HasAccess but tells why it fails, the session
+		// attributes are made available to policies as subject attributes
+		CheckAccess(ctx context.Context, action string, resource any) error
+		// CheckExpression evaluates the authz.Expression for this Subject, the
+		// errors are the same as CheckRole, the resource may be nil
+		CheckExpression(ctx context.Context, expr *authz.Expression, action string, resource any) error
+
+		// SwitchDomain returns a copy of the context in which the domain, e.g. a
+		// tenant, is active, authorizers created by authz.NewDomainAuthorizer
+		// evaluate roles and authorities within the active domain
+		SwitchDomain(ctx context.Context, domain string) context.Context
+		// Domain returns the active domain, or empty if none is active
+		Domain(context.Context) string
+
+		// Login performs a login attempt for this Subject, an authc.AccountStatusError
+		// is returned if the account status check failed, e.g. authc.ErrCredentialsExpired
+		// so that the user can be sent to a password-change flow. Without
+		// WithRenewToken the token resumes an existing session, for which no
+		// authc.AuthenticationSuccessEvent is published, or binds authc.Stateless
+		// users without session
 		Login(context.Context, authc.Token, ...LoginOption) (context.Context, error)
+		// VerifySecondFactor performs the second authentication step for
+		// a partially authenticated Subject, other sessions are only evicted
+		// once it succeeds. Failures are counted per principal, an authc.LockedError
+		// is returned and the session is invalidated once the limit is reached
+		VerifySecondFactor(context.Context, authc.Token) (context.Context, error)
 		// Logout logs out this Subject and invalidates and/or removes any
 		// associated entities, such as a Session and authorization data
 		Logout(context.Context) (context.Context, error)
@@ -50,18 +105,22 @@ type (
 	subject[S semgt.Session] struct {
 		authenticator authc.Authenticator
 		authorizer    authz.Authorizer
+		secondFactor  authc.SecondFactor
+		lockoutStore  authc.LockoutStore
 		repository    semgt.Repository[S]
 		registry      semgt.Registry[S]
+		publisher     event.Publisher
 	}
 )
 
 func (s *subject[S]) Authenticated(ctx context.Context) bool {
-	session, err := s.Session(ctx)
-	if err != nil {
-		return false
-	}
+	_, err := s.fullyAuthenticated(ctx)
+	return err == nil
+}
 
-	return session != nil
+func (s *subject[S]) PartiallyAuthenticated(ctx context.Context) bool {
+	_, err := s.fullyAuthenticated(ctx)
+	return errors.Is(err, authc.ErrSecondFactorRequired)
 }
 
 func (s *subject[S]) UserDetails(ctx context.Context) (authc.UserDetails, error) {
@@ -83,14 +142,20 @@ func (s *subject[S]) Session(ctx context.Context) (semgt.Session, error) {
 }
 
 func (s *subject[S]) Login(ctx context.Context, token authc.Token, opts ...LoginOption) (context.Context, error) {
+	opt := apply(opts...)
+
+	// resuming a session is not a login of its own
+	authcCtx := ctx
+	if !opt.RenewToken {
+		authcCtx = authc.WithResumption(ctx)
+	}
+
 	// 先授权
-	userDetails, err := s.authenticator.Authenticate(ctx, token)
+	userDetails, err := s.authenticator.Authenticate(authcCtx, token)
 	if err != nil {
 		return ctx, err
 	}
 
-	opt := apply(opts...)
-
 	if opt.RenewToken {
 		return s.loginWithNewToken(ctx, userDetails, opt)
 	}
@@ -98,6 +163,63 @@ func (s *subject[S]) Login(ctx context.Context, token authc.Token, opts ...Login
 	return s.loginWithOldToken(ctx, token, userDetails)
 }
 
+func (s *subject[S]) VerifySecondFactor(ctx context.Context, token authc.Token) (context.Context, error) {
+	userDetails, err := s.UserDetails(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	session, err := s.Session(ctx)
+	if err != nil {
+		return ctx, err
+	}
+
+	partial, _, err := session.AttributeAsBool(ctx, PartiallyAuthenticatedKey)
+	if err != nil {
+		return ctx, err
+	}
+
+	// already fully authenticated
+	if !partial {
+		return ctx, nil
+	}
+
+	if s.secondFactor == nil {
+		return ctx, ErrSecondFactorUnsupported
+	}
+
+	err = s.checkSecondFactor(ctx, userDetails, token)
+	if err != nil {
+		if errors.Is(err, authc.ErrLocked) {
+			return s.invalidatePartialSession(ctx, session.(S), err)
+		}
+
+		return ctx, err
+	}
+
+	err = session.RemoveAttribute(ctx, PartiallyAuthenticatedKey)
+	if err != nil {
+		return ctx, err
+	}
+
+	err = s.repository.Save(ctx, session.(S))
+	if err != nil {
+		return ctx, err
+	}
+
+	platform, _, err := session.AttributeAsString(ctx, PlatformKey)
+	if err != nil {
+		return ctx, err
+	}
+
+	err = s.activateSession(ctx, userDetails, session.(S), apply(WithPlatform(platform)))
+	if err != nil {
+		return ctx, err
+	}
+
+	return ctx, nil
+}
+
 func (s *subject[S]) Logout(ctx context.Context) (context.Context, error) {
 	userDetails, err := s.UserDetails(ctx)
 	if err != nil {
@@ -108,7 +230,12 @@ func (s *subject[S]) Logout(ctx context.Context) (context.Context, error) {
 
 	session, err := s.Session(ctx)
 	if err != nil {
-		return ctx, err
+		if !stateless(userDetails) {
+			return ctx, err
+		}
+
+		s.publish(ctx, &LogoutEvent{UserDetails: userDetails})
+		return context.WithValue(ctx, userDetailsCtxKey{}, nil), nil
 	}
 
 	err = s.registry.Deregister(ctx, userDetails.Principal(), session.(S))
@@ -125,12 +252,15 @@ func (s *subject[S]) Logout(ctx context.Context) (context.Context, error) {
 	if err != nil {
 		return ctx, err
 	}
+
+	s.publish(ctx, &LogoutEvent{UserDetails: userDetails, Session: session})
+
 	ctx = context.WithValue(ctx, sessionCtxKey{}, nil)
 	return context.WithValue(ctx, userDetailsCtxKey{}, nil), nil
 }
 
 func (s *subject[S]) HasRole(ctx context.Context, role authz.Role) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -139,7 +269,7 @@ func (s *subject[S]) HasRole(ctx context.Context, role authz.Role) bool {
 }
 
 func (s *subject[S]) HasAnyRole(ctx context.Context, roles ...authz.Role) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -148,7 +278,7 @@ func (s *subject[S]) HasAnyRole(ctx context.Context, roles ...authz.Role) bool {
 }
 
 func (s *subject[S]) HasAllRole(ctx context.Context, roles ...authz.Role) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -157,7 +287,7 @@ func (s *subject[S]) HasAllRole(ctx context.Context, roles ...authz.Role) bool {
 }
 
 func (s *subject[S]) HasAuthority(ctx context.Context, authority authz.Authority) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -166,7 +296,7 @@ func (s *subject[S]) HasAuthority(ctx context.Context, authority authz.Authority
 }
 
 func (s *subject[S]) HasAnyAuthority(ctx context.Context, authorities ...authz.Authority) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -175,7 +305,7 @@ func (s *subject[S]) HasAnyAuthority(ctx context.Context, authorities ...authz.A
 }
 
 func (s *subject[S]) HasAllAuthority(ctx context.Context, authorities ...authz.Authority) bool {
-	userDetails, err := s.UserDetails(ctx)
+	userDetails, err := s.fullyAuthenticated(ctx)
 	if err != nil {
 		return false
 	}
@@ -183,30 +313,145 @@ func (s *subject[S]) HasAllAuthority(ctx context.Context, authorities ...authz.A
 	return s.authorizer.HasAllAuthority(ctx, userDetails, authorities...)
 }
 
+func (s *subject[S]) CheckRole(ctx context.Context, role authz.Role) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckRole(ctx, userDetails, role)
+}
+
+func (s *subject[S]) CheckAnyRole(ctx context.Context, roles ...authz.Role) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckAnyRole(ctx, userDetails, roles...)
+}
+
+func (s *subject[S]) CheckAllRole(ctx context.Context, roles ...authz.Role) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckAllRole(ctx, userDetails, roles...)
+}
+
+func (s *subject[S]) CheckAuthority(ctx context.Context, authority authz.Authority) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckAuthority(ctx, userDetails, authority)
+}
+
+func (s *subject[S]) CheckAnyAuthority(ctx context.Context, authorities ...authz.Authority) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckAnyAuthority(ctx, userDetails, authorities...)
+}
+
+func (s *subject[S]) CheckAllAuthority(ctx context.Context, authorities ...authz.Authority) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.authorizer.CheckAllAuthority(ctx, userDetails, authorities...)
+}
+
+func (s *subject[S]) HasAccess(ctx context.Context, action string, resource any) bool {
+	return s.CheckAccess(ctx, action, resource) == nil
+}
+
+func (s *subject[S]) CheckAccess(ctx context.Context, action string, resource any) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	authorizer, ok := s.authorizer.(authz.ResourceAuthorizer)
+	if !ok {
+		return ErrResourceUnsupported
+	}
+
+	attributes, err := s.sessionAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx = authz.WithSubjectAttributes(ctx, attributes)
+	return authorizer.CheckAccess(ctx, userDetails, action, resource)
+}
+
+func (s *subject[S]) CheckExpression(ctx context.Context, expr *authz.Expression, action string, resource any) error {
+	userDetails, err := s.fullyAuthenticated(ctx)
+	if err != nil {
+		return err
+	}
+
+	attributes, err := s.sessionAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx = authz.WithSubjectAttributes(ctx, attributes)
+	return expr.Check(ctx, s.authorizer, userDetails, action, resource)
+}
+
+func (s *subject[S]) SwitchDomain(ctx context.Context, domain string) context.Context {
+	return authz.WithDomain(ctx, domain)
+}
+
+func (s *subject[S]) Domain(ctx context.Context) string {
+	return authz.DomainFrom(ctx)
+}
+
 ///=====================================
 ///		    Private
 ///=====================================
 
 func (s *subject[S]) loginWithNewToken(ctx context.Context, userDetails authc.UserDetails, opt *LoginOptions) (context.Context, error) {
-	err := s.applyGlobalOptions(ctx, userDetails, opt)
+	session, err := s.createAndSaveSession(ctx, userDetails, opt)
 	if err != nil {
 		return ctx, err
 	}
 
-	session, err := s.createAndSaveSession(ctx, userDetails, opt)
+	partial, _, err := session.AttributeAsBool(ctx, PartiallyAuthenticatedKey)
 	if err != nil {
 		return ctx, err
 	}
 
-	err = s.registerSession(ctx, userDetails, session)
-	if err != nil {
-		return ctx, err
+	// a partially authenticated session must not evict other sessions,
+	// it is activated once the second factor has been verified
+	if !partial {
+		err = s.activateSession(ctx, userDetails, session, opt)
+		if err != nil {
+			return ctx, err
+		}
 	}
 
 	ctx = context.WithValue(ctx, sessionCtxKey{}, session)
 	return context.WithValue(ctx, userDetailsCtxKey{}, userDetails), nil
 }
 
+// activateSession evicts sessions as configured and registers the new one
+func (s *subject[S]) activateSession(ctx context.Context, userDetails authc.UserDetails, session S, opt *LoginOptions) error {
+	err := s.applyGlobalOptions(ctx, userDetails, opt)
+	if err != nil {
+		return err
+	}
+
+	return s.registerSession(ctx, userDetails, session)
+}
+
 func (s *subject[S]) applyGlobalOptions(ctx context.Context, userDetails authc.UserDetails, opt *LoginOptions) error {
 	sessions, err := s.registry.ActiveSessions(ctx, userDetails.Principal())
 	if err != nil {
@@ -260,6 +505,8 @@ func (s *subject[S]) applyExclusiveOption(ctx context.Context, userDetails authc
 			if err != nil {
 				return nil, err
 			}
+
+			s.publish(ctx, &SessionReplacedEvent{UserDetails: userDetails, Session: ss, Platform: platform})
 		}
 
 		return sessions[:j], nil
@@ -289,6 +536,8 @@ func (s *subject[S]) applyConcurrencyOption(ctx context.Context, userDetails aut
 			if err != nil {
 				return err
 			}
+
+			s.publish(ctx, &SessionOverflowEvent{UserDetails: userDetails, Session: ss})
 		}
 	}
 
@@ -314,6 +563,12 @@ func (s *subject[S]) createAndSaveSession(ctx context.Context, userDetails authc
 		return
 	}
 
+	// 二次认证
+	err = s.requireSecondFactor(ctx, userDetails, session)
+	if err != nil {
+		return
+	}
+
 	// 保存会话
 	err = s.repository.Save(ctx, session)
 	if err != nil {
@@ -323,6 +578,82 @@ func (s *subject[S]) createAndSaveSession(ctx context.Context, userDetails authc
 	return
 }
 
+func (s *subject[S]) requireSecondFactor(ctx context.Context, userDetails authc.UserDetails, session S) error {
+	if s.secondFactor == nil {
+		return nil
+	}
+
+	required, err := s.secondFactor.Required(ctx, userDetails)
+	if err != nil {
+		return err
+	}
+
+	if !required {
+		return nil
+	}
+
+	return session.SetAttribute(ctx, PartiallyAuthenticatedKey, true)
+}
+
+// checkSecondFactor verifies the token, failures are counted per principal
+// so that logging in again does not grant more attempts
+func (s *subject[S]) checkSecondFactor(ctx context.Context, userDetails authc.UserDetails, token authc.Token) error {
+	if s.lockoutStore == nil {
+		return s.secondFactor.Verify(ctx, userDetails, token)
+	}
+
+	key := "secondFactor:" + userDetails.Principal()
+	attempts, err := s.lockoutStore.Load(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if time.Now().Before(attempts.LockedUntil) {
+		return &authc.LockedError{RetryAfter: attempts.LockedUntil}
+	}
+
+	err = s.secondFactor.Verify(ctx, userDetails, token)
+	if err == nil {
+		_ = s.lockoutStore.Reset(ctx, key)
+		return nil
+	}
+
+	if !errors.Is(err, authc.ErrUnauthenticated) {
+		return err
+	}
+
+	opts := GetGlobalOptions()
+	failures, ierr := s.lockoutStore.Increment(ctx, key, opts.GetSecondFactorLockDuration())
+	if ierr != nil {
+		return ierr
+	}
+
+	if failures < opts.GetMaxSecondFactorFailures() {
+		return err
+	}
+
+	lockedUntil := time.Now().Add(opts.GetSecondFactorLockDuration())
+	ierr = s.lockoutStore.LockUntil(ctx, key, lockedUntil)
+	if ierr != nil {
+		return ierr
+	}
+
+	return &authc.LockedError{RetryAfter: lockedUntil}
+}
+
+// invalidatePartialSession removes the session so that the user must log in again
+func (s *subject[S]) invalidatePartialSession(ctx context.Context, session S, cause error) (context.Context, error) {
+	err := s.repository.Remove(ctx, session.Token())
+	if err != nil {
+		return ctx, err
+	}
+
+	_ = session.Stop(ctx)
+
+	ctx = context.WithValue(ctx, sessionCtxKey{}, nil)
+	return context.WithValue(ctx, userDetailsCtxKey{}, nil), cause
+}
+
 func (s *subject[S]) registerSession(ctx context.Context, userDetails authc.UserDetails, session S) error {
 	// 注册会话
 	err := s.registry.Register(ctx, userDetails.Principal(), session)
@@ -334,11 +665,21 @@ func (s *subject[S]) registerSession(ctx context.Context, userDetails authc.User
 }
 
 func (s *subject[S]) loginWithOldToken(ctx context.Context, token authc.Token, userDetails authc.UserDetails) (context.Context, error) {
+	// the token proves the identity by itself, there is no session to resume
+	if stateless(userDetails) {
+		return context.WithValue(ctx, userDetailsCtxKey{}, userDetails), nil
+	}
+
 	session, err := s.repository.Read(ctx, token.Principal())
 	if err != nil {
 		return ctx, err
 	}
 
+	// the session has expired or never existed
+	if isNil(session) {
+		return ctx, authc.ErrUnauthenticated
+	}
+
 	_ = session.Touch(ctx)
 	_ = s.registry.KeepAlive(ctx, userDetails.Principal())
 
@@ -346,6 +687,66 @@ func (s *subject[S]) loginWithOldToken(ctx context.Context, token authc.Token, u
 	return context.WithValue(ctx, userDetailsCtxKey{}, userDetails), nil
 }
 
+func (s *subject[S]) fullyAuthenticated(ctx context.Context) (authc.UserDetails, error) {
+	userDetails, err := s.UserDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.Session(ctx)
+	if err != nil {
+		if stateless(userDetails) {
+			return userDetails, nil
+		}
+
+		return nil, err
+	}
+
+	partial, _, err := session.AttributeAsBool(ctx, PartiallyAuthenticatedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if partial {
+		return nil, authc.ErrSecondFactorRequired
+	}
+
+	return userDetails, nil
+}
+
+// sessionAttributes returns the session attributes except the internal ones
+func (s *subject[S]) sessionAttributes(ctx context.Context) (authz.Attributes, error) {
+	session, err := s.Session(ctx)
+	if err != nil {
+		if userDetails, uerr := s.UserDetails(ctx); uerr == nil && stateless(userDetails) {
+			return authz.Attributes{}, nil
+		}
+
+		return nil, err
+	}
+
+	keys, err := session.AttributeKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := authz.Attributes{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, "__") {
+			continue
+		}
+
+		var value any
+		if _, err = session.Attribute(ctx, key, &value); err != nil {
+			return nil, err
+		}
+
+		attributes[key] = value
+	}
+
+	return attributes, nil
+}
+
 func (s *subject[S]) logoutIfPossible(ctx context.Context, userDetails authc.UserDetails) {
 	if la, ok := s.authenticator.(authc.LogoutAware); ok {
 		la.Logout(ctx, userDetails)
@@ -364,6 +765,32 @@ func (s *subject[S]) logoutIfPossible(ctx context.Context, userDetails authc.Use
 	}
 }
 
+func (s *subject[S]) publish(ctx context.Context, e event.Event) {
+	if s.publisher != nil {
+		s.publisher.Publish(ctx, e)
+	}
+}
+
+// stateless reports whether the user is authenticated without session
+func stateless(userDetails authc.UserDetails) bool {
+	s, ok := authc.As[authc.Stateless](userDetails)
+	return ok && s.Stateless()
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return rv.IsNil()
+	default:
+		return false
+	}
+}
+
 func apply(opts ...LoginOption) *LoginOptions {
 	opt := defaultLoginOptions
 
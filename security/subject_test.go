@@ -3,7 +3,9 @@ package security
 import (
 	"context"
 	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
 	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/event"
 	"github.com/shrinex/shield/semgt"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -221,3 +223,430 @@ func TestOverflow(t *testing.T) {
 	assert.False(t, ok)
 	assert.False(t, overflow)
 }
+
+type mockSecondFactor struct {
+}
+
+func (f *mockSecondFactor) Required(_ context.Context, userDetails authc.UserDetails) (bool, error) {
+	return userDetails.Principal() == "archer", nil
+}
+
+func (f *mockSecondFactor) Verify(_ context.Context, _ authc.UserDetails, token authc.Token) error {
+	if token.Credentials() != "123456" {
+		return authc.ErrUnauthenticated
+	}
+
+	return nil
+}
+
+type mockAuthzRealm struct {
+}
+
+func (r *mockAuthzRealm) LoadRoles(context.Context, authc.UserDetails) ([]authz.Role, error) {
+	return []authz.Role{authz.NewRole("admin")}, nil
+}
+
+func (r *mockAuthzRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return []authz.Authority{authz.NewAuthority("read")}, nil
+}
+
+func TestSecondFactor(t *testing.T) {
+	GetGlobalOptions().SamePlatformProhibited = false
+	GetGlobalOptions().Concurrency = 2
+
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		SecondFactor(&mockSecondFactor{}).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	ctx, err := sb.Login(context.Background(), authc.NewUsernamePasswordToken("archer", "123"), WithRenewToken())
+	assert.NoError(t, err)
+	assert.False(t, sb.Authenticated(ctx))
+	assert.True(t, sb.PartiallyAuthenticated(ctx))
+	assert.False(t, sb.HasRole(ctx, authz.NewRole("admin")))
+	assert.False(t, sb.HasAuthority(ctx, authz.NewAuthority("read")))
+	assert.ErrorIs(t, sb.CheckRole(ctx, authz.NewRole("admin")), authc.ErrSecondFactorRequired)
+
+	session, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	ctx, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "000000"))
+	assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+	assert.True(t, sb.PartiallyAuthenticated(ctx))
+
+	ctx, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "123456"))
+	assert.NoError(t, err)
+	assert.True(t, sb.Authenticated(ctx))
+	assert.False(t, sb.PartiallyAuthenticated(ctx))
+	assert.True(t, sb.HasRole(ctx, authz.NewRole("admin")))
+	assert.True(t, sb.HasAuthority(ctx, authz.NewAuthority("read")))
+
+	// subsequent requests restore the fully authenticated session
+	ctx, err = sb.Login(context.Background(), authc.NewBearerToken(session.Token()))
+	assert.NoError(t, err)
+	assert.True(t, sb.Authenticated(ctx))
+
+	// users without second factor are fully authenticated at once
+	ctx, err = sb.Login(context.Background(), authc.NewUsernamePasswordToken("guest", "123"), WithRenewToken())
+	assert.NoError(t, err)
+	assert.True(t, sb.Authenticated(ctx))
+	assert.False(t, sb.PartiallyAuthenticated(ctx))
+}
+
+func TestSecondFactorDefersEviction(t *testing.T) {
+	GetGlobalOptions().SamePlatformProhibited = true
+	GetGlobalOptions().Concurrency = 2
+
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	registry := semgt.NewRegistry(repository)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		SecondFactor(&mockSecondFactor{}).
+		Repository(repository).
+		Registry(registry).
+		Build()
+
+	token := authc.NewUsernamePasswordToken("archer", "123")
+	ctx, err := sb.Login(context.Background(), token, WithPlatform("mobile"), WithRenewToken())
+	assert.NoError(t, err)
+	_, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "123456"))
+	assert.NoError(t, err)
+	first, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	// the password alone does not kick out the first session
+	ctx, err = sb.Login(context.Background(), token, WithPlatform("mobile"), WithRenewToken())
+	assert.NoError(t, err)
+	assert.True(t, sb.PartiallyAuthenticated(ctx))
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sessions))
+	assert.Equal(t, first.Token(), sessions[0].Token())
+
+	_, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "123456"))
+	assert.NoError(t, err)
+	second, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sessions))
+	assert.Equal(t, second.Token(), sessions[0].Token())
+
+	ss, err := repository.Read(ctx, first.Token())
+	assert.NoError(t, err)
+	assert.Nil(t, ss)
+}
+
+func TestSecondFactorLockout(t *testing.T) {
+	GetGlobalOptions().SamePlatformProhibited = false
+	GetGlobalOptions().Concurrency = 2
+	GetGlobalOptions().MaxSecondFactorFailures = 3
+	defer func() { GetGlobalOptions().MaxSecondFactorFailures = 5 }()
+
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		SecondFactor(&mockSecondFactor{}).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	token := authc.NewUsernamePasswordToken("archer", "123")
+	ctx, err := sb.Login(context.Background(), token, WithRenewToken())
+	assert.NoError(t, err)
+	session, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		ctx, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "000000"))
+		assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+		assert.True(t, sb.PartiallyAuthenticated(ctx))
+	}
+
+	// logging in again does not grant more attempts
+	ctx, err = sb.Login(context.Background(), token, WithRenewToken())
+	assert.NoError(t, err)
+	session, err = sb.Session(ctx)
+	assert.NoError(t, err)
+
+	ctx, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "000000"))
+	assert.ErrorIs(t, err, authc.ErrLocked)
+	assert.False(t, sb.PartiallyAuthenticated(ctx))
+
+	ss, err := repository.Read(context.Background(), session.Token())
+	assert.NoError(t, err)
+	assert.Nil(t, ss)
+
+	// the correct code is rejected as well until the lock expires
+	ctx, err = sb.Login(context.Background(), token, WithRenewToken())
+	assert.NoError(t, err)
+	_, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "123456"))
+	assert.ErrorIs(t, err, authc.ErrLocked)
+}
+
+func TestSecondFactorUnsupported(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	session, err := repository.Create(context.Background(), "token")
+	assert.NoError(t, err)
+	assert.NoError(t, session.SetAttribute(context.Background(), PartiallyAuthenticatedKey, true))
+
+	sb := &subject[*semgt.MapSession]{
+		authenticator: authc.NewAuthenticator(&mockRealm{}),
+		repository:    repository,
+		registry:      semgt.NewRegistry(repository),
+	}
+
+	ctx := context.WithValue(context.Background(), sessionCtxKey{}, session)
+	ctx = context.WithValue(ctx, userDetailsCtxKey{}, authc.NewBearerToken("archer"))
+	_, err = sb.VerifySecondFactor(ctx, authc.NewOneTimePasswordToken("archer", "123456"))
+	assert.ErrorIs(t, err, ErrSecondFactorUnsupported)
+}
+
+type expiredUser string
+
+func (u expiredUser) Principal() string {
+	return string(u)
+}
+
+func (u expiredUser) Enabled() bool {
+	return true
+}
+
+func (u expiredUser) Locked() bool {
+	return false
+}
+
+func (u expiredUser) AccountExpired() bool {
+	return false
+}
+
+func (u expiredUser) CredentialsExpired() bool {
+	return true
+}
+
+type expiredRealm struct {
+}
+
+func (r *expiredRealm) Supports(authc.Token) bool {
+	return true
+}
+
+func (r *expiredRealm) LoadUserDetails(_ context.Context, token authc.Token) (authc.UserDetails, error) {
+	return expiredUser(token.Principal()), nil
+}
+
+func TestLoginWithCredentialsExpired(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := &subject[*semgt.MapSession]{
+		authenticator: authc.NewAuthenticator(&expiredRealm{}),
+		repository:    repository,
+		registry:      semgt.NewRegistry(repository),
+	}
+
+	ctx, err := sb.Login(context.Background(), authc.NewUsernamePasswordToken("archer", "123"), WithRenewToken())
+	assert.ErrorIs(t, err, authc.ErrCredentialsExpired)
+	assert.False(t, sb.Authenticated(ctx))
+
+	var statusErr *authc.AccountStatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, "archer", statusErr.UserDetails.Principal())
+}
+
+func TestSessionEvents(t *testing.T) {
+	GetGlobalOptions().SamePlatformProhibited = true
+	GetGlobalOptions().Concurrency = 1
+
+	var received []event.Event
+	publisher := event.NewMulticaster()
+	publisher.AddListener(event.ListenerFunc(func(_ context.Context, e event.Event) {
+		received = append(received, e)
+	}))
+
+	token := authc.NewUsernamePasswordToken("archer", "123")
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	authenticator := authc.NewBuilder().Realms(&mockRealm{}).Publisher(publisher).Build()
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authenticator).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Publisher(publisher).
+		Build()
+
+	ctx, err := sb.Login(context.Background(), token, WithPlatform("mobile"), WithRenewToken())
+	assert.NoError(t, err)
+	firstSession, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	ctx, err = sb.Login(ctx, token, WithPlatform("mobile"), WithRenewToken())
+	assert.NoError(t, err)
+	secondSession, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	GetGlobalOptions().SamePlatformProhibited = false
+	ctx, err = sb.Login(ctx, token, WithPlatform("web"), WithRenewToken())
+	assert.NoError(t, err)
+	thirdSession, err := sb.Session(ctx)
+	assert.NoError(t, err)
+
+	// resuming the session does not publish AuthenticationSuccessEvent
+	_, err = sb.Login(context.Background(), authc.NewBearerToken(thirdSession.Token()))
+	assert.NoError(t, err)
+
+	_, err = sb.Logout(ctx)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 6, len(received))
+	assert.IsType(t, &authc.AuthenticationSuccessEvent{}, received[0])
+	assert.IsType(t, &authc.AuthenticationSuccessEvent{}, received[1])
+	assert.IsType(t, &authc.AuthenticationSuccessEvent{}, received[3])
+
+	replaced, ok := received[2].(*SessionReplacedEvent)
+	assert.True(t, ok)
+	assert.Equal(t, firstSession.Token(), replaced.Session.Token())
+	assert.Equal(t, "mobile", replaced.Platform)
+
+	overflow, ok := received[4].(*SessionOverflowEvent)
+	assert.True(t, ok)
+	assert.Equal(t, secondSession.Token(), overflow.Session.Token())
+
+	logout, ok := received[5].(*LogoutEvent)
+	assert.True(t, ok)
+	assert.Equal(t, thirdSession.Token(), logout.Session.Token())
+	assert.Equal(t, token, logout.UserDetails)
+}
+
+func TestLoginWithUnknownToken(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := &subject[*semgt.MapSession]{
+		authenticator: authc.NewAuthenticator(&mockRealm{}),
+		repository:    repository,
+		registry:      semgt.NewRegistry(repository),
+	}
+
+	ctx, err := sb.Login(context.Background(), authc.NewBearerToken("unknown"))
+	assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+	assert.False(t, sb.Authenticated(ctx))
+}
+
+func TestCheck(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	ctx := context.Background()
+	assert.ErrorIs(t, sb.CheckRole(ctx, authz.NewRole("admin")), authc.ErrUnauthenticated)
+	assert.ErrorIs(t, sb.CheckAllAuthority(ctx, authz.NewAuthority("read")), authc.ErrUnauthenticated)
+
+	ctx, err := sb.Login(ctx, authc.NewUsernamePasswordToken("archer", "123"), WithRenewToken())
+	assert.NoError(t, err)
+
+	assert.NoError(t, sb.CheckRole(ctx, authz.NewRole("admin")))
+	assert.NoError(t, sb.CheckAnyRole(ctx, authz.NewRole("admin"), authz.NewRole("user")))
+	assert.ErrorIs(t, sb.CheckAllRole(ctx, authz.NewRole("admin"), authz.NewRole("user")), authz.ErrForbidden)
+	assert.NoError(t, sb.CheckAuthority(ctx, authz.NewAuthority("read")))
+	assert.ErrorIs(t, sb.CheckAnyAuthority(ctx, authz.NewAuthority("write")), authz.ErrForbidden)
+	assert.ErrorIs(t, sb.CheckAllAuthority(ctx, authz.NewAuthority("read"), authz.NewAuthority("write")), authz.ErrForbidden)
+}
+
+func TestCheckAccess(t *testing.T) {
+	policies, err := authz.ParsePolicies([]byte(`{"policies": [{
+		"name": "sales-only",
+		"effect": "permit",
+		"actions": ["read"],
+		"condition": {"all": [
+			{"eq": ["subject.principal", "archer"]},
+			{"eq": ["subject.department", "sales"]}
+		]}
+	}]}`))
+	assert.NoError(t, err)
+
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewPolicyAuthorizer(authz.NewAuthorizer(&mockAuthzRealm{}), authz.NewPolicyEngine(policies...))).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	ctx := context.Background()
+	assert.ErrorIs(t, sb.CheckAccess(ctx, "read", nil), authc.ErrUnauthenticated)
+
+	ctx, err = sb.Login(ctx, authc.NewUsernamePasswordToken("archer", "123"), WithRenewToken())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, sb.CheckAccess(ctx, "read", nil), authz.ErrForbidden)
+
+	session, err := sb.Session(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, session.SetAttribute(ctx, "department", "sales"))
+
+	assert.True(t, sb.HasAccess(ctx, "read", nil))
+	assert.False(t, sb.HasAccess(ctx, "write", nil))
+
+	expr := authz.MustCompileExpression("hasRole('admin') and subject.department == 'sales' and hasAccess(action)")
+	assert.NoError(t, sb.CheckExpression(ctx, expr, "read", nil))
+	assert.ErrorIs(t, sb.CheckExpression(ctx, expr, "write", nil), authz.ErrForbidden)
+
+	sb = NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	assert.ErrorIs(t, sb.CheckAccess(ctx, "read", nil), ErrResourceUnsupported)
+}
+
+type statelessUser string
+
+func (u statelessUser) Principal() string {
+	return string(u)
+}
+
+func (u statelessUser) Stateless() bool {
+	return true
+}
+
+type statelessRealm struct {
+}
+
+func (r *statelessRealm) Supports(authc.Token) bool {
+	return true
+}
+
+func (r *statelessRealm) LoadUserDetails(_ context.Context, token authc.Token) (authc.UserDetails, error) {
+	return statelessUser(token.Principal()), nil
+}
+
+func TestStatelessLogin(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	sb := NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&statelessRealm{})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	ctx, err := sb.Login(context.Background(), authc.NewBearerToken("archer"))
+	assert.NoError(t, err)
+	assert.True(t, sb.Authenticated(ctx))
+	assert.True(t, sb.HasRole(ctx, authz.NewRole("admin")))
+
+	_, err = sb.Session(ctx)
+	assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+
+	ctx, err = sb.Logout(ctx)
+	assert.NoError(t, err)
+	assert.False(t, sb.Authenticated(ctx))
+}
@@ -1,14 +1,33 @@
 package security
 
+import (
+	"errors"
+	"github.com/shrinex/shield/semgt"
+)
+
 const (
 	// PlatformKey is a session attribute key that
 	// point to logged-in platform
-	PlatformKey = "__platformKey"
+	PlatformKey = semgt.PlatformKey
 
 	// UserDetailsKey is a session attribute key that
 	// point to logged-in user
 	UserDetailsKey = "__userDetailsKey"
 
+	// PartiallyAuthenticatedKey is a session attribute key that indicates
+	// the logged-in user has not passed the second factor yet
+	PartiallyAuthenticatedKey = "__partiallyAuthenticatedKey"
+
 	// DefaultPlatform is the default platform
-	DefaultPlatform = "universal"
+	DefaultPlatform = semgt.DefaultPlatform
+)
+
+var (
+	// ErrResourceUnsupported is returned by Subject.CheckAccess
+	// if the authorizer is not an authz.ResourceAuthorizer
+	ErrResourceUnsupported = errors.New("authorizer does not support resources")
+
+	// ErrSecondFactorUnsupported is returned by Subject.VerifySecondFactor
+	// if no authc.SecondFactor is configured
+	ErrSecondFactorUnsupported = errors.New("second factor is not configured")
 )
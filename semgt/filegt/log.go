@@ -0,0 +1,234 @@
+package filegt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	// record is an entry of the log, replaying a record
+	// twice leaves the same state as replaying it once
+	record struct {
+		Op         op            `json:"op"`
+		Token      string        `json:"token"`
+		Session    *sessionState `json:"session,omitempty"`
+		Name       string        `json:"name,omitempty"`
+		Data       string        `json:"data,omitempty"`
+		AccessTime int64         `json:"accessTime,omitempty"`
+		Principal  string        `json:"principal,omitempty"`
+		Platform   string        `json:"platform,omitempty"`
+	}
+
+	op string
+
+	sessionState struct {
+		Token          string            `json:"token"`
+		StartTime      int64             `json:"startTime"`
+		LastAccessTime int64             `json:"lastAccessTime"`
+		Timeout        time.Duration     `json:"timeout"`
+		IdleTimeout    time.Duration     `json:"idleTimeout"`
+		Attributes     map[string]string `json:"attributes"`
+	}
+
+	registrationState struct {
+		Token     string `json:"token"`
+		Principal string `json:"principal"`
+		Platform  string `json:"platform"`
+	}
+
+	// snapshot is the whole state written by compaction
+	snapshot struct {
+		Sessions      []*sessionState      `json:"sessions"`
+		Registrations []*registrationState `json:"registrations"`
+	}
+)
+
+const (
+	opSave       op = "save"
+	opSet        op = "set"
+	opDelete     op = "del"
+	opTouch      op = "touch"
+	opRemove     op = "remove"
+	opRegister   op = "register"
+	opDeregister op = "deregister"
+
+	logName      = "sessions.log"
+	snapshotName = "sessions.snap"
+
+	// frameHeader is the length and the CRC-32 of the payload
+	frameHeader = 8
+)
+
+var (
+	// ErrCorrupted is returned when the snapshot or a log record cannot be recovered
+	ErrCorrupted = errors.New("corrupted session store")
+
+	errTornFrame = errors.New("torn frame")
+)
+
+// writeFrame writes the payload prefixed by its length and CRC-32,
+// so that a frame torn by a crash is detected on recovery
+func writeFrame(w io.Writer, payload string) (int64, error) {
+	buf := make([]byte, frameHeader+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE([]byte(payload)))
+	copy(buf[frameHeader:], payload)
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// readFrame returns io.EOF at the end, or errTornFrame if the frame is
+// incomplete, longer than the limit or does not match its CRC-32
+func readFrame(r *bufio.Reader, limit int64) (string, error) {
+	var header [frameHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", errTornFrame
+		}
+
+		return "", err
+	}
+
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	if size > limit-frameHeader {
+		return "", errTornFrame
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return "", errTornFrame
+		}
+
+		return "", err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return "", errTornFrame
+	}
+
+	return string(payload), nil
+}
+
+// writeSnapshot atomically replaces the snapshot file
+func (r *Repository) writeSnapshot(snap *snapshot) error {
+	payload, err := r.codec.Encode(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(r.dir, snapshotName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = writeFrame(f, payload); err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, filepath.Join(r.dir, snapshotName)); err != nil {
+		return err
+	}
+
+	syncDir(r.dir)
+	return nil
+}
+
+// readSnapshot returns nil if there is no snapshot yet
+func (r *Repository) readSnapshot() (*snapshot, error) {
+	f, err := os.Open(filepath.Join(r.dir, snapshotName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := readFrame(bufio.NewReader(f), info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	var snap snapshot
+	if err = r.codec.Decode(payload, &snap); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+
+	return &snap, nil
+}
+
+// replayLog applies the records of the log, a torn tail left by a crash is
+// truncated, a record that cannot be decoded is ErrCorrupted, it returns the
+// size of the log after recovery
+func (r *Repository) replayLog(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, err := readFrame(reader, info.Size()-offset)
+		if err == io.EOF {
+			return offset, nil
+		}
+
+		if err == errTornFrame {
+			break
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		// the frame is intact, so the record was written corrupted, truncating
+		// the log would silently drop the valid records that follow it
+		var rec record
+		if err = r.codec.Decode(payload, &rec); err != nil {
+			return 0, fmt.Errorf("%w: log record at offset %d: %v", ErrCorrupted, offset, err)
+		}
+
+		r.apply(&rec)
+		offset += int64(frameHeader + len(payload))
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+
+	return offset, f.Sync()
+}
+
+// syncDir makes a rename durable, it is not supported on every platform
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
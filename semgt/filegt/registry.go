@@ -0,0 +1,116 @@
+package filegt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+)
+
+type (
+	// Registry is a semgt.Registry whose registrations are
+	// persisted in the log of the Repository
+	Registry struct {
+		repo *Repository
+	}
+)
+
+var _ semgt.Registry[*Session] = (*Registry)(nil)
+
+func NewRegistry(repo *Repository) *Registry {
+	return &Registry{repo: repo}
+}
+
+// Register registers the stored Session, a Session
+// which has not been saved is not registered
+func (r *Registry) Register(ctx context.Context, principal string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	platform, found, err := session.AttributeAsString(ctx, semgt.PlatformKey)
+	if err != nil {
+		return err
+	}
+
+	if !found || len(platform) == 0 {
+		platform = semgt.DefaultPlatform
+	}
+
+	r.repo.mu.Lock()
+	defer r.repo.mu.Unlock()
+
+	// already registered
+	if _, ok := r.repo.registrations[session.Token()]; ok {
+		return nil
+	}
+
+	if r.repo.sessions[session.Token()] != session {
+		return nil
+	}
+
+	rec := &record{Op: opRegister, Token: session.Token(), Principal: principal, Platform: platform}
+	if err = r.repo.appendLocked(rec); err != nil {
+		return err
+	}
+
+	r.repo.apply(rec)
+	return nil
+}
+
+func (r *Registry) Deregister(ctx context.Context, _ string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.repo.mu.Lock()
+	defer r.repo.mu.Unlock()
+
+	if _, ok := r.repo.registrations[session.Token()]; !ok {
+		return nil
+	}
+
+	rec := &record{Op: opDeregister, Token: session.Token()}
+	if err := r.repo.appendLocked(rec); err != nil {
+		return err
+	}
+
+	r.repo.apply(rec)
+	return nil
+}
+
+// ActiveSessions returns the sessions ordered by registration
+func (r *Registry) ActiveSessions(ctx context.Context, principal string) ([]*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.repo.mu.Lock()
+	tokens := r.repo.registeredLocked(func(reg *registration) bool {
+		return reg.principal == principal
+	})
+	r.repo.mu.Unlock()
+
+	sessions := make([]*Session, 0, len(tokens))
+	for _, token := range tokens {
+		session, err := r.repo.Read(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		if session != nil {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+// KeepAlive does nothing since registrations live as long as their sessions
+func (r *Registry) KeepAlive(_ context.Context, _ string) error {
+	return nil
+}
@@ -0,0 +1,44 @@
+package filegt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t, t.TempDir())
+	registry := NewRegistry(repository)
+
+	web, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, web.SetAttribute(ctx, semgt.PlatformKey, "web"))
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.Empty(t, repository.registrations)
+
+	assert.NoError(t, repository.Save(ctx, web))
+	app, _ := repository.Create(ctx, "def")
+	assert.NoError(t, repository.Save(ctx, app))
+
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", app))
+	assert.Equal(t, "web", repository.registrations["abc"].platform)
+	assert.Equal(t, "universal", repository.registrations["def"].platform)
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Equal(t, []*Session{web, app}, sessions)
+
+	assert.NoError(t, registry.Deregister(ctx, "archer", web))
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Equal(t, []*Session{app}, sessions)
+
+	assert.NoError(t, repository.Remove(ctx, "def"))
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+	assert.NoError(t, registry.KeepAlive(ctx, "archer"))
+}
@@ -0,0 +1,470 @@
+package filegt
+
+import (
+	"context"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/semgt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// SyncPolicy controls when the log is flushed to disk
+	SyncPolicy int
+
+	// Options of Repository
+	Options struct {
+		// SyncPolicy controls when the log is flushed to disk
+		SyncPolicy SyncPolicy
+		// SyncPeriod is how often the log is flushed by SyncPeriodically
+		SyncPeriod time.Duration
+		// CompactInterval is how often the log is compacted into the
+		// snapshot, the log is only compacted by Compact if it is zero
+		CompactInterval time.Duration
+	}
+
+	Option func(*Options)
+
+	// Repository is a semgt.Repository that keeps sessions in memory and
+	// persists them to a directory for a single process. Every change is
+	// appended to a log, which is periodically compacted into a snapshot,
+	// both are replayed on startup so that sessions survive a restart
+	Repository struct {
+		mu          sync.Mutex
+		dir         string
+		codec       codec.Codec
+		timeout     time.Duration
+		idleTimeout time.Duration
+		opt         *Options
+		log         *os.File
+		logSize     int64
+		dirty       bool
+		closed      bool
+		sessions    map[string]*Session
+		// registrations maps token to registration
+		registrations map[string]*registration
+		seq           uint64
+		stopGuard     sync.Once
+		stopChan      chan struct{}
+	}
+
+	registration struct {
+		principal string
+		platform  string
+		seq       uint64
+	}
+)
+
+const (
+	// SyncPeriodically flushes the log every Options.SyncPeriod, changes
+	// within the period may be lost if the machine crashes
+	SyncPeriodically SyncPolicy = iota
+	// SyncAlways flushes the log after every change
+	SyncAlways
+	// SyncNever leaves flushing the log to the operating system
+	SyncNever
+)
+
+var (
+	_ semgt.Repository[*Session] = (*Repository)(nil)
+
+	defaultOptions = Options{
+		SyncPolicy:      SyncPeriodically,
+		SyncPeriod:      time.Second,
+		CompactInterval: 10 * time.Minute,
+	}
+)
+
+// WithSyncPolicy sets when the log is flushed to disk, SyncPeriodically by default
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(opt *Options) {
+		opt.SyncPolicy = policy
+	}
+}
+
+// WithSyncPeriod sets how often the log is flushed by SyncPeriodically, a second by default
+func WithSyncPeriod(period time.Duration) Option {
+	return func(opt *Options) {
+		if period > 0 {
+			opt.SyncPeriod = period
+		}
+	}
+}
+
+// WithCompactInterval sets how often the log is compacted, ten minutes by
+// default, the background compaction is disabled if the interval is not positive
+func WithCompactInterval(interval time.Duration) Option {
+	return func(opt *Options) {
+		opt.CompactInterval = interval
+	}
+}
+
+// NewRepository recovers the sessions persisted in the directory, which
+// is created if missing, a torn tail of the log left by a crash is dropped
+func NewRepository(dir string, codec codec.Codec, timeout time.Duration,
+	idleTimeout time.Duration, opts ...Option) (*Repository, error) {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	r := &Repository{
+		dir:           dir,
+		codec:         codec,
+		timeout:       timeout,
+		idleTimeout:   idleTimeout,
+		opt:           &opt,
+		sessions:      make(map[string]*Session),
+		registrations: make(map[string]*registration),
+		stopChan:      make(chan struct{}),
+	}
+
+	snap, err := r.readSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	if snap != nil {
+		r.restore(snap)
+	}
+
+	log, err := os.OpenFile(filepath.Join(dir, logName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	r.logSize, err = r.replayLog(log)
+	if err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+
+	r.log = log
+	go r.startBackground()
+
+	return r, nil
+}
+
+// Create returns a new Session which is not stored until Save
+func (r *Repository) Create(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return &Session{
+		MapSession: semgt.NewSessionTimeout(token, r.codec, r.timeout, r.idleTimeout),
+		repo:       r,
+	}, nil
+}
+
+func (r *Repository) Read(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	session, ok := r.sessions[token]
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	if session.GetExpired() {
+		_ = r.Remove(ctx, token)
+		_ = session.MapSession.Stop(ctx)
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// Save stores the Session and appends its whole state to the log
+func (r *Repository) Save(ctx context.Context, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	keys, err := session.AttributeKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err = r.appendLocked(&record{Op: opSave, Token: session.Token(), Session: session.state(keys)}); err != nil {
+		return err
+	}
+
+	session.repo = r
+	r.sessions[session.Token()] = session
+	return nil
+}
+
+// Remove removes the Session and its registration
+func (r *Repository) Remove(ctx context.Context, token string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.sessions[token]; !ok {
+		return nil
+	}
+
+	if err := r.appendLocked(&record{Op: opRemove, Token: token}); err != nil {
+		return err
+	}
+
+	delete(r.sessions, token)
+	delete(r.registrations, token)
+	return nil
+}
+
+// Sync flushes the log to disk
+func (r *Repository) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.syncLocked()
+}
+
+// Compact writes the live sessions to the snapshot and empties the log,
+// expired sessions are dropped. A crash in between is safe since records
+// replayed on the newer snapshot leave the same state
+func (r *Repository) Compact() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+
+	ctx := context.Background()
+	snap := &snapshot{}
+	for token, session := range r.sessions {
+		keys, err := session.AttributeKeys(ctx)
+		if err != nil || session.GetExpired() {
+			delete(r.sessions, token)
+			delete(r.registrations, token)
+			_ = session.MapSession.Stop(ctx)
+			continue
+		}
+
+		snap.Sessions = append(snap.Sessions, session.state(keys))
+	}
+
+	for _, token := range r.registeredLocked(func(*registration) bool { return true }) {
+		reg := r.registrations[token]
+		snap.Registrations = append(snap.Registrations, &registrationState{
+			Token:     token,
+			Principal: reg.principal,
+			Platform:  reg.platform,
+		})
+	}
+
+	if err := r.writeSnapshot(snap); err != nil {
+		return err
+	}
+
+	if err := r.log.Truncate(0); err != nil {
+		return err
+	}
+
+	r.logSize = 0
+	r.dirty = false
+	return r.log.Sync()
+}
+
+// Close stops the background sync and compaction, then syncs and closes the log
+func (r *Repository) Close() error {
+	r.stopGuard.Do(func() {
+		close(r.stopChan)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	if err := r.log.Sync(); err != nil {
+		_ = r.log.Close()
+		return err
+	}
+
+	return r.log.Close()
+}
+
+func (r *Repository) startBackground() {
+	var syncC, compactC <-chan time.Time
+	if r.opt.SyncPolicy == SyncPeriodically {
+		ticker := time.NewTicker(r.opt.SyncPeriod)
+		defer ticker.Stop()
+		syncC = ticker.C
+	}
+
+	if r.opt.CompactInterval > 0 {
+		ticker := time.NewTicker(r.opt.CompactInterval)
+		defer ticker.Stop()
+		compactC = ticker.C
+	}
+
+	for {
+		select {
+		case <-syncC:
+			_ = r.Sync()
+		case <-compactC:
+			_ = r.Compact()
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+// appendIfStored appends the record unless the Session has been removed,
+// so that changes to a removed Session never resurrect it
+func (r *Repository) appendIfStored(session *Session, rec *record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sessions[session.Token()] != session {
+		return nil
+	}
+
+	return r.appendLocked(rec)
+}
+
+func (r *Repository) appendLocked(rec *record) error {
+	if r.closed {
+		return ErrClosed
+	}
+
+	payload, err := r.codec.Encode(rec)
+	if err != nil {
+		return err
+	}
+
+	n, err := writeFrame(r.log, payload)
+	if err != nil {
+		// drop the partial frame so that later records stay readable
+		_ = r.log.Truncate(r.logSize)
+		return err
+	}
+
+	r.logSize += n
+	r.dirty = true
+	if r.opt.SyncPolicy == SyncAlways {
+		return r.syncLocked()
+	}
+
+	return nil
+}
+
+func (r *Repository) syncLocked() error {
+	if r.closed {
+		return ErrClosed
+	}
+
+	if !r.dirty {
+		return nil
+	}
+
+	if err := r.log.Sync(); err != nil {
+		return err
+	}
+
+	r.dirty = false
+	return nil
+}
+
+// apply replays the record on the state
+func (r *Repository) apply(rec *record) {
+	session := r.sessions[rec.Token]
+	switch rec.Op {
+	case opSave:
+		if rec.Session != nil {
+			r.sessions[rec.Token] = r.newSession(rec.Session)
+		}
+	case opSet:
+		if session != nil {
+			session.SetRawAttribute(rec.Name, rec.Data)
+		}
+	case opDelete:
+		if session != nil {
+			session.SetRawAttribute(rec.Name, "")
+		}
+	case opTouch:
+		if session != nil {
+			session.SetLastAccessTime(time.Unix(0, rec.AccessTime))
+		}
+	case opRemove:
+		delete(r.sessions, rec.Token)
+		delete(r.registrations, rec.Token)
+	case opRegister:
+		if _, ok := r.registrations[rec.Token]; !ok {
+			r.seq++
+			r.registrations[rec.Token] = &registration{principal: rec.Principal, platform: rec.Platform, seq: r.seq}
+		}
+	case opDeregister:
+		delete(r.registrations, rec.Token)
+	}
+}
+
+func (r *Repository) restore(snap *snapshot) {
+	for _, state := range snap.Sessions {
+		r.sessions[state.Token] = r.newSession(state)
+	}
+
+	for _, reg := range snap.Registrations {
+		r.apply(&record{Op: opRegister, Token: reg.Token, Principal: reg.Principal, Platform: reg.Platform})
+	}
+}
+
+func (r *Repository) newSession(state *sessionState) *Session {
+	ms := semgt.NewSessionTimeout(state.Token, r.codec, state.Timeout, state.IdleTimeout)
+	ms.SetStartTime(time.Unix(0, state.StartTime))
+	ms.SetLastAccessTime(time.Unix(0, state.LastAccessTime))
+	for key, data := range state.Attributes {
+		ms.SetRawAttribute(key, data)
+	}
+
+	return &Session{MapSession: ms, repo: r}
+}
+
+// registeredLocked returns the registered tokens matching the filter
+// in the order of registration
+func (r *Repository) registeredLocked(filter func(*registration) bool) []string {
+	var tokens []string
+	for token, reg := range r.registrations {
+		if filter(reg) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return r.registrations[tokens[i]].seq < r.registrations[tokens[j]].seq
+	})
+
+	return tokens
+}
@@ -0,0 +1,235 @@
+package filegt
+
+import (
+	"bytes"
+	"context"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRepository(t *testing.T, dir string, opts ...Option) *Repository {
+	opts = append([]Option{WithCompactInterval(0)}, opts...)
+	repository, err := NewRepository(dir, codec.JSON, 10*time.Minute, time.Minute, opts...)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = repository.Close() })
+
+	return repository
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir, WithSyncPolicy(SyncAlways))
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, session.SetAttribute(ctx, "age", 18))
+	assert.NoError(t, session.RemoveAttribute(ctx, "name"))
+	assert.NoError(t, session.Touch(ctx))
+	assert.NoError(t, NewRegistry(repository).Register(ctx, "archer", session))
+
+	removed, _ := repository.Create(ctx, "def")
+	assert.NoError(t, repository.Save(ctx, removed))
+	assert.NoError(t, repository.Remove(ctx, "def"))
+	assert.NoError(t, repository.Close())
+
+	recovered := newTestRepository(t, dir)
+	read, err := recovered.Read(ctx, "abc")
+	assert.NoError(t, err)
+	age, _, err := read.AttributeAsInt(ctx, "age")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(18), age)
+	_, found, err := read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, session.GetStartTime().UnixNano(), read.GetStartTime().UnixNano())
+	assert.Equal(t, session.GetLastAccessTime().UnixNano(), read.GetLastAccessTime().UnixNano())
+
+	read, err = recovered.Read(ctx, "def")
+	assert.NoError(t, err)
+	assert.Nil(t, read)
+
+	sessions, err := NewRegistry(recovered).ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestRecoverTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+	assert.NoError(t, repository.Close())
+
+	path := filepath.Join(dir, logName)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	// a crash while appending leaves a partial frame
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 42, 1, 2, 3, 4, '{', '"'})
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	recovered := newTestRepository(t, dir)
+	read, err := recovered.Read(ctx, "abc")
+	assert.NoError(t, err)
+	name, _, err := read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", name)
+
+	truncated, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), truncated.Size())
+
+	assert.NoError(t, read.SetAttribute(ctx, "age", 18))
+	assert.NoError(t, recovered.Close())
+
+	recovered = newTestRepository(t, dir)
+	read, err = recovered.Read(ctx, "abc")
+	assert.NoError(t, err)
+	age, _, err := read.AttributeAsInt(ctx, "age")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(18), age)
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir)
+
+	for _, token := range []string{"abc", "def", "ghi"} {
+		session, _ := repository.Create(ctx, token)
+		assert.NoError(t, session.SetAttribute(ctx, "name", token))
+		assert.NoError(t, repository.Save(ctx, session))
+		assert.NoError(t, NewRegistry(repository).Register(ctx, "archer", session))
+	}
+	assert.NoError(t, repository.Remove(ctx, "def"))
+
+	expired, _ := repository.Read(ctx, "ghi")
+	expired.SetLastAccessTime(time.Now().Add(-2 * time.Minute))
+
+	assert.NoError(t, repository.Compact())
+	info, err := os.Stat(filepath.Join(dir, logName))
+	assert.NoError(t, err)
+	assert.Zero(t, info.Size())
+	assert.True(t, expired.GetExpired())
+
+	assert.NoError(t, repository.Close())
+
+	recovered := newTestRepository(t, dir)
+	assert.Len(t, recovered.sessions, 1)
+	sessions, err := NewRegistry(recovered).ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "abc", sessions[0].Token())
+}
+
+func TestCrashDuringCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+	removed, _ := repository.Create(ctx, "def")
+	assert.NoError(t, repository.Save(ctx, removed))
+	assert.NoError(t, repository.Remove(ctx, "def"))
+	assert.NoError(t, repository.Sync())
+
+	log, err := os.ReadFile(filepath.Join(dir, logName))
+	assert.NoError(t, err)
+	assert.NoError(t, repository.Compact())
+	assert.NoError(t, repository.Close())
+
+	// the snapshot is renamed but the log is not truncated yet
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, logName), log, 0600))
+
+	recovered := newTestRepository(t, dir)
+	assert.Len(t, recovered.sessions, 1)
+	read, err := recovered.Read(ctx, "abc")
+	assert.NoError(t, err)
+	name, _, err := read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.Equal(t, "archer", name)
+}
+
+func TestCorruptedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, snapshotName), []byte("garbage!!"), 0600))
+
+	_, err := NewRepository(dir, codec.JSON, 10*time.Minute, time.Minute)
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestCorruptedLogRecord(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, repository.Close())
+
+	// an intact frame whose record cannot be decoded, followed by a valid record
+	path := filepath.Join(dir, logName)
+	log, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = writeFrame(&buf, "garbage!!")
+	assert.NoError(t, err)
+	corrupted := append(buf.Bytes(), log...)
+	assert.NoError(t, os.WriteFile(path, corrupted, 0600))
+
+	_, err = NewRepository(dir, codec.JSON, 10*time.Minute, time.Minute)
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	// the log is left for inspection
+	kept, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, corrupted, kept)
+}
+
+func TestNotResurrected(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repository := newTestRepository(t, dir)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, repository.Remove(ctx, "abc"))
+	assert.NoError(t, session.SetAttribute(ctx, semgt.AlreadyReplacedKey, true))
+	assert.NoError(t, repository.Close())
+
+	recovered := newTestRepository(t, dir)
+	assert.Empty(t, recovered.sessions)
+}
+
+func TestStopAndClose(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t, t.TempDir(), WithSyncPolicy(SyncNever))
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, session.Flush(ctx))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Same(t, session, read)
+
+	assert.NoError(t, session.Stop(ctx))
+	read, err = repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, read)
+
+	assert.NoError(t, repository.Close())
+	other, _ := repository.Create(ctx, "def")
+	assert.ErrorIs(t, repository.Save(ctx, other), ErrClosed)
+	assert.ErrorIs(t, repository.Compact(), ErrClosed)
+}
@@ -0,0 +1,90 @@
+package filegt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+)
+
+type (
+	// Session is a semgt.MapSession persisted by a file Repository,
+	// attribute changes and Touch are appended to the log once the
+	// Session is saved
+	Session struct {
+		*semgt.MapSession
+		repo *Repository
+	}
+)
+
+var _ semgt.Session = (*Session)(nil)
+
+func (s *Session) SetAttribute(ctx context.Context, key string, value any) error {
+	if err := s.MapSession.SetAttribute(ctx, key, value); err != nil {
+		return err
+	}
+
+	data, ok := s.RawAttribute(key)
+	if !ok {
+		return s.repo.appendIfStored(s, &record{Op: opDelete, Token: s.Token(), Name: key})
+	}
+
+	return s.repo.appendIfStored(s, &record{Op: opSet, Token: s.Token(), Name: key, Data: data})
+}
+
+func (s *Session) RemoveAttribute(ctx context.Context, key string) error {
+	if err := s.MapSession.RemoveAttribute(ctx, key); err != nil {
+		return err
+	}
+
+	return s.repo.appendIfStored(s, &record{Op: opDelete, Token: s.Token(), Name: key})
+}
+
+func (s *Session) Touch(ctx context.Context) error {
+	if err := s.MapSession.Touch(ctx); err != nil {
+		return err
+	}
+
+	return s.repo.appendIfStored(s, &record{
+		Op:         opTouch,
+		Token:      s.Token(),
+		AccessTime: s.GetLastAccessTime().UnixNano(),
+	})
+}
+
+// Flush saves the Session and syncs the log regardless of the SyncPolicy
+func (s *Session) Flush(ctx context.Context) error {
+	if err := s.MapSession.Flush(ctx); err != nil {
+		return err
+	}
+
+	if err := s.repo.Save(ctx, s); err != nil {
+		return err
+	}
+
+	return s.repo.Sync()
+}
+
+func (s *Session) Stop(ctx context.Context) error {
+	if err := s.MapSession.Stop(ctx); err != nil {
+		return err
+	}
+
+	return s.repo.Remove(ctx, s.Token())
+}
+
+func (s *Session) state(keys []string) *sessionState {
+	attrs := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if data, ok := s.RawAttribute(key); ok {
+			attrs[key] = data
+		}
+	}
+
+	return &sessionState{
+		Token:          s.Token(),
+		StartTime:      s.GetStartTime().UnixNano(),
+		LastAccessTime: s.GetLastAccessTime().UnixNano(),
+		Timeout:        s.GetTimeout(),
+		IdleTimeout:    s.GetIdleTimeout(),
+		Attributes:     attrs,
+	}
+}
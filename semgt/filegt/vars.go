@@ -0,0 +1,8 @@
+package filegt
+
+import "errors"
+
+var (
+	// ErrClosed is returned when the Repository has been closed
+	ErrClosed = errors.New("session repository closed")
+)
@@ -0,0 +1,176 @@
+package redisgt
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/shrinex/shield/semgt"
+	"sort"
+	"strconv"
+)
+
+type (
+	// Registry is a semgt.Registry that keeps the tokens of each principal
+	// and platform in a redis sorted set scored by the start time, and the
+	// platforms of each principal in a set. The keys of a principal share a
+	// hash tag, so they stay in the same slot of a redis cluster
+	Registry struct {
+		repo *Repository
+	}
+)
+
+var _ semgt.Registry[*Session] = (*Registry)(nil)
+
+func NewRegistry(repo *Repository) *Registry {
+	return &Registry{repo: repo}
+}
+
+func (r *Registry) Register(ctx context.Context, principal string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	platform, found, err := session.AttributeAsString(ctx, semgt.PlatformKey)
+	if err != nil {
+		return err
+	}
+
+	if !found || len(platform) == 0 {
+		platform = semgt.DefaultPlatform
+	}
+
+	platformsKey := r.platformsKey(principal)
+	tokensKey := r.tokensKey(principal, platform)
+	_, err = r.repo.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddNX(ctx, tokensKey, redis.Z{
+			Score:  float64(session.GetStartTime().UnixMilli()),
+			Member: session.Token(),
+		})
+		pipe.SAdd(ctx, platformsKey, platform)
+		pipe.PExpire(ctx, tokensKey, r.repo.timeout)
+		pipe.PExpire(ctx, platformsKey, r.repo.timeout)
+		return nil
+	})
+
+	return err
+}
+
+func (r *Registry) Deregister(ctx context.Context, principal string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	platforms, err := r.platforms(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.repo.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, platform := range platforms {
+			pipe.ZRem(ctx, r.tokensKey(principal, platform), session.Token())
+		}
+		return nil
+	})
+
+	return err
+}
+
+// ActiveSessions returns the sessions ordered by platform and start
+// time, tokens of sessions which no longer exist are dropped
+func (r *Registry) ActiveSessions(ctx context.Context, principal string) ([]*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	platforms, err := r.platforms(ctx, principal)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0)
+	for _, platform := range platforms {
+		tokensKey := r.tokensKey(principal, platform)
+		tokens, err := r.repo.client.ZRange(ctx, tokensKey, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		var stale []any
+		for _, token := range tokens {
+			session, err := r.repo.Read(ctx, token)
+			if err != nil {
+				return nil, err
+			}
+
+			if session == nil {
+				stale = append(stale, token)
+				continue
+			}
+
+			sessions = append(sessions, session)
+		}
+
+		if len(stale) != 0 {
+			if err = r.repo.client.ZRem(ctx, tokensKey, stale...).Err(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sessions, nil
+}
+
+// KeepAlive refreshes the TTL of the keys of the principal, and drops
+// the tokens of sessions which must have timed out
+func (r *Registry) KeepAlive(ctx context.Context, principal string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	platforms, err := r.platforms(ctx, principal)
+	if err != nil {
+		return err
+	}
+
+	if len(platforms) == 0 {
+		return nil
+	}
+
+	deadline := strconv.FormatInt(nowFunc().Add(-r.repo.timeout).UnixMilli(), 10)
+	_, err = r.repo.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, platform := range platforms {
+			tokensKey := r.tokensKey(principal, platform)
+			pipe.ZRemRangeByScore(ctx, tokensKey, "-inf", "("+deadline)
+			pipe.PExpire(ctx, tokensKey, r.repo.timeout)
+		}
+		pipe.PExpire(ctx, r.platformsKey(principal), r.repo.timeout)
+		return nil
+	})
+
+	return err
+}
+
+func (r *Registry) platforms(ctx context.Context, principal string) ([]string, error) {
+	platforms, err := r.repo.client.SMembers(ctx, r.platformsKey(principal)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(platforms)
+	return platforms, nil
+}
+
+func (r *Registry) platformsKey(principal string) string {
+	return r.repo.opt.KeyPrefix + "principals:{" + principal + "}"
+}
+
+func (r *Registry) tokensKey(principal string, platform string) string {
+	return r.platformsKey(principal) + ":" + platform
+}
@@ -0,0 +1,85 @@
+package redisgt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	web, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, web.SetAttribute(ctx, semgt.PlatformKey, "web"))
+	assert.NoError(t, repository.Save(ctx, web))
+	app, _ := repository.Create(ctx, "def")
+	assert.NoError(t, repository.Save(ctx, app))
+
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", app))
+
+	members, err := mr.SMembers("shield:principals:{archer}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"universal", "web"}, members)
+
+	tokens, err := mr.ZMembers("shield:principals:{archer}:web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, tokens)
+	assert.Equal(t, 10*time.Minute, mr.TTL("shield:principals:{archer}:web"))
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	assert.Equal(t, "def", sessions[0].Token())
+	assert.Equal(t, "abc", sessions[1].Token())
+
+	assert.NoError(t, registry.Deregister(ctx, "archer", web))
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "def", sessions[0].Token())
+}
+
+func TestActiveSessionsDropsStale(t *testing.T) {
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, registry.Register(ctx, "archer", session))
+	assert.NoError(t, repository.Remove(ctx, "abc"))
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+	assert.False(t, mr.Exists("shield:principals:{archer}:universal"))
+}
+
+func TestKeepAlive(t *testing.T) {
+	now := time.Now()
+	freeze(t, now)
+
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, registry.Register(ctx, "archer", session))
+
+	mr.FastForward(5 * time.Minute)
+	assert.NoError(t, registry.KeepAlive(ctx, "archer"))
+	assert.Equal(t, 10*time.Minute, mr.TTL("shield:principals:{archer}"))
+	assert.Equal(t, 10*time.Minute, mr.TTL("shield:principals:{archer}:universal"))
+
+	// tokens of sessions started before the timeout are dropped
+	freeze(t, now.Add(11*time.Minute))
+	assert.NoError(t, registry.KeepAlive(ctx, "archer"))
+	assert.False(t, mr.Exists("shield:principals:{archer}:universal"))
+}
@@ -0,0 +1,186 @@
+package redisgt
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/semgt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Options of Repository
+	Options struct {
+		// KeyPrefix prefixes every redis key
+		KeyPrefix string
+	}
+
+	Option func(*Options)
+
+	// Repository is a semgt.Repository that stores each Session in a redis
+	// hash, which expires when the Session times out or stays idle too long
+	Repository struct {
+		client      redis.UniversalClient
+		codec       codec.Codec
+		timeout     time.Duration
+		idleTimeout time.Duration
+		opt         *Options
+	}
+)
+
+var (
+	_ semgt.Repository[*Session] = (*Repository)(nil)
+
+	defaultOptions = Options{
+		KeyPrefix: "shield:",
+	}
+)
+
+// WithKeyPrefix sets the prefix of redis keys, shield: by default
+func WithKeyPrefix(prefix string) Option {
+	return func(opt *Options) {
+		if len(prefix) != 0 {
+			opt.KeyPrefix = prefix
+		}
+	}
+}
+
+func NewRepository(client redis.UniversalClient, codec codec.Codec,
+	timeout time.Duration, idleTimeout time.Duration, opts ...Option) *Repository {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &Repository{
+		client:      client,
+		codec:       codec,
+		timeout:     timeout,
+		idleTimeout: idleTimeout,
+		opt:         &opt,
+	}
+}
+
+// Create returns a new Session which is not stored until Save
+func (r *Repository) Create(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return newSession(token, r), nil
+}
+
+func (r *Repository) Read(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	fields, err := r.client.HGetAll(ctx, r.sessionKey(token)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	session, err := r.decode(token, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.GetExpired() {
+		_ = r.Remove(ctx, token)
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// Save replaces the stored Session, its TTL is reset
+// to the time left before it expires
+func (r *Repository) Save(ctx context.Context, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	session.mu.RLock()
+	ttl := session.ttlLocked()
+	session.mu.RUnlock()
+
+	key := r.sessionKey(session.Token())
+	if ttl <= 0 {
+		return r.client.Del(ctx, key).Err()
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, session.fields())
+		pipe.PExpire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	session.persisted = true
+	session.mu.Unlock()
+
+	return nil
+}
+
+func (r *Repository) Remove(ctx context.Context, token string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return r.client.Del(ctx, r.sessionKey(token)).Err()
+}
+
+func (r *Repository) decode(token string, fields map[string]string) (*Session, error) {
+	session := &Session{
+		token:     token,
+		repo:      r,
+		persisted: true,
+		attrs:     make(map[string]string, len(fields)),
+	}
+
+	millis := make(map[string]int64, 4)
+	for _, name := range []string{startTimeField, lastAccessTimeField, timeoutField, idleTimeoutField} {
+		n, err := strconv.ParseInt(fields[name], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s has invalid %s", ErrMalformedSession, token, name)
+		}
+
+		millis[name] = n
+	}
+
+	session.startTime = time.UnixMilli(millis[startTimeField])
+	session.lastAccessTime = time.UnixMilli(millis[lastAccessTimeField])
+	session.timeout = time.Duration(millis[timeoutField]) * time.Millisecond
+	session.idleTimeout = time.Duration(millis[idleTimeoutField]) * time.Millisecond
+
+	for field, value := range fields {
+		if strings.HasPrefix(field, attrPrefix) {
+			session.attrs[strings.TrimPrefix(field, attrPrefix)] = value
+		}
+	}
+
+	return session, nil
+}
+
+func (r *Repository) sessionKey(token string) string {
+	return r.opt.KeyPrefix + "sessions:" + token
+}
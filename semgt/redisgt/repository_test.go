@@ -0,0 +1,58 @@
+package redisgt
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReadMissing(t *testing.T) {
+	_, repository := newTestRepository(t)
+
+	session, err := repository.Read(context.Background(), "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, session)
+}
+
+func TestSaveTTL(t *testing.T) {
+	now := time.Now()
+	freeze(t, now)
+
+	ctx := context.Background()
+	mr, repository := newTestRepository(t, WithKeyPrefix("app:"))
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.Equal(t, time.Minute, mr.TTL("app:sessions:abc"))
+
+	// the timeout wins once less than the idle timeout is left
+	freeze(t, now.Add(9*time.Minute+30*time.Second))
+	assert.NoError(t, session.Touch(ctx))
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.Equal(t, 30*time.Second, mr.TTL("app:sessions:abc"))
+}
+
+func TestReadExpired(t *testing.T) {
+	now := time.Now()
+	freeze(t, now)
+
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	freeze(t, now.Add(2*time.Minute))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, read)
+	assert.False(t, mr.Exists("shield:sessions:abc"))
+}
+
+func TestReadMalformed(t *testing.T) {
+	mr, repository := newTestRepository(t)
+	mr.HSet("shield:sessions:abc", "startTime", "yesterday")
+
+	_, err := repository.Read(context.Background(), "abc")
+	assert.ErrorIs(t, err, ErrMalformedSession)
+}
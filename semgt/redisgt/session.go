@@ -0,0 +1,378 @@
+package redisgt
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/shrinex/shield/semgt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type (
+	// Session is a semgt.Session stored in a redis hash, attribute
+	// changes and Touch are written through once the Session is saved
+	Session struct {
+		token          string
+		repo           *Repository
+		mu             sync.RWMutex
+		persisted      bool
+		startTime      time.Time
+		lastAccessTime time.Time
+		timeout        time.Duration
+		idleTimeout    time.Duration
+		attrs          map[string]string
+	}
+)
+
+var (
+	_ semgt.Session = (*Session)(nil)
+
+	// updateScript writes fields to an existing session hash and
+	// refreshes its TTL, it never resurrects a removed session
+	updateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+if ttl <= 0 then
+	redis.call('DEL', KEYS[1])
+	return 0
+end
+for i = 2, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('PEXPIRE', KEYS[1], ttl)
+return 1
+`)
+)
+
+func newSession(token string, repo *Repository) *Session {
+	nowTime := nowFunc()
+	return &Session{
+		token:          token,
+		repo:           repo,
+		startTime:      nowTime,
+		lastAccessTime: nowTime,
+		timeout:        repo.timeout,
+		idleTimeout:    repo.idleTimeout,
+		attrs:          make(map[string]string),
+	}
+}
+
+func (s *Session) Token() string {
+	return s.token
+}
+
+func (s *Session) StartTime(ctx context.Context) (time.Time, error) {
+	if err := s.checkState(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.startTime, nil
+}
+
+func (s *Session) Timeout(ctx context.Context) (time.Duration, error) {
+	if err := s.checkState(ctx); err != nil {
+		return time.Duration(0), err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.timeout, nil
+}
+
+func (s *Session) IdleTimeout(ctx context.Context) (time.Duration, error) {
+	if err := s.checkState(ctx); err != nil {
+		return time.Duration(0), err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.idleTimeout, nil
+}
+
+func (s *Session) LastAccessTime(ctx context.Context) (time.Time, error) {
+	if err := s.checkState(ctx); err != nil {
+		return time.Time{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastAccessTime, nil
+}
+
+func (s *Session) Expired(ctx context.Context) (bool, error) {
+	if err := s.checkState(ctx); err != nil {
+		return false, err
+	}
+
+	return s.GetExpired(), nil
+}
+
+func (s *Session) Attribute(ctx context.Context, key string, ptr any) (bool, error) {
+	if err := s.checkState(ctx); err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	data, ok := s.attrs[key]
+	s.mu.RUnlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	err := s.repo.codec.Decode(data, ptr)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Session) AttributeAsInt(ctx context.Context, key string) (int64, bool, error) {
+	var value int64
+	found, err := s.Attribute(ctx, key, &value)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return value, found, nil
+}
+
+func (s *Session) AttributeAsBool(ctx context.Context, key string) (bool, bool, error) {
+	var value bool
+	found, err := s.Attribute(ctx, key, &value)
+	if err != nil {
+		return false, false, err
+	}
+
+	return value, found, nil
+}
+
+func (s *Session) AttributeAsFloat(ctx context.Context, key string) (float64, bool, error) {
+	var value float64
+	found, err := s.Attribute(ctx, key, &value)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return value, found, nil
+}
+
+func (s *Session) AttributeAsString(ctx context.Context, key string) (string, bool, error) {
+	var value string
+	found, err := s.Attribute(ctx, key, &value)
+	if err != nil {
+		return "", false, err
+	}
+
+	return value, found, nil
+}
+
+func (s *Session) AttributeKeys(ctx context.Context) ([]string, error) {
+	if err := s.checkState(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for key := range s.attrs {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (s *Session) SetAttribute(ctx context.Context, key string, value any) error {
+	if err := s.checkState(ctx); err != nil {
+		return err
+	}
+
+	if value == nil {
+		return s.removeAttribute(ctx, key)
+	}
+
+	data, err := s.repo.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.attrs[key] = data
+	s.mu.Unlock()
+
+	return s.update(ctx, attrPrefix+key, data)
+}
+
+func (s *Session) RemoveAttribute(ctx context.Context, key string) error {
+	if err := s.checkState(ctx); err != nil {
+		return err
+	}
+
+	return s.removeAttribute(ctx, key)
+}
+
+func (s *Session) Touch(ctx context.Context) error {
+	if err := s.checkState(ctx); err != nil {
+		return err
+	}
+
+	nowTime := nowFunc()
+
+	s.mu.Lock()
+	s.lastAccessTime = nowTime
+	s.mu.Unlock()
+
+	return s.update(ctx, lastAccessTimeField, formatTime(nowTime))
+}
+
+func (s *Session) Flush(ctx context.Context) error {
+	if err := s.checkState(ctx); err != nil {
+		return err
+	}
+
+	return s.repo.Save(ctx, s)
+}
+
+// Stop expires this session and removes it from redis
+// so that other replicas no longer read it
+func (s *Session) Stop(ctx context.Context) error {
+	if err := s.checkState(ctx); err != nil {
+		return err
+	}
+
+	data, err := s.repo.codec.Encode(true)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.attrs[semgt.AlreadyExpiredKey] = data
+	s.mu.Unlock()
+
+	return s.repo.Remove(ctx, s.token)
+}
+
+///=====================================
+///		    Getters
+///=====================================
+
+func (s *Session) GetStartTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.startTime
+}
+
+func (s *Session) GetLastAccessTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.lastAccessTime
+}
+
+func (s *Session) GetExpired() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.attrs[semgt.AlreadyExpiredKey]; ok {
+		return true
+	}
+
+	return s.ttlLocked() <= 0
+}
+
+// ttlLocked returns how long the session lives
+// if it is not accessed, mu must be held
+func (s *Session) ttlLocked() time.Duration {
+	deadline := s.startTime.Add(s.timeout)
+	if idle := s.lastAccessTime.Add(s.idleTimeout); idle.Before(deadline) {
+		deadline = idle
+	}
+
+	return deadline.Sub(nowFunc())
+}
+
+// fields returns the hash fields of the session
+func (s *Session) fields() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fields := make(map[string]any, len(s.attrs)+4)
+	fields[startTimeField] = formatTime(s.startTime)
+	fields[lastAccessTimeField] = formatTime(s.lastAccessTime)
+	fields[timeoutField] = strconv.FormatInt(s.timeout.Milliseconds(), 10)
+	fields[idleTimeoutField] = strconv.FormatInt(s.idleTimeout.Milliseconds(), 10)
+	for key, value := range s.attrs {
+		fields[attrPrefix+key] = value
+	}
+
+	return fields
+}
+
+func (s *Session) update(ctx context.Context, field string, value string) error {
+	s.mu.RLock()
+	persisted := s.persisted
+	ttl := s.ttlLocked()
+	s.mu.RUnlock()
+
+	// written by Save later
+	if !persisted {
+		return nil
+	}
+
+	key := s.repo.sessionKey(s.token)
+	return updateScript.Run(ctx, s.repo.client, []string{key}, ttl.Milliseconds(), field, value).Err()
+}
+
+func (s *Session) removeAttribute(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.attrs, key)
+	persisted := s.persisted
+	s.mu.Unlock()
+
+	if !persisted {
+		return nil
+	}
+
+	return s.repo.client.HDel(ctx, s.repo.sessionKey(s.token), attrPrefix+key).Err()
+}
+
+func (s *Session) checkState(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.attrs[semgt.AlreadyReplacedKey]; ok {
+		return semgt.ErrReplaced
+	}
+
+	if _, ok := s.attrs[semgt.AlreadyOverflowKey]; ok {
+		return semgt.ErrOverflow
+	}
+
+	if _, ok := s.attrs[semgt.AlreadyExpiredKey]; ok {
+		return semgt.ErrExpired
+	}
+
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
@@ -0,0 +1,104 @@
+package redisgt
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestRepository(t *testing.T, opts ...Option) (*miniredis.Miniredis, *Repository) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return mr, NewRepository(client, codec.JSON, 10*time.Minute, time.Minute, opts...)
+}
+
+// freeze fixes the current time until the test ends
+func freeze(t *testing.T, now time.Time) {
+	nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { nowFunc = time.Now })
+}
+
+func TestSessionWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+
+	session, err := repository.Create(ctx, "abc")
+	assert.NoError(t, err)
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+	assert.False(t, mr.Exists("shield:sessions:abc"))
+
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, session.SetAttribute(ctx, "age", 18))
+	assert.NoError(t, session.RemoveAttribute(ctx, "name"))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	age, found, err := read.AttributeAsInt(ctx, "age")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(18), age)
+	_, found, err = read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, session.GetStartTime().UnixMilli(), read.GetStartTime().UnixMilli())
+
+	timeout, err := read.Timeout(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, timeout)
+}
+
+func TestSessionTouch(t *testing.T) {
+	now := time.Now()
+	freeze(t, now)
+
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.Equal(t, time.Minute, mr.TTL("shield:sessions:abc"))
+
+	later := now.Add(30 * time.Second)
+	freeze(t, later)
+	mr.FastForward(30 * time.Second)
+	assert.NoError(t, session.Touch(ctx))
+	assert.Equal(t, time.Minute, mr.TTL("shield:sessions:abc"))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, later.UnixMilli(), read.GetLastAccessTime().UnixMilli())
+}
+
+func TestSessionStop(t *testing.T) {
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	assert.NoError(t, session.Stop(ctx))
+	assert.False(t, mr.Exists("shield:sessions:abc"))
+	assert.True(t, session.GetExpired())
+
+	_, err := session.Expired(ctx)
+	assert.ErrorIs(t, err, semgt.ErrExpired)
+}
+
+func TestSessionNotResurrected(t *testing.T) {
+	ctx := context.Background()
+	mr, repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	assert.NoError(t, repository.Remove(ctx, "abc"))
+	assert.NoError(t, session.SetAttribute(ctx, semgt.AlreadyReplacedKey, true))
+	assert.False(t, mr.Exists("shield:sessions:abc"))
+
+	_, err := session.Expired(ctx)
+	assert.ErrorIs(t, err, semgt.ErrReplaced)
+}
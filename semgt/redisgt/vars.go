@@ -0,0 +1,24 @@
+package redisgt
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	nowFunc = time.Now
+
+	// ErrMalformedSession is returned when a stored session cannot be decoded
+	ErrMalformedSession = errors.New("malformed redis session")
+)
+
+const (
+	startTimeField      = "startTime"
+	lastAccessTimeField = "lastAccessTime"
+	timeoutField        = "timeout"
+	idleTimeoutField    = "idleTimeout"
+
+	// attrPrefix prefixes the hash fields of attributes,
+	// so that they never clash with the fields above
+	attrPrefix = "attr:"
+)
@@ -68,13 +68,13 @@ func (r *MapSessionRegistry) Register(ctx context.Context, principal string, ses
 		return nil
 	}
 
-	platform, found, err := session.AttributeAsString(ctx, "__platformKey")
+	platform, found, err := session.AttributeAsString(ctx, PlatformKey)
 	if err != nil {
 		return err
 	}
 
 	if !found || len(platform) == 0 {
-		platform = "universal"
+		platform = DefaultPlatform
 	}
 
 	// build relations
@@ -0,0 +1,99 @@
+package sqlgt
+
+import (
+	"strconv"
+	"strings"
+)
+
+type (
+	// Dialect hides the differences between databases
+	Dialect struct {
+		// Name of the database
+		Name string
+		// Placeholder returns the nth bind parameter, starting from 1
+		Placeholder func(n int) string
+		// Text is the column type of attribute values
+		Text string
+		// InlineIndexes declares indexes in CREATE TABLE, for
+		// databases lacking CREATE INDEX IF NOT EXISTS, e.g. MySQL
+		InlineIndexes bool
+	}
+
+	// index of a table, Name is suffixed to the name of the table
+	index struct {
+		Name    string
+		Columns string
+	}
+)
+
+var (
+	// Postgres is the Dialect of PostgreSQL
+	Postgres = &Dialect{
+		Name: "postgres",
+		Placeholder: func(n int) string {
+			return "$" + strconv.Itoa(n)
+		},
+		Text: "TEXT",
+	}
+
+	// MySQL is the Dialect of MySQL and MariaDB
+	MySQL = &Dialect{
+		Name: "mysql",
+		Placeholder: func(int) string {
+			return "?"
+		},
+		Text:          "LONGTEXT",
+		InlineIndexes: true,
+	}
+
+	// SQLite is the Dialect of SQLite
+	SQLite = &Dialect{
+		Name: "sqlite",
+		Placeholder: func(int) string {
+			return "?"
+		},
+		Text: "TEXT",
+	}
+)
+
+// createTable returns the statements creating the table and its indexes, they
+// are idempotent so that a migration interrupted halfway can be run again, as
+// MySQL commits DDL implicitly instead of rolling it back with the migration
+func (d *Dialect) createTable(table string, definitions []string, indexes ...index) []string {
+	if d.InlineIndexes {
+		for _, idx := range indexes {
+			definitions = append(definitions, "INDEX "+table+"_"+idx.Name+" ("+idx.Columns+")")
+		}
+	}
+
+	stmts := []string{"CREATE TABLE IF NOT EXISTS " + table + " (" + strings.Join(definitions, ", ") + ")"}
+	if !d.InlineIndexes {
+		for _, idx := range indexes {
+			stmts = append(stmts, "CREATE INDEX IF NOT EXISTS "+table+"_"+idx.Name+" ON "+table+" ("+idx.Columns+")")
+		}
+	}
+
+	return stmts
+}
+
+// rebind replaces ? in the query with the placeholders of the Dialect
+func (d *Dialect) rebind(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			sb.WriteString(d.Placeholder(n))
+			continue
+		}
+
+		sb.WriteRune(ch)
+	}
+
+	return sb.String()
+}
+
+// in returns n comma separated ?
+func in(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,144 @@
+package sqlgt
+
+import (
+	"context"
+	"fmt"
+)
+
+type (
+	// tables are the names of the tables, prefixed by Options.TablePrefix
+	tables struct {
+		migrations    string
+		sessions      string
+		attributes    string
+		registrations string
+	}
+
+	migration struct {
+		version     int
+		description string
+		statements  func(tables, *Dialect) []string
+	}
+)
+
+// migrations must only be appended, applied migrations are never run again,
+// statements must be idempotent, see Dialect.createTable
+var migrations = []migration{
+	{
+		version:     1,
+		description: "create sessions and attributes",
+		statements: func(t tables, d *Dialect) []string {
+			return append(
+				d.createTable(t.sessions, []string{
+					"token VARCHAR(255) NOT NULL PRIMARY KEY",
+					"start_time BIGINT NOT NULL",
+					"last_access_time BIGINT NOT NULL",
+					"timeout BIGINT NOT NULL",
+					"idle_timeout BIGINT NOT NULL",
+					"expiry_time BIGINT NOT NULL",
+				}, index{Name: "expiry_time", Columns: "expiry_time"}),
+				d.createTable(t.attributes, []string{
+					"token VARCHAR(255) NOT NULL",
+					"name VARCHAR(255) NOT NULL",
+					"data " + d.Text + " NOT NULL",
+					"PRIMARY KEY (token, name)",
+				})...,
+			)
+		},
+	},
+	{
+		version:     2,
+		description: "create registrations",
+		statements: func(t tables, d *Dialect) []string {
+			return d.createTable(t.registrations, []string{
+				"token VARCHAR(255) NOT NULL PRIMARY KEY",
+				"principal VARCHAR(255) NOT NULL",
+				"platform VARCHAR(64) NOT NULL",
+				"registered_at BIGINT NOT NULL",
+			}, index{Name: "principal", Columns: "principal, platform"})
+		},
+	},
+}
+
+func newTables(prefix string) tables {
+	return tables{
+		migrations:    prefix + "schema_migrations",
+		sessions:      prefix + "sessions",
+		attributes:    prefix + "session_attributes",
+		registrations: prefix + "session_registrations",
+	}
+}
+
+// Migrate creates or upgrades the tables, each migration is applied
+// in a transaction and recorded so that it is applied only once.
+// On MySQL, DDL commits implicitly, so a failed migration may leave
+// some of its tables behind, they are skipped once Migrate is retried.
+// Replicas should not migrate concurrently
+func (r *Repository) Migrate(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+r.tables.migrations+" ("+
+		"version INTEGER NOT NULL PRIMARY KEY, "+
+		"description VARCHAR(255) NOT NULL, "+
+		"applied_at BIGINT NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	applied, err := r.appliedVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if err = r.apply(ctx, m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT version FROM "+r.tables.migrations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+func (r *Repository) apply(ctx context.Context, m migration) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range m.statements(r.tables, r.opt.Dialect) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, r.opt.Dialect.rebind("INSERT INTO "+r.tables.migrations+
+		" (version, description, applied_at) VALUES (?, ?, ?)"), m.version, m.description, nowFunc().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
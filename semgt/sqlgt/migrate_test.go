@@ -0,0 +1,81 @@
+package sqlgt
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shrinex/shield/codec"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+	"testing"
+	"time"
+)
+
+func newTestRepository(t *testing.T, opts ...Option) *Repository {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	// every connection opens another in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	opts = append([]Option{WithCleanupInterval(0)}, opts...)
+	repository := NewRepository(db, codec.JSON, 10*time.Minute, time.Minute, opts...)
+	assert.NoError(t, repository.Migrate(context.Background()))
+
+	return repository
+}
+
+// freeze fixes the current time until the test ends
+func freeze(t *testing.T, now time.Time) {
+	nowFunc = func() time.Time { return now }
+	t.Cleanup(func() { nowFunc = time.Now })
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t, WithTablePrefix("app_"))
+	assert.NoError(t, repository.Migrate(ctx))
+
+	var count int
+	err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM app_schema_migrations").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), count)
+
+	for _, table := range []string{"app_sessions", "app_session_attributes", "app_session_registrations"} {
+		err = repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count)
+		assert.NoError(t, err, table)
+	}
+}
+
+func TestMigrateInterrupted(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+
+	// as if MySQL had committed the DDL of the failed migration
+	_, err := repository.db.ExecContext(ctx, "DELETE FROM "+repository.tables.migrations+" WHERE version = 2")
+	assert.NoError(t, err)
+	assert.NoError(t, repository.Migrate(ctx))
+
+	applied, err := repository.appliedVersions(ctx)
+	assert.NoError(t, err)
+	assert.True(t, applied[2])
+}
+
+func TestCreateTable(t *testing.T) {
+	definitions := []string{"token VARCHAR(255) NOT NULL PRIMARY KEY", "principal VARCHAR(255) NOT NULL"}
+	idx := index{Name: "principal", Columns: "principal"}
+
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS registrations (token VARCHAR(255) NOT NULL PRIMARY KEY, principal VARCHAR(255) NOT NULL)",
+		"CREATE INDEX IF NOT EXISTS registrations_principal ON registrations (principal)",
+	}, Postgres.createTable("registrations", definitions, idx))
+	assert.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS registrations (token VARCHAR(255) NOT NULL PRIMARY KEY, " +
+			"principal VARCHAR(255) NOT NULL, INDEX registrations_principal (principal))",
+	}, MySQL.createTable("registrations", definitions, idx))
+}
+
+func TestRebind(t *testing.T) {
+	query := "SELECT token FROM sessions WHERE token IN (" + in(3) + ") AND expiry_time > ?"
+	assert.Equal(t, "SELECT token FROM sessions WHERE token IN ($1, $2, $3) AND expiry_time > $4", Postgres.rebind(query))
+	assert.Equal(t, query, MySQL.rebind(query))
+}
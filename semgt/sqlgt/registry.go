@@ -0,0 +1,127 @@
+package sqlgt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+)
+
+type (
+	// Registry is a semgt.Registry that stores the principal and platform of
+	// each registered Session as a row, which is deleted with the Session
+	Registry struct {
+		repo *Repository
+	}
+)
+
+var _ semgt.Registry[*Session] = (*Registry)(nil)
+
+func NewRegistry(repo *Repository) *Registry {
+	return &Registry{repo: repo}
+}
+
+// Register registers the stored Session with a conditional insert, a Session
+// which is registered already or has been removed meanwhile is not registered.
+// A concurrent registration of the same Session may still violate the primary
+// key, which is not an error as long as the Session ends up registered
+func (r *Registry) Register(ctx context.Context, principal string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	platform, found, err := session.AttributeAsString(ctx, semgt.PlatformKey)
+	if err != nil {
+		return err
+	}
+
+	if !found || len(platform) == 0 {
+		platform = semgt.DefaultPlatform
+	}
+
+	t := r.repo.tables
+	_, err = r.repo.db.ExecContext(ctx, r.repo.rebind("INSERT INTO "+t.registrations+
+		" (token, principal, platform, registered_at) SELECT s.token, ?, ?, ? FROM "+t.sessions+
+		" s WHERE s.token = ? AND NOT EXISTS (SELECT 1 FROM "+t.registrations+" g WHERE g.token = s.token)"),
+		principal, platform, nowFunc().UnixMilli(), session.Token())
+	if err == nil {
+		return nil
+	}
+
+	if registered, rerr := r.registered(ctx, session.Token()); rerr == nil && registered {
+		return nil
+	}
+
+	return err
+}
+
+func (r *Registry) Deregister(ctx context.Context, _ string, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	_, err := r.repo.db.ExecContext(ctx, r.repo.rebind("DELETE FROM "+r.repo.tables.registrations+
+		" WHERE token = ?"), session.Token())
+	return err
+}
+
+// ActiveSessions returns the sessions ordered by registration time
+func (r *Registry) ActiveSessions(ctx context.Context, principal string) ([]*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	t := r.repo.tables
+	rows, err := r.repo.db.QueryContext(ctx, r.repo.rebind("SELECT g.token FROM "+t.registrations+" g JOIN "+
+		t.sessions+" s ON s.token = g.token WHERE g.principal = ? AND s.expiry_time > ? "+
+		"ORDER BY g.registered_at, g.token"), principal, nowFunc().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err = rows.Scan(&token); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+	_ = rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*Session, 0, len(tokens))
+	for _, token := range tokens {
+		session, err := r.repo.Read(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+
+		if session != nil {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (r *Registry) registered(ctx context.Context, token string) (bool, error) {
+	var registered int
+	err := r.repo.db.QueryRowContext(ctx, r.repo.rebind("SELECT COUNT(*) FROM "+r.repo.tables.registrations+
+		" WHERE token = ?"), token).Scan(&registered)
+	return registered != 0, err
+}
+
+// KeepAlive does nothing since registrations live as long as their sessions
+func (r *Registry) KeepAlive(_ context.Context, _ string) error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package sqlgt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	web, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, web.SetAttribute(ctx, semgt.PlatformKey, "web"))
+	assert.NoError(t, repository.Save(ctx, web))
+	app, _ := repository.Create(ctx, "def")
+	assert.NoError(t, repository.Save(ctx, app))
+
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", web))
+	assert.NoError(t, registry.Register(ctx, "archer", app))
+
+	var platform string
+	err := repository.db.QueryRowContext(ctx, "SELECT platform FROM shield_session_registrations WHERE token = 'def'").Scan(&platform)
+	assert.NoError(t, err)
+	assert.Equal(t, "universal", platform)
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	assert.NoError(t, registry.Deregister(ctx, "archer", web))
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, "def", sessions[0].Token())
+	assert.NoError(t, registry.KeepAlive(ctx, "archer"))
+}
+
+func TestRegisterRemoved(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, registry.Register(ctx, "archer", session))
+
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, registry.Register(ctx, "archer", session))
+	assert.NoError(t, repository.Remove(ctx, "abc"))
+
+	sessions, err = registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestActiveSessionsSkipsExpired(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+	assert.NoError(t, registry.Register(ctx, "archer", session))
+
+	freeze(t, time.Now().Add(2*time.Minute))
+	sessions, err := registry.ActiveSessions(ctx, "archer")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestRegisterConcurrently(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	registry := NewRegistry(repository)
+
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- registry.Register(ctx, "archer", session)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	var count int
+	err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM shield_session_registrations").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
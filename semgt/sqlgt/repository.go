@@ -0,0 +1,399 @@
+package sqlgt
+
+import (
+	"context"
+	"database/sql"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/semgt"
+	"sync"
+	"time"
+)
+
+type (
+	// Options of Repository
+	Options struct {
+		// Dialect of the database, SQLite by default
+		Dialect *Dialect
+		// TablePrefix prefixes every table name
+		TablePrefix string
+		// BatchSize is the maximum number of expired sessions deleted at once
+		BatchSize int
+		// CleanupInterval is how often expired sessions are deleted,
+		// expired sessions are only deleted by Reap if it is zero
+		CleanupInterval time.Duration
+	}
+
+	Option func(*Options)
+
+	// Repository is a semgt.Repository that stores each Session as a row
+	// and its attributes as rows of another table, see Migrate
+	Repository struct {
+		db          *sql.DB
+		codec       codec.Codec
+		timeout     time.Duration
+		idleTimeout time.Duration
+		opt         *Options
+		tables      tables
+		stopGuard   sync.Once
+		stopChan    chan struct{}
+	}
+
+	// execer is implemented by both *sql.DB and *sql.Tx
+	execer interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+	}
+)
+
+var (
+	_ semgt.Repository[*Session] = (*Repository)(nil)
+
+	defaultOptions = Options{
+		Dialect:         SQLite,
+		TablePrefix:     "shield_",
+		BatchSize:       500,
+		CleanupInterval: time.Minute,
+	}
+)
+
+// WithDialect sets the Dialect of the database, SQLite by default
+func WithDialect(dialect *Dialect) Option {
+	return func(opt *Options) {
+		if dialect != nil {
+			opt.Dialect = dialect
+		}
+	}
+}
+
+// WithTablePrefix sets the prefix of table names, shield_ by default
+func WithTablePrefix(prefix string) Option {
+	return func(opt *Options) {
+		if len(prefix) != 0 {
+			opt.TablePrefix = prefix
+		}
+	}
+}
+
+// WithBatchSize sets the maximum number of expired sessions deleted at once, 500 by default
+func WithBatchSize(size int) Option {
+	return func(opt *Options) {
+		if size > 0 {
+			opt.BatchSize = size
+		}
+	}
+}
+
+// WithCleanupInterval sets how often expired sessions are deleted, a minute by
+// default, the background cleanup is disabled if the interval is not positive
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(opt *Options) {
+		opt.CleanupInterval = interval
+	}
+}
+
+// NewRepository returns a Repository backed by the database,
+// the tables must have been created by Migrate
+func NewRepository(db *sql.DB, codec codec.Codec, timeout time.Duration,
+	idleTimeout time.Duration, opts ...Option) *Repository {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	r := &Repository{
+		db:          db,
+		codec:       codec,
+		timeout:     timeout,
+		idleTimeout: idleTimeout,
+		opt:         &opt,
+		tables:      newTables(opt.TablePrefix),
+		stopChan:    make(chan struct{}),
+	}
+
+	if opt.CleanupInterval > 0 {
+		go r.startCleanup()
+	}
+
+	return r
+}
+
+// Create returns a new Session which is not stored until Save
+func (r *Repository) Create(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return &Session{
+		MapSession: semgt.NewSessionTimeout(token, r.codec, r.timeout, r.idleTimeout),
+		repo:       r,
+	}, nil
+}
+
+func (r *Repository) Read(ctx context.Context, token string) (*Session, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	var startTime, lastAccessTime, timeout, idleTimeout int64
+	err := r.db.QueryRowContext(ctx, r.rebind("SELECT start_time, last_access_time, timeout, idle_timeout FROM "+
+		r.tables.sessions+" WHERE token = ?"), token).Scan(&startTime, &lastAccessTime, &timeout, &idleTimeout)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	ms := semgt.NewSessionTimeout(token, r.codec,
+		time.Duration(timeout)*time.Millisecond, time.Duration(idleTimeout)*time.Millisecond)
+	ms.SetStartTime(time.UnixMilli(startTime))
+	ms.SetLastAccessTime(time.UnixMilli(lastAccessTime))
+
+	if ms.GetExpired() {
+		_ = r.Remove(ctx, token)
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind("SELECT name, data FROM "+
+		r.tables.attributes+" WHERE token = ?"), token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, data string
+		if err = rows.Scan(&name, &data); err != nil {
+			return nil, err
+		}
+
+		ms.SetRawAttribute(name, data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Session{MapSession: ms, repo: r, persisted: true}, nil
+}
+
+// Save replaces the stored Session and its attributes in a transaction
+func (r *Repository) Save(ctx context.Context, session *Session) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	keys, err := session.AttributeKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	token := session.Token()
+	for _, table := range []string{r.tables.sessions, r.tables.attributes} {
+		if _, err = tx.ExecContext(ctx, r.rebind("DELETE FROM "+table+" WHERE token = ?"), token); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, r.rebind("INSERT INTO "+r.tables.sessions+
+		" (token, start_time, last_access_time, timeout, idle_timeout, expiry_time) VALUES (?, ?, ?, ?, ?, ?)"),
+		token,
+		session.GetStartTime().UnixMilli(),
+		session.GetLastAccessTime().UnixMilli(),
+		session.GetTimeout().Milliseconds(),
+		session.GetIdleTimeout().Milliseconds(),
+		session.expiryTime().UnixMilli(),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		data, ok := session.RawAttribute(key)
+		if !ok {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, r.rebind("INSERT INTO "+r.tables.attributes+
+			" (token, name, data) VALUES (?, ?, ?)"), token, key, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	session.setPersisted()
+	return nil
+}
+
+// Remove deletes the Session, its attributes and its registration in a transaction
+func (r *Repository) Remove(ctx context.Context, token string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = r.deleteTokens(ctx, tx, []any{token}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reap deletes expired sessions in batches of Options.BatchSize,
+// it returns the number of deleted sessions
+func (r *Repository) Reap(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		default:
+		}
+
+		tokens, err := r.expiredTokens(ctx)
+		if err != nil {
+			return total, err
+		}
+
+		if len(tokens) == 0 {
+			return total, nil
+		}
+
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return total, err
+		}
+
+		if err = r.deleteTokens(ctx, tx, tokens); err != nil {
+			_ = tx.Rollback()
+			return total, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return total, err
+		}
+
+		total += len(tokens)
+		if len(tokens) < r.opt.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+func (r *Repository) StopCleanup() error {
+	r.stopGuard.Do(func() {
+		close(r.stopChan)
+	})
+
+	return nil
+}
+
+func (r *Repository) startCleanup() {
+	ticker := time.NewTicker(r.opt.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = r.Reap(context.Background())
+		case <-r.stopChan:
+			return
+		}
+	}
+}
+
+func (r *Repository) expiredTokens(ctx context.Context) ([]any, error) {
+	rows, err := r.db.QueryContext(ctx, r.rebind("SELECT token FROM "+r.tables.sessions+
+		" WHERE expiry_time <= ? ORDER BY expiry_time LIMIT ?"), nowFunc().UnixMilli(), r.opt.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []any
+	for rows.Next() {
+		var token string
+		if err = rows.Scan(&token); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *Repository) deleteTokens(ctx context.Context, db execer, tokens []any) error {
+	for _, table := range []string{r.tables.registrations, r.tables.attributes, r.tables.sessions} {
+		_, err := db.ExecContext(ctx, r.rebind("DELETE FROM "+table+" WHERE token IN ("+in(len(tokens))+")"), tokens...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setAttribute replaces the attribute of a stored session, it
+// inserts nothing if the session has been removed meanwhile
+func (r *Repository) setAttribute(ctx context.Context, token string, name string, data string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, r.rebind("DELETE FROM "+r.tables.attributes+
+		" WHERE token = ? AND name = ?"), token, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, r.rebind("INSERT INTO "+r.tables.attributes+" (token, name, data) "+
+		"SELECT token, ?, ? FROM "+r.tables.sessions+" WHERE token = ?"), name, data, token)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) removeAttribute(ctx context.Context, token string, name string) error {
+	_, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM "+r.tables.attributes+
+		" WHERE token = ? AND name = ?"), token, name)
+	return err
+}
+
+func (r *Repository) touch(ctx context.Context, session *Session) error {
+	_, err := r.db.ExecContext(ctx, r.rebind("UPDATE "+r.tables.sessions+
+		" SET last_access_time = ?, expiry_time = ? WHERE token = ?"),
+		session.GetLastAccessTime().UnixMilli(), session.expiryTime().UnixMilli(), session.Token())
+	return err
+}
+
+func (r *Repository) rebind(query string) string {
+	return r.opt.Dialect.rebind(query)
+}
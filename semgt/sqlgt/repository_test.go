@@ -0,0 +1,118 @@
+package sqlgt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSaveAndRead(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+
+	session, err := repository.Create(ctx, "abc")
+	assert.NoError(t, err)
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, read)
+
+	assert.NoError(t, repository.Save(ctx, session))
+	read, err = repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	name, found, err := read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "archer", name)
+	assert.Equal(t, session.GetStartTime().UnixMilli(), read.GetStartTime().UnixMilli())
+	assert.Equal(t, 10*time.Minute, read.GetTimeout())
+	assert.Equal(t, time.Minute, read.GetIdleTimeout())
+}
+
+func TestWriteThrough(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	assert.NoError(t, session.SetAttribute(ctx, "age", 18))
+	assert.NoError(t, session.SetAttribute(ctx, "age", 19))
+	assert.NoError(t, session.SetAttribute(ctx, "name", "archer"))
+	assert.NoError(t, session.SetAttribute(ctx, "name", nil))
+	time.Sleep(2 * time.Millisecond)
+	assert.NoError(t, session.Touch(ctx))
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	age, _, err := read.AttributeAsInt(ctx, "age")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(19), age)
+	_, found, err := read.AttributeAsString(ctx, "name")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, session.GetLastAccessTime().UnixMilli(), read.GetLastAccessTime().UnixMilli())
+}
+
+func TestNotResurrected(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	assert.NoError(t, repository.Remove(ctx, "abc"))
+	assert.NoError(t, session.SetAttribute(ctx, semgt.AlreadyReplacedKey, true))
+
+	var count int
+	err := repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM shield_session_attributes").Scan(&count)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	_, err = session.Expired(ctx)
+	assert.ErrorIs(t, err, semgt.ErrReplaced)
+}
+
+func TestStop(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t)
+	session, _ := repository.Create(ctx, "abc")
+	assert.NoError(t, repository.Save(ctx, session))
+
+	assert.NoError(t, session.Stop(ctx))
+	assert.True(t, session.GetExpired())
+
+	read, err := repository.Read(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, read)
+}
+
+func TestReap(t *testing.T) {
+	ctx := context.Background()
+	repository := newTestRepository(t, WithBatchSize(2))
+	registry := NewRegistry(repository)
+
+	for _, token := range []string{"a", "b", "c", "d", "e"} {
+		session, _ := repository.Create(ctx, token)
+		assert.NoError(t, session.SetAttribute(ctx, "name", token))
+		assert.NoError(t, repository.Save(ctx, session))
+		assert.NoError(t, registry.Register(ctx, "archer", session))
+	}
+
+	n, err := repository.Reap(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+
+	freeze(t, time.Now().Add(2*time.Minute))
+	n, err = repository.Reap(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	for _, table := range []string{"shield_sessions", "shield_session_attributes", "shield_session_registrations"} {
+		var count int
+		err = repository.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&count)
+		assert.NoError(t, err)
+		assert.Zero(t, count, table)
+	}
+}
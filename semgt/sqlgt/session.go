@@ -0,0 +1,104 @@
+package sqlgt
+
+import (
+	"context"
+	"github.com/shrinex/shield/semgt"
+	"sync"
+	"time"
+)
+
+type (
+	// Session is a semgt.MapSession stored in a database, attribute
+	// changes and Touch are written through once the Session is saved
+	Session struct {
+		*semgt.MapSession
+		repo      *Repository
+		mu        sync.RWMutex
+		persisted bool
+	}
+)
+
+var _ semgt.Session = (*Session)(nil)
+
+func (s *Session) SetAttribute(ctx context.Context, key string, value any) error {
+	if err := s.MapSession.SetAttribute(ctx, key, value); err != nil {
+		return err
+	}
+
+	if !s.isPersisted() {
+		return nil
+	}
+
+	data, ok := s.RawAttribute(key)
+	if !ok {
+		return s.repo.removeAttribute(ctx, s.Token(), key)
+	}
+
+	return s.repo.setAttribute(ctx, s.Token(), key, data)
+}
+
+func (s *Session) RemoveAttribute(ctx context.Context, key string) error {
+	if err := s.MapSession.RemoveAttribute(ctx, key); err != nil {
+		return err
+	}
+
+	if !s.isPersisted() {
+		return nil
+	}
+
+	return s.repo.removeAttribute(ctx, s.Token(), key)
+}
+
+func (s *Session) Touch(ctx context.Context) error {
+	if err := s.MapSession.Touch(ctx); err != nil {
+		return err
+	}
+
+	if !s.isPersisted() {
+		return nil
+	}
+
+	return s.repo.touch(ctx, s)
+}
+
+func (s *Session) Flush(ctx context.Context) error {
+	if err := s.MapSession.Flush(ctx); err != nil {
+		return err
+	}
+
+	return s.repo.Save(ctx, s)
+}
+
+// Stop expires this session and removes it from the
+// database so that other replicas no longer read it
+func (s *Session) Stop(ctx context.Context) error {
+	if err := s.MapSession.Stop(ctx); err != nil {
+		return err
+	}
+
+	return s.repo.Remove(ctx, s.Token())
+}
+
+// expiryTime returns when the session expires if it is not accessed
+func (s *Session) expiryTime() time.Time {
+	expiry := s.GetStartTime().Add(s.GetTimeout())
+	if idle := s.GetLastAccessTime().Add(s.GetIdleTimeout()); idle.Before(expiry) {
+		expiry = idle
+	}
+
+	return expiry
+}
+
+func (s *Session) isPersisted() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.persisted
+}
+
+func (s *Session) setPersisted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.persisted = true
+}
@@ -0,0 +1,5 @@
+package sqlgt
+
+import "time"
+
+var nowFunc = time.Now
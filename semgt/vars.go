@@ -17,6 +17,14 @@ var (
 )
 
 const (
+	// PlatformKey is a session attribute key that
+	// point to logged-in platform, sessions are
+	// grouped by principal and platform
+	PlatformKey = "__platformKey"
+
+	// DefaultPlatform is used when the session has no platform
+	DefaultPlatform = "universal"
+
 	// AlreadyExpiredKey is a session attribute key that indicates
 	// the session is expired
 	AlreadyExpiredKey = "__alreadyExpiredKey"
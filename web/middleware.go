@@ -0,0 +1,299 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/security"
+	"net"
+	"net/http"
+)
+
+type (
+	// Middleware binds security.Subject to each request, the context returned
+	// by Subject.Login is stored on the request so that handlers can call
+	// Subject methods with r.Context()
+	Middleware struct {
+		subject security.Subject
+		opt     *Options
+	}
+
+	loginResponse struct {
+		Token string `json:"token"`
+	}
+)
+
+// NewMiddleware returns a Middleware, by default the token
+// is read from the Authorization header with Bearer scheme
+func NewMiddleware(subject security.Subject, opts ...Option) *Middleware {
+	opt := defaultOptions
+	for _, f := range opts {
+		f(&opt)
+	}
+
+	return &Middleware{
+		subject: subject,
+		opt:     &opt,
+	}
+}
+
+// Handler logs in the Subject with the token carried by the request, requests
+// without token or with a rejected one, e.g. an expired session cookie, are
+// logged in with the remember-me cookie if configured, or passed on anonymously
+// otherwise, so that the rules decide whether the route needs authentication,
+// only a failure to authenticate the token is answered with WriteError. Users authenticated by authc.Stateless tokens, e.g. a JWT, are
+// bound without session. The client ip is bound as the authc client key for
+// lockout and as the ip environment attribute for authz policies, and the
+// domain is activated if configured
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		ctx := authc.WithClientKey(r.Context(), ip)
+		ctx = authz.WithEnvironment(ctx, authz.Attributes{"ip": ip})
+		if m.opt.Domain != nil {
+			if domain := m.opt.Domain(r); len(domain) != 0 {
+				ctx = m.subject.SwitchDomain(ctx, domain)
+			}
+		}
+		r = r.WithContext(ctx)
+
+		token, found := m.opt.extractToken(r)
+		if !found {
+			next.ServeHTTP(w, m.autoLogin(w, r))
+			return
+		}
+
+		ctx, err := m.subject.Login(r.Context(), authc.NewBearerToken(token))
+		if err != nil {
+			if rejected(err) {
+				next.ServeHTTP(w, m.autoLogin(w, r))
+				return
+			}
+
+			WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireAuthenticated answers with 401 unless the Subject is fully authenticated
+func (m *Middleware) RequireAuthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := authenticated(m.subject, r); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole answers with 401 unless the Subject is fully
+// authenticated, and with 403 if it does not have the role
+func (m *Middleware) RequireRole(role authz.Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.subject.CheckRole(r.Context(), role); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAuthority answers with 401 unless the Subject is fully
+// authenticated, and with 403 if it does not have the authority
+func (m *Middleware) RequireAuthority(authority authz.Authority, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.subject.CheckAuthority(r.Context(), authority); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authorize evaluates the Rules against the request, the Subject must be bound
+// by Handler first. Requests that fail the Requirement are answered with 401
+// unless the Subject is authenticated, in which case 403 is answered, realm
+// failures are answered by WriteError
+func (m *Middleware) Authorize(rules *Rules, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requirement := DenyAll
+		rule, params, found := rules.Match(r)
+		if found {
+			requirement = rule.Requirement
+			r = withPathParams(r, params)
+		}
+
+		if err := requirement.Check(m.subject, r); err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoginHandler logs in with the username and password form parameters, the
+// new session token is sent in the JSON body and, if configured, the cookie.
+// A remember-me cookie is issued as well if asked by the form. The client ip
+// is bound as the authc client key, so it can be served without Handler
+func (m *Middleware) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			WriteProblem(w, r, http.StatusMethodNotAllowed, "")
+			return
+		}
+
+		username := r.PostFormValue(m.opt.UsernameParameter)
+		password := r.PostFormValue(m.opt.PasswordParameter)
+		token := authc.NewUsernamePasswordToken(username, password)
+
+		ctx := authc.WithClientKey(r.Context(), clientIP(r))
+		ctx, err := m.subject.Login(ctx, token,
+			security.WithPlatform(m.opt.Platform(r)), security.WithRenewToken())
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		session, err := m.subject.Session(ctx)
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		if len(m.opt.Cookie) != 0 {
+			http.SetCookie(w, m.cookie(r, m.opt.Cookie, session.Token(), 0))
+		}
+
+		if m.opt.rememberMe(r) {
+			m.remember(ctx, w, r)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(&loginResponse{Token: session.Token()})
+	})
+}
+
+// LogoutHandler logs out the Subject bound by Handler and clears the cookies
+func (m *Middleware) LogoutHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			WriteProblem(w, r, http.StatusMethodNotAllowed, "")
+			return
+		}
+
+		_, err := m.subject.Logout(r.Context())
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+
+		if len(m.opt.Cookie) != 0 {
+			http.SetCookie(w, m.cookie(r, m.opt.Cookie, "", -1))
+		}
+
+		if len(m.opt.RememberMeCookie) != 0 {
+			http.SetCookie(w, m.cookie(r, m.opt.RememberMeCookie, "", -1))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// autoLogin logs in with the remember-me cookie, the new session token and the
+// rotated remember-me token are sent with the cookies, a rejected remember-me
+// cookie is cleared and the request is passed on anonymously
+func (m *Middleware) autoLogin(w http.ResponseWriter, r *http.Request) *http.Request {
+	if len(m.opt.RememberMeCookie) == 0 {
+		return r
+	}
+
+	c, err := r.Cookie(m.opt.RememberMeCookie)
+	if err != nil || len(c.Value) == 0 {
+		return r
+	}
+
+	token, err := authc.ParseRememberMeToken(c.Value)
+	if err != nil {
+		http.SetCookie(w, m.cookie(r, m.opt.RememberMeCookie, "", -1))
+		return r
+	}
+
+	ctx, err := m.subject.Login(r.Context(), token,
+		security.WithPlatform(m.opt.Platform(r)), security.WithRenewToken())
+	if err != nil {
+		// keep the cookie if the realm is unavailable
+		if errors.Is(err, authc.ErrUnauthenticated) {
+			http.SetCookie(w, m.cookie(r, m.opt.RememberMeCookie, "", -1))
+		}
+
+		return r
+	}
+
+	userDetails, err := m.subject.UserDetails(ctx)
+	if err != nil {
+		return r
+	}
+
+	if rud, ok := authc.As[*authc.RememberMeUserDetails](userDetails); ok {
+		http.SetCookie(w, m.cookie(r, m.opt.RememberMeCookie, rud.Renewed(), m.rememberMeMaxAge()))
+	}
+
+	if session, err := m.subject.Session(ctx); err == nil && len(m.opt.Cookie) != 0 {
+		http.SetCookie(w, m.cookie(r, m.opt.Cookie, session.Token(), 0))
+	}
+
+	return r.WithContext(ctx)
+}
+
+// remember starts a remember-me series for the logged-in user, a failure
+// does not fail the login since the user is logged in already
+func (m *Middleware) remember(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	userDetails, err := m.subject.UserDetails(ctx)
+	if err != nil {
+		return
+	}
+
+	value, err := m.opt.RememberMe.Remember(ctx, userDetails.Principal())
+	if err != nil {
+		return
+	}
+
+	http.SetCookie(w, m.cookie(r, m.opt.RememberMeCookie, value, m.rememberMeMaxAge()))
+}
+
+func (m *Middleware) rememberMeMaxAge() int {
+	return int(m.opt.RememberMe.Validity().Seconds())
+}
+
+func (m *Middleware) cookie(r *http.Request, name string, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
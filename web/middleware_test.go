@@ -0,0 +1,454 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/jwt"
+	"github.com/shrinex/shield/security"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockRealm struct {
+	repository semgt.Repository[*semgt.MapSession]
+}
+
+func (r *mockRealm) Supports(authc.Token) bool {
+	return true
+}
+
+func (r *mockRealm) LoadUserDetails(ctx context.Context, token authc.Token) (authc.UserDetails, error) {
+	if _, ok := token.(*authc.UsernamePasswordToken); ok {
+		if token.Credentials() != "123" {
+			return nil, authc.ErrBadCredentials
+		}
+
+		return authc.NewBearerToken(token.Principal()), nil
+	}
+
+	session, err := r.repository.Read(ctx, token.Credentials())
+	if err != nil || session == nil {
+		return nil, authc.ErrUnauthenticated
+	}
+
+	return authc.NewBearerToken("archer"), nil
+}
+
+type mockAuthzRealm struct {
+}
+
+func (r *mockAuthzRealm) LoadRoles(context.Context, authc.UserDetails) ([]authz.Role, error) {
+	return []authz.Role{authz.NewRole("user")}, nil
+}
+
+func (r *mockAuthzRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return []authz.Authority{authz.NewAuthority("read")}, nil
+}
+
+func newMiddleware(opts ...Option) *Middleware {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{repository: repository})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+
+	return NewMiddleware(subject, opts...)
+}
+
+func login(t *testing.T, m *Middleware, password string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {"archer"}, "password": {password}}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	m.LoginHandler().ServeHTTP(w, r)
+	return w
+}
+
+func TestLoginAndAccess(t *testing.T) {
+	m := newMiddleware()
+
+	w := login(t, m, "123")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp loginResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.NotEmpty(t, resp.Token)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/profile", m.RequireAuthenticated(ok))
+	mux.Handle("/reports", m.RequireAuthority(authz.NewAuthority("read"), ok))
+	mux.Handle("/admin", m.RequireRole(authz.NewRole("admin"), ok))
+	mux.Handle("/logout", m.LogoutHandler())
+	handler := m.Handler(mux)
+
+	serve := func(method string, path string, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		if len(token) != 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/profile", resp.Token).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/reports", resp.Token).Code)
+
+	w = serve(http.MethodGet, "/admin", resp.Token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, ContentTypeProblem, w.Header().Get("Content-Type"))
+
+	w = serve(http.MethodGet, "/profile", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var problem Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, http.StatusUnauthorized, problem.Status)
+	assert.Equal(t, "/profile", problem.Instance)
+
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/profile", "unknown").Code)
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/logout", resp.Token).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/profile", resp.Token).Code)
+}
+
+func TestLoginFailed(t *testing.T) {
+	m := newMiddleware()
+
+	w := login(t, m, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ContentTypeProblem, w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Body.String(), "mockRealm")
+}
+
+func TestClientLockout(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	ac := authc.NewLockoutAuthenticator(authc.NewAuthenticator(&mockRealm{repository: repository}), nil,
+		authc.WithMaxFailures(10), authc.WithMaxClientFailures(2))
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(ac).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject)
+	handler := m.Handler(m.LoginHandler())
+
+	serve := func(username string, remoteAddr string) int {
+		form := url.Values{"username": {username}, "password": {"123456"}}
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// password spraying across principals from one client
+	assert.Equal(t, http.StatusUnauthorized, serve("a", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusUnauthorized, serve("b", "10.0.0.1:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("c", "10.0.0.1:5678"))
+
+	// other clients are not affected
+	assert.Equal(t, http.StatusUnauthorized, serve("c", "10.0.0.2:1234"))
+}
+
+func TestExtractToken(t *testing.T) {
+	opt := defaultOptions
+	WithCookie("SESSION")(&opt)
+	WithQuery("access_token")(&opt)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "bearer header")
+	token, found := opt.extractToken(r)
+	assert.True(t, found)
+	assert.Equal(t, "header", token)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic YXJjaGVyOjEyMw==")
+	r.AddCookie(&http.Cookie{Name: "SESSION", Value: "cookie"})
+	token, found = opt.extractToken(r)
+	assert.True(t, found)
+	assert.Equal(t, "cookie", token)
+
+	r = httptest.NewRequest(http.MethodGet, "/?access_token=query", nil)
+	token, found = opt.extractToken(r)
+	assert.True(t, found)
+	assert.Equal(t, "query", token)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	_, found = opt.extractToken(r)
+	assert.False(t, found)
+}
+
+func TestCookieLogin(t *testing.T) {
+	m := newMiddleware(WithCookie("SESSION"))
+
+	w := login(t, m, "123")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cookies := w.Result().Cookies()
+	assert.Equal(t, 1, len(cookies))
+	assert.Equal(t, "SESSION", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	m.Handler(m.RequireAuthenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDomain(t *testing.T) {
+	policy := authz.NewDomainPolicy()
+	policy.GrantRole("archer", authz.NewRole("admin"), "org-a")
+	policy.GrantRole("archer", authz.NewRole("viewer"), "org-b")
+
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{repository: repository})).
+		Authorizer(authz.NewDomainAuthorizer(policy)).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject, WithDomainHeader("X-Tenant-ID"))
+
+	w := login(t, m, "123")
+	var resp loginResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	handler := m.Handler(m.RequireRole(authz.NewRole("admin"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "org-a", subject.Domain(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(tenant string) int {
+		r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		r.Header.Set("Authorization", "Bearer "+resp.Token)
+		if len(tenant) != 0 {
+			r.Header.Set("X-Tenant-ID", tenant)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("org-a"))
+	assert.Equal(t, http.StatusForbidden, serve("org-b"))
+	assert.Equal(t, http.StatusForbidden, serve(""))
+}
+
+type credentialsLoader struct {
+}
+
+func (l *credentialsLoader) LoadCredentials(_ context.Context, principal string) (authc.UserDetails, string, error) {
+	return authc.NewBearerToken(principal), "", nil
+}
+
+func (l *credentialsLoader) UpdateCredentials(context.Context, authc.UserDetails, string) error {
+	return nil
+}
+
+func TestRememberMe(t *testing.T) {
+	rememberMe := authc.NewRememberMeRealm(authc.NewPersistentTokenStore(), &credentialsLoader{})
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(rememberMe, &mockRealm{repository: repository})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject, WithCookie("SESSION"), WithRememberMe("REMEMBER", rememberMe))
+
+	form := url.Values{"username": {"archer"}, "password": {"123"}, "remember-me": {"on"}}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	m.LoginHandler().ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	assert.NotNil(t, cookies["SESSION"])
+	assert.NotNil(t, cookies["REMEMBER"])
+	assert.Equal(t, int(rememberMe.Validity().Seconds()), cookies["REMEMBER"].MaxAge)
+
+	handler := m.Handler(m.RequireAuthenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(remember *http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+		r.AddCookie(remember)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// the session has gone, the remember-me cookie logs in again
+	w = serve(cookies["REMEMBER"])
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	renewed := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		renewed[c.Name] = c
+	}
+	assert.NotNil(t, renewed["SESSION"])
+	assert.NotEqual(t, cookies["SESSION"].Value, renewed["SESSION"].Value)
+	assert.NotNil(t, renewed["REMEMBER"])
+	assert.NotEqual(t, cookies["REMEMBER"].Value, renewed["REMEMBER"].Value)
+
+	// the old value is presented again, the cookie is assumed stolen
+	w = serve(cookies["REMEMBER"])
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	cleared := w.Result().Cookies()
+	assert.Equal(t, 1, len(cleared))
+	assert.Equal(t, "REMEMBER", cleared[0].Name)
+	assert.True(t, cleared[0].MaxAge < 0)
+
+	// every series has been removed
+	assert.Equal(t, http.StatusUnauthorized, serve(renewed["REMEMBER"]).Code)
+}
+
+func TestRememberMeExpiredSession(t *testing.T) {
+	rememberMe := authc.NewRememberMeRealm(authc.NewPersistentTokenStore(), &credentialsLoader{})
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(rememberMe, &mockRealm{repository: repository})).
+		Authorizer(authz.NewAuthorizer(&mockAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject, WithCookie("SESSION"), WithRememberMe("REMEMBER", rememberMe))
+
+	value, err := rememberMe.Remember(context.TODO(), "archer")
+	assert.NoError(t, err)
+
+	handler := m.Handler(m.RequireAuthenticated(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	// the session cookie has expired on the server
+	r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	r.AddCookie(&http.Cookie{Name: "SESSION", Value: "expired"})
+	r.AddCookie(&http.Cookie{Name: "REMEMBER", Value: value})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	assert.NotNil(t, cookies["SESSION"])
+	assert.NotEqual(t, "expired", cookies["SESSION"].Value)
+	assert.NotNil(t, cookies["REMEMBER"])
+}
+
+func TestStatelessToken(t *testing.T) {
+	secret := []byte("secret")
+	realm := jwt.NewRealm(jwt.NewStaticKeyResolver(secret), jwt.WithAlgorithms("HS256"))
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(realm)).
+		Authorizer(authz.NewAuthorizer(realm)).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject)
+
+	rules := NewRules().
+		Route("/admin").HasRole(authz.NewRole("admin")).
+		Route("/root").HasRole(authz.NewRole("root")).
+		AnyRequest().Authenticated()
+	handler := m.Handler(m.Authorize(rules, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := subject.Session(r.Context())
+		assert.ErrorIs(t, err, authc.ErrUnauthenticated)
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	raw, err := jwt.Sign(jwt.Claims{
+		jwt.ClaimSubject:   "archer",
+		jwt.ClaimExpiresAt: time.Now().Add(time.Hour).Unix(),
+		"roles":            []string{"admin"},
+	}, jwt.HS256, secret, "")
+	assert.NoError(t, err)
+
+	serve := func(path string, token string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// no session has been created for the JWT
+	assert.Equal(t, http.StatusOK, serve("/profile", raw))
+	assert.Equal(t, http.StatusOK, serve("/admin", raw))
+	assert.Equal(t, http.StatusForbidden, serve("/root", raw))
+
+	forged, _ := jwt.Sign(jwt.Claims{jwt.ClaimSubject: "archer"}, jwt.HS256, []byte("forged"), "")
+	assert.Equal(t, http.StatusUnauthorized, serve("/profile", forged))
+}
+
+func TestRejectedTokenIsAnonymous(t *testing.T) {
+	m := newMiddleware()
+
+	rules := NewRules().
+		Route("/login").PermitAll().
+		AnyRequest().Authenticated()
+	handler := m.Handler(m.Authorize(rules, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(path string) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer expired")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/login"))
+	assert.Equal(t, http.StatusUnauthorized, serve("/profile"))
+}
+
+func TestWriteError(t *testing.T) {
+	serve := func(err error) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		WriteError(w, httptest.NewRequest(http.MethodGet, "/", nil), err)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(authc.ErrBadCredentials).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(authc.ErrSecondFactorRequired).Code)
+	assert.Equal(t, http.StatusForbidden, serve(&authz.ForbiddenError{Requirement: "hasRole(admin)"}).Code)
+
+	w := serve(&authc.LockedError{RetryAfter: time.Now().Add(time.Minute)})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(errDatabaseDown).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(&net.OpError{Op: "dial", Err: errors.New("refused")}).Code)
+	assert.Equal(t, http.StatusInternalServerError, serve(errors.New("unexpected")).Code)
+}
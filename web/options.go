@@ -0,0 +1,168 @@
+package web
+
+import (
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/security"
+	"net/http"
+	"strings"
+)
+
+type (
+	// Option can be used to customize Options
+	Option func(*Options)
+
+	// Options contains config attribute that can
+	// affect how Middleware binds Subject to requests
+	Options struct {
+		// Header is the request header that carries the token, empty to disable
+		Header string
+		// Scheme is the optional scheme that prefixes the token in Header, e.g. Bearer
+		Scheme string
+		// Cookie is the cookie that carries the token, empty to disable,
+		// the login handler also sends the session token with it
+		Cookie string
+		// Query is the query parameter that carries the token, empty to disable
+		Query string
+		// UsernameParameter is the form parameter read by the login handler
+		UsernameParameter string
+		// PasswordParameter is the form parameter read by the login handler
+		PasswordParameter string
+		// Platform returns the login platform of the request
+		Platform func(*http.Request) string
+		// Domain returns the active domain of the request, e.g. a tenant,
+		// empty if the request has none
+		Domain func(*http.Request) string
+		// RememberMeCookie is the cookie that carries the remember-me token, empty to disable
+		RememberMeCookie string
+		// RememberMeParameter is the form parameter that asks the login handler
+		// to remember the user
+		RememberMeParameter string
+		// RememberMe issues the remember-me tokens, the Authenticator of the
+		// Subject must consult it to authenticate them
+		RememberMe *authc.RememberMeRealm
+	}
+)
+
+var defaultOptions = Options{
+	Header:              "Authorization",
+	Scheme:              "Bearer",
+	UsernameParameter:   "username",
+	PasswordParameter:   "password",
+	RememberMeParameter: "remember-me",
+	Platform: func(*http.Request) string {
+		return security.DefaultPlatform
+	},
+}
+
+// WithHeader reads the token from the header, the scheme can be empty
+func WithHeader(header string, scheme string) Option {
+	return func(opt *Options) {
+		opt.Header = header
+		opt.Scheme = scheme
+	}
+}
+
+// WithCookie reads the token from the cookie
+func WithCookie(cookie string) Option {
+	return func(opt *Options) {
+		opt.Cookie = cookie
+	}
+}
+
+// WithQuery reads the token from the query parameter,
+// tokens in URLs are easily leaked so use it with care
+func WithQuery(query string) Option {
+	return func(opt *Options) {
+		opt.Query = query
+	}
+}
+
+// WithLoginParameters changes the form parameters read by the login handler
+func WithLoginParameters(username string, password string) Option {
+	return func(opt *Options) {
+		if len(username) != 0 && len(password) != 0 {
+			opt.UsernameParameter = username
+			opt.PasswordParameter = password
+		}
+	}
+}
+
+// WithPlatform specifies how the login platform is determined
+func WithPlatform(platform func(*http.Request) string) Option {
+	return func(opt *Options) {
+		if platform != nil {
+			opt.Platform = platform
+		}
+	}
+}
+
+// WithDomain specifies how the active domain of the request is
+// determined, e.g. from a header or a path parameter
+func WithDomain(domain func(*http.Request) string) Option {
+	return func(opt *Options) {
+		opt.Domain = domain
+	}
+}
+
+// WithDomainHeader reads the active domain from the header, e.g. X-Tenant-ID
+func WithDomainHeader(header string) Option {
+	return WithDomain(func(r *http.Request) string {
+		return r.Header.Get(header)
+	})
+}
+
+// extractToken looks up the token in header, cookie and query parameter in order
+func (opt *Options) extractToken(r *http.Request) (string, bool) {
+	if len(opt.Header) != 0 {
+		if value := strings.TrimSpace(r.Header.Get(opt.Header)); len(value) != 0 {
+			if len(opt.Scheme) == 0 {
+				return value, true
+			}
+
+			prefix := opt.Scheme + " "
+			if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+				return strings.TrimSpace(value[len(prefix):]), true
+			}
+		}
+	}
+
+	if len(opt.Cookie) != 0 {
+		if c, err := r.Cookie(opt.Cookie); err == nil && len(c.Value) != 0 {
+			return c.Value, true
+		}
+	}
+
+	if len(opt.Query) != 0 {
+		if value := r.URL.Query().Get(opt.Query); len(value) != 0 {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// WithRememberMe issues a remember-me cookie on login if the remember-me form
+// parameter is set, requests without token are then logged in with it, it is
+// usually used along with WithCookie so that the new session is kept
+func WithRememberMe(cookie string, realm *authc.RememberMeRealm) Option {
+	return func(opt *Options) {
+		if len(cookie) != 0 && realm != nil {
+			opt.RememberMeCookie = cookie
+			opt.RememberMe = realm
+		}
+	}
+}
+
+// rememberMe reports whether the login form asks to remember the user
+func (opt *Options) rememberMe(r *http.Request) bool {
+	if opt.RememberMe == nil {
+		return false
+	}
+
+	switch strings.ToLower(r.PostFormValue(opt.RememberMeParameter)) {
+	case "1", "on", "true", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type (
+	// pathPattern is an Ant-style path pattern, "*" matches one segment,
+	// "**" matches zero or more segments, "{name}" matches one segment and
+	// captures it as a path parameter, other segments support path.Match syntax
+	pathPattern struct {
+		raw      string
+		segments []string
+	}
+
+	pathParamsCtxKey struct{}
+)
+
+func compilePattern(pattern string) *pathPattern {
+	segments := splitPath(pattern)
+	for _, seg := range segments {
+		if _, ok := paramName(seg); ok || seg == "**" {
+			continue
+		}
+
+		if _, err := path.Match(seg, ""); err != nil {
+			panic("web: malformed pattern " + pattern)
+		}
+	}
+
+	return &pathPattern{raw: pattern, segments: segments}
+}
+
+// PathParam returns the path parameter captured by the pattern of the matched rule
+func PathParam(r *http.Request, name string) string {
+	params, _ := r.Context().Value(pathParamsCtxKey{}).(map[string]string)
+	return params[name]
+}
+
+func withPathParams(r *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), pathParamsCtxKey{}, params))
+}
+
+func (p *pathPattern) match(urlPath string) (map[string]string, bool) {
+	params := make(map[string]string)
+	if !matchSegments(p.segments, splitPath(urlPath), params) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+func matchSegments(pattern []string, segments []string, params map[string]string) bool {
+	for len(pattern) != 0 {
+		seg := pattern[0]
+		if seg == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(segments); i++ {
+				// params captured by a failed attempt must not leak
+				captured := make(map[string]string)
+				if matchSegments(rest, segments[i:], captured) {
+					for k, v := range captured {
+						params[k] = v
+					}
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+
+		if name, ok := paramName(seg); ok {
+			params[name] = segments[0]
+		} else if matched, _ := path.Match(seg, segments[0]); !matched {
+			return false
+		}
+
+		pattern, segments = pattern[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+func paramName(seg string) (string, bool) {
+	if len(seg) > 2 && strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+		return seg[1 : len(seg)-1], true
+	}
+
+	return "", false
+}
+
+func splitPath(p string) []string {
+	// clean the path so that "/public/../admin" cannot bypass rules
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if len(p) == 0 {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	// Type is a URI reference that identifies the problem type
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type
+	Title string `json:"title"`
+	// Status is the HTTP status code
+	Status int `json:"status"`
+	// Detail is an explanation specific to this occurrence
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI reference that identifies this occurrence
+	Instance string `json:"instance,omitempty"`
+}
+
+// ContentTypeProblem is the media type of Problem
+const ContentTypeProblem = "application/problem+json"
+
+// WriteProblem writes a Problem with the status and detail
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&problem)
+}
+
+// WriteError translates the error returned by security.Subject into a Problem,
+// authentication failures are answered with 401, authc.ErrLocked with 429 and
+// Retry-After, authz.ErrForbidden with 403, unavailable realms, e.g. timeouts
+// or broken connections, with 503 and other errors with 500
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var locked *authc.LockedError
+	if errors.As(err, &locked) {
+		retryAfter := int(time.Until(locked.RetryAfter).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		WriteProblem(w, r, http.StatusTooManyRequests, authc.ErrLocked.Error())
+		return
+	}
+
+	var statusErr *authc.AccountStatusError
+	switch {
+	case errors.As(err, &statusErr):
+		WriteProblem(w, r, http.StatusUnauthorized, statusErr.Error())
+	case errors.Is(err, authc.ErrSecondFactorRequired):
+		WriteProblem(w, r, http.StatusUnauthorized, authc.ErrSecondFactorRequired.Error())
+	case errors.Is(err, authc.ErrInvalidToken), errors.Is(err, authc.ErrUnauthenticated):
+		// never disclose which realm rejected the token
+		WriteProblem(w, r, http.StatusUnauthorized, authc.ErrUnauthenticated.Error())
+	case errors.Is(err, authz.ErrForbidden):
+		WriteProblem(w, r, http.StatusForbidden, err.Error())
+	case unavailable(err):
+		WriteProblem(w, r, http.StatusServiceUnavailable, "")
+	default:
+		WriteProblem(w, r, http.StatusInternalServerError, "")
+	}
+}
+
+// rejected reports whether the token has been rejected, as opposed to
+// a failure to authenticate it
+func rejected(err error) bool {
+	var statusErr *authc.AccountStatusError
+	return errors.Is(err, authc.ErrLocked) || errors.As(err, &statusErr) ||
+		errors.Is(err, authc.ErrInvalidToken) || errors.Is(err, authc.ErrUnauthenticated)
+}
+
+// unavailable reports whether the error is caused by an unreachable backend
+func unavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)
+}
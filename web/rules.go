@@ -0,0 +1,288 @@
+package web
+
+import (
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/security"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+type (
+	// A Requirement decides whether the Subject can access the request
+	Requirement interface {
+		// Check returns nil if the request can proceed, authc.ErrUnauthenticated
+		// if the Subject must log in first, authz.ErrForbidden if the Subject
+		// is not allowed, or the realm failure otherwise
+		Check(security.Subject, *http.Request) error
+		// String describes the Requirement in the rule table
+		String() string
+	}
+
+	// Rule maps method and path pattern to a Requirement
+	Rule struct {
+		// Methods restricts the rule to the methods, empty for any method
+		Methods []string
+		// Pattern is the path pattern
+		Pattern string
+		// Requirement the request must satisfy
+		Requirement Requirement
+
+		pattern *pathPattern
+	}
+
+	// Rules is an ordered list of Rule, the first matched Rule
+	// applies and requests that match no Rule are denied
+	Rules struct {
+		rules []*Rule
+	}
+
+	// RuleBuilder provides a fluent way to add Rule to Rules
+	RuleBuilder struct {
+		rules   *Rules
+		pattern string
+		methods []string
+	}
+
+	requirement struct {
+		desc  string
+		check func(security.Subject, *http.Request) error
+	}
+)
+
+var (
+	_ Requirement = (*requirement)(nil)
+
+	// PermitAll allows anyone, including anonymous users
+	PermitAll Requirement = &requirement{
+		desc: "permitAll",
+		check: func(security.Subject, *http.Request) error {
+			return nil
+		},
+	}
+
+	// DenyAll allows nobody
+	DenyAll = predicate("denyAll", func(security.Subject, *http.Request) bool {
+		return false
+	})
+
+	// Authenticated allows fully authenticated users
+	Authenticated Requirement = &requirement{
+		desc:  "authenticated",
+		check: authenticated,
+	}
+)
+
+// NewRules returns an empty Rules
+func NewRules() *Rules {
+	return &Rules{}
+}
+
+// Route starts a Rule for the path pattern and methods, empty methods match any method
+func (rs *Rules) Route(pattern string, methods ...string) *RuleBuilder {
+	return &RuleBuilder{rules: rs, pattern: pattern, methods: methods}
+}
+
+// AnyRequest starts a Rule that matches every request,
+// it is usually the last one
+func (rs *Rules) AnyRequest() *RuleBuilder {
+	return rs.Route("/**")
+}
+
+// Match returns the first Rule that matches the request,
+// together with the captured path parameters
+func (rs *Rules) Match(r *http.Request) (*Rule, map[string]string, bool) {
+	for _, rule := range rs.rules {
+		if !rule.matchMethod(r.Method) {
+			continue
+		}
+
+		if params, ok := rule.pattern.match(r.URL.Path); ok {
+			return rule, params, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// Table returns the effective rules in evaluation order
+func (rs *Rules) Table() []Rule {
+	table := make([]Rule, 0, len(rs.rules))
+	for _, rule := range rs.rules {
+		table = append(table, *rule)
+	}
+
+	return table
+}
+
+// String dumps the effective rule table for auditing
+func (rs *Rules) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "#\tMETHODS\tPATTERN\tREQUIREMENT")
+	for i, rule := range rs.rules {
+		methods := "*"
+		if len(rule.Methods) != 0 {
+			methods = strings.Join(rule.Methods, ",")
+		}
+
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", i+1, methods, rule.Pattern, rule.Requirement)
+	}
+	_, _ = fmt.Fprintf(tw, "-\t*\t(unmatched)\t%s\n", DenyAll)
+	_ = tw.Flush()
+
+	return sb.String()
+}
+
+func (rule *Rule) matchMethod(method string) bool {
+	if len(rule.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+///=====================================
+///		    RuleBuilder
+///=====================================
+
+// Require completes the Rule with the Requirement
+func (b *RuleBuilder) Require(requirement Requirement) *Rules {
+	b.rules.rules = append(b.rules.rules, &Rule{
+		Methods:     b.methods,
+		Pattern:     b.pattern,
+		Requirement: requirement,
+		pattern:     compilePattern(b.pattern),
+	})
+	return b.rules
+}
+
+func (b *RuleBuilder) PermitAll() *Rules {
+	return b.Require(PermitAll)
+}
+
+func (b *RuleBuilder) DenyAll() *Rules {
+	return b.Require(DenyAll)
+}
+
+func (b *RuleBuilder) Authenticated() *Rules {
+	return b.Require(Authenticated)
+}
+
+func (b *RuleBuilder) HasRole(role authz.Role) *Rules {
+	return b.hasAnyRole("hasRole", role)
+}
+
+func (b *RuleBuilder) HasAnyRole(roles ...authz.Role) *Rules {
+	return b.hasAnyRole("hasAnyRole", roles...)
+}
+
+func (b *RuleBuilder) hasAnyRole(name string, roles ...authz.Role) *Rules {
+	return b.Require(&requirement{
+		desc: authz.Requirement(name, roles...),
+		check: func(subject security.Subject, r *http.Request) error {
+			return subject.CheckAnyRole(r.Context(), roles...)
+		},
+	})
+}
+
+func (b *RuleBuilder) HasAuthority(authority authz.Authority) *Rules {
+	return b.hasAllAuthority("hasAuthority", authority)
+}
+
+func (b *RuleBuilder) HasAnyAuthority(authorities ...authz.Authority) *Rules {
+	return b.Require(&requirement{
+		desc: authz.Requirement("hasAnyAuthority", authorities...),
+		check: func(subject security.Subject, r *http.Request) error {
+			return subject.CheckAnyAuthority(r.Context(), authorities...)
+		},
+	})
+}
+
+func (b *RuleBuilder) HasAllAuthority(authorities ...authz.Authority) *Rules {
+	return b.hasAllAuthority("hasAllAuthority", authorities...)
+}
+
+func (b *RuleBuilder) hasAllAuthority(name string, authorities ...authz.Authority) *Rules {
+	return b.Require(&requirement{
+		desc: authz.Requirement(name, authorities...),
+		check: func(subject security.Subject, r *http.Request) error {
+			return subject.CheckAllAuthority(r.Context(), authorities...)
+		},
+	})
+}
+
+// Expression completes the Rule with an authz.Expression, path parameters are
+// available as resource attributes and the method as action, e.g.
+//
+//	hasRole('admin') or resource.user == principal
+//
+// It panics if the expression is malformed
+func (b *RuleBuilder) Expression(source string) *Rules {
+	expr := authz.MustCompileExpression(source)
+	return b.Require(&requirement{
+		desc: source,
+		check: func(subject security.Subject, r *http.Request) error {
+			params, _ := r.Context().Value(pathParamsCtxKey{}).(map[string]string)
+			resource := make(authz.Attributes, len(params))
+			for k, v := range params {
+				resource[k] = v
+			}
+
+			return subject.CheckExpression(r.Context(), expr, r.Method, resource)
+		},
+	})
+}
+
+// Access completes the Rule with a custom predicate, path parameters
+// can be read by PathParam, desc is shown in the rule table
+func (b *RuleBuilder) Access(desc string, p func(security.Subject, *http.Request) bool) *Rules {
+	return b.Require(predicate(desc, p))
+}
+
+func (req *requirement) Check(subject security.Subject, r *http.Request) error {
+	return req.check(subject, r)
+}
+
+func (req *requirement) String() string {
+	return req.desc
+}
+
+// predicate returns a Requirement that fails with authz.ForbiddenError,
+// or with the authentication error if the Subject is not authenticated
+func predicate(desc string, p func(security.Subject, *http.Request) bool) Requirement {
+	return &requirement{
+		desc: desc,
+		check: func(subject security.Subject, r *http.Request) error {
+			if p(subject, r) {
+				return nil
+			}
+
+			if err := authenticated(subject, r); err != nil {
+				return err
+			}
+
+			return &authz.ForbiddenError{Requirement: desc}
+		},
+	}
+}
+
+func authenticated(subject security.Subject, r *http.Request) error {
+	if subject.Authenticated(r.Context()) {
+		return nil
+	}
+
+	if subject.PartiallyAuthenticated(r.Context()) {
+		return authc.ErrSecondFactorRequired
+	}
+
+	return authc.ErrUnauthenticated
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/shrinex/shield/authc"
+	"github.com/shrinex/shield/authz"
+	"github.com/shrinex/shield/codec"
+	"github.com/shrinex/shield/security"
+	"github.com/shrinex/shield/semgt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPathPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matched bool
+		params  map[string]string
+	}{
+		{"/", "/", true, map[string]string{}},
+		{"/users", "/users/", true, map[string]string{}},
+		{"/users/*", "/users/1", true, map[string]string{}},
+		{"/users/*", "/users/1/orders", false, nil},
+		{"/users/**", "/users", true, map[string]string{}},
+		{"/users/**", "/users/1/orders", true, map[string]string{}},
+		{"/users/{id}", "/users/1", true, map[string]string{"id": "1"}},
+		{"/users/{id}/orders/{oid}", "/users/1/orders/2", true, map[string]string{"id": "1", "oid": "2"}},
+		{"/**/{id}/edit", "/a/b/7/edit", true, map[string]string{"id": "7"}},
+		{"/static/*.css", "/static/site.css", true, map[string]string{}},
+		{"/static/*.css", "/static/site.js", false, nil},
+		{"/public/**", "/public/../admin", false, nil},
+	}
+
+	for _, c := range cases {
+		params, matched := compilePattern(c.pattern).match(c.path)
+		assert.Equal(t, c.matched, matched, c.pattern+" "+c.path)
+		assert.Equal(t, c.params, params, c.pattern+" "+c.path)
+	}
+
+	assert.Panics(t, func() { compilePattern("/[") })
+}
+
+func TestAuthorize(t *testing.T) {
+	m := newMiddleware()
+
+	w := login(t, m, "123")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp loginResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	token := resp.Token
+
+	rules := NewRules().
+		Route("/public/**").PermitAll().
+		Route("/users/{id}", http.MethodGet).Access("self", func(subject security.Subject, r *http.Request) bool {
+		userDetails, err := subject.UserDetails(r.Context())
+		return err == nil && userDetails.Principal() == PathParam(r, "id")
+	}).
+		Route("/reports/**").HasAnyAuthority(authz.NewAuthority("read"), authz.NewAuthority("write")).
+		Route("/admin/**").HasRole(authz.NewRole("admin")).
+		Route("/orders/{user}/**").Expression("hasRole('admin') or (hasAuthority('read') and resource.user == principal)").
+		Route("/profile").Authenticated()
+
+	var param string
+	handler := m.Handler(m.Authorize(rules, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param = PathParam(r, "id")
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	serve := func(method string, path string, token string) int {
+		r := httptest.NewRequest(method, path, nil)
+		if len(token) != 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/public/index.html", ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/profile", ""))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/profile", token))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/reports/2024", token))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/admin/users", token))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/users/archer", token))
+	assert.Equal(t, "archer", param)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/users/other", token))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/orders/archer/1", token))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/orders/other/1", token))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/orders/archer/1", ""))
+
+	// unmatched requests are denied
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, "/users/archer", token))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/unknown", ""))
+}
+
+func TestRulesTable(t *testing.T) {
+	rules := NewRules().
+		Route("/public/**").PermitAll().
+		Route("/users/{id}", http.MethodGet, http.MethodPut).HasAnyRole(authz.NewRole("admin"), authz.NewRole("user")).
+		AnyRequest().Authenticated()
+
+	table := rules.Table()
+	assert.Equal(t, 3, len(table))
+	assert.Equal(t, "/users/{id}", table[1].Pattern)
+	assert.Equal(t, "hasAnyRole(admin, user)", table[1].Requirement.String())
+
+	expected := `#  METHODS  PATTERN      REQUIREMENT
+1  *        /public/**   permitAll
+2  GET,PUT  /users/{id}  hasAnyRole(admin, user)
+3  *        /**          authenticated
+-  *        (unmatched)  denyAll
+`
+	assert.Equal(t, expected, rules.String())
+}
+
+// errDatabaseDown is an infrastructure failure
+var errDatabaseDown = fmt.Errorf("database down: %w", context.DeadlineExceeded)
+
+type failingAuthzRealm struct {
+}
+
+func (r *failingAuthzRealm) LoadRoles(context.Context, authc.UserDetails) ([]authz.Role, error) {
+	return nil, errDatabaseDown
+}
+
+func (r *failingAuthzRealm) LoadAuthorities(context.Context, authc.UserDetails) ([]authz.Authority, error) {
+	return nil, errDatabaseDown
+}
+
+func TestAuthorizeWithRealmFailure(t *testing.T) {
+	repository := semgt.NewRepository(codec.JSON, time.Hour, time.Hour)
+	subject := security.NewBuilder[*semgt.MapSession]().
+		Authenticator(authc.NewAuthenticator(&mockRealm{repository: repository})).
+		Authorizer(authz.NewAuthorizer(&failingAuthzRealm{})).
+		Repository(repository).
+		Registry(semgt.NewRegistry(repository)).
+		Build()
+	m := NewMiddleware(subject)
+
+	w := login(t, m, "123")
+	var resp loginResponse
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+
+	rules := NewRules().AnyRequest().HasRole(authz.NewRole("admin"))
+	handler := m.Handler(m.Authorize(rules, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}